@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package anim decodes animated GIF and PNG (APNG) files into whole
+// frames, and plays them back in a Gio window.
+//
+// The frames of an animated file are patches: each covers part of
+// the canvas, is blended onto what the frames before it left there,
+// and is then disposed of in one of three ways before the next
+// frame: left in place, cleared to transparent, or undone. Decode
+// applies all of that up front, so an Animation holds every frame
+// as the full canvas it shows, ready to be made into an ImageOp.
+package anim
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	// minDelay is the shortest frame delay honoured. Like web
+	// browsers, shorter delays, which old encoders write for "as
+	// fast as possible", are taken as defaultDelay.
+	minDelay     = 20 * time.Millisecond
+	defaultDelay = 100 * time.Millisecond
+)
+
+// Animation is a decoded animated image. As an image.Image it is its
+// first frame, so that it can go wherever a still image does.
+type Animation struct {
+	// Frames are the frames as shown, all the size of the canvas.
+	Frames []*image.RGBA
+	// Delays are how long each frame shows.
+	Delays []time.Duration
+	// Loops is how many times the animation plays. Zero means
+	// forever.
+	Loops int
+}
+
+func (a *Animation) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (a *Animation) Bounds() image.Rectangle {
+	return a.Frames[0].Bounds()
+}
+
+func (a *Animation) At(x, y int) color.Color {
+	return a.Frames[0].At(x, y)
+}
+
+// Duration returns how long one play of a takes.
+func (a *Animation) Duration() time.Duration {
+	var d time.Duration
+	for _, fd := range a.Delays {
+		d += fd
+	}
+	return d
+}
+
+var (
+	gifHeader = []byte("GIF8")
+	pngHeader = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// Decode decodes an image like image.Decode does, except that a GIF
+// or PNG file with more than one frame comes back as an *Animation.
+func Decode(r io.Reader) (image.Image, string, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	head, _ := br.Peek(len(pngHeader))
+	switch {
+	case bytes.HasPrefix(head, gifHeader):
+		g, err := gif.DecodeAll(br)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(g.Image) == 1 {
+			return g.Image[0], "gif", nil
+		}
+		return fromGIF(g), "gif", nil
+	case bytes.Equal(head, pngHeader):
+		data, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, "", err
+		}
+		if !isAPNG(data) {
+			img, err := png.Decode(bytes.NewReader(data))
+			return img, "png", err
+		}
+		a, err := decodeAPNG(data)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(a.Frames) == 1 {
+			return a.Frames[0], "png", nil
+		}
+		return a, "png", nil
+	}
+	return image.Decode(br)
+}
+
+// frameDelay returns d, or defaultDelay if d is too short to be
+// meant.
+func frameDelay(d time.Duration) time.Duration {
+	if d < minDelay {
+		return defaultDelay
+	}
+	return d
+}
+
+// disposal is what happens to a frame before the next is drawn.
+type disposal int
+
+const (
+	// disposeNone leaves the frame on the canvas.
+	disposeNone disposal = iota
+	// disposeBackground clears the frame's area to transparent.
+	disposeBackground
+	// disposePrevious puts back what was under the frame.
+	disposePrevious
+)
+
+// compositor builds up the frames of an animation on a canvas.
+type compositor struct {
+	canvas *image.RGBA
+	anim   *Animation
+}
+
+func newCompositor(size image.Point) *compositor {
+	return &compositor{
+		canvas: image.NewRGBA(image.Rectangle{Max: size}),
+		anim:   new(Animation),
+	}
+}
+
+// add blends the patch img onto the canvas with op, records the
+// canvas as the next frame, and then disposes of the patch.
+func (c *compositor) add(img image.Image, op draw.Op, d time.Duration, dispose disposal) {
+	var prev *image.RGBA
+	r := img.Bounds().Intersect(c.canvas.Rect)
+	if dispose == disposePrevious {
+		prev = image.NewRGBA(r)
+		draw.Draw(prev, r, c.canvas, r.Min, draw.Src)
+	}
+	draw.Draw(c.canvas, r, img, r.Min, op)
+	frame := image.NewRGBA(c.canvas.Rect)
+	copy(frame.Pix, c.canvas.Pix)
+	c.anim.Frames = append(c.anim.Frames, frame)
+	c.anim.Delays = append(c.anim.Delays, frameDelay(d))
+	switch dispose {
+	case disposeBackground:
+		draw.Draw(c.canvas, r, image.Transparent, image.Point{}, draw.Src)
+	case disposePrevious:
+		draw.Draw(c.canvas, r, prev, r.Min, draw.Src)
+	}
+}
+
+// fromGIF composes the frames of g.
+func fromGIF(g *gif.GIF) *Animation {
+	size := image.Point{X: g.Config.Width, Y: g.Config.Height}
+	if size.X == 0 || size.Y == 0 {
+		var r image.Rectangle
+		for _, img := range g.Image {
+			r = r.Union(img.Rect)
+		}
+		size = r.Max
+	}
+	c := newCompositor(size)
+	for i, img := range g.Image {
+		dispose := disposeNone
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				// Browsers clear to transparent rather than to the
+				// background color, and so do we.
+				dispose = disposeBackground
+			case gif.DisposalPrevious:
+				dispose = disposePrevious
+			}
+		}
+		// Transparent pixels let the canvas show through.
+		c.add(img, draw.Over, time.Duration(g.Delay[i])*10*time.Millisecond, dispose)
+	}
+	// LoopCount is the number of repeats: -1 for none, 0 for
+	// forever.
+	switch {
+	case g.LoopCount < 0:
+		c.anim.Loops = 1
+	case g.LoopCount > 0:
+		c.anim.Loops = g.LoopCount + 1
+	}
+	return c.anim
+}
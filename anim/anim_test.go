@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package anim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+)
+
+var (
+	clear = color.RGBA{}
+	red   = color.RGBA{R: 0xff, A: 0xff}
+	green = color.RGBA{G: 0xff, A: 0xff}
+	blue  = color.RGBA{B: 0xff, A: 0xff}
+)
+
+// checkFrame compares frame i of a with want, a map of the pixels
+// that are not red.
+func checkFrame(t *testing.T, a *Animation, i int, want map[image.Point]color.RGBA) {
+	t.Helper()
+	f := a.Frames[i]
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			w, ok := want[image.Point{X: x, Y: y}]
+			if !ok {
+				w = red
+			}
+			if got := f.RGBAAt(x, y); got != w {
+				t.Errorf("frame %d: pixel (%d,%d) is %v, want %v", i, x, y, got, w)
+			}
+		}
+	}
+}
+
+func TestDecodeGIF(t *testing.T) {
+	pal := color.Palette{clear, red, green, blue}
+	fill := func(r image.Rectangle, c uint8) *image.Paletted {
+		img := image.NewPaletted(r, pal)
+		for i := range img.Pix {
+			img.Pix[i] = c
+		}
+		return img
+	}
+	last := fill(image.Rect(2, 2, 4, 4), 3)
+	last.SetColorIndex(3, 3, 0)
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			fill(image.Rect(0, 0, 4, 4), 1),
+			fill(image.Rect(0, 0, 2, 2), 2),
+			last,
+		},
+		Delay:     []int{10, 0, 25},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalBackground},
+		LoopCount: 2,
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, ok := img.(*Animation)
+	if !ok || format != "gif" {
+		t.Fatalf("decoded a %T of format %q, want an animated GIF", img, format)
+	}
+	if len(a.Frames) != 3 || a.Loops != 3 {
+		t.Fatalf("%d frames played %d times, want 3 frames played 3 times", len(a.Frames), a.Loops)
+	}
+	checkFrame(t, a, 0, nil)
+	checkFrame(t, a, 1, map[image.Point]color.RGBA{{0, 0}: green, {1, 0}: green, {0, 1}: green, {1, 1}: green})
+	// The green patch is undone, and the transparent pixel of the
+	// last patch shows what is under it.
+	checkFrame(t, a, 2, map[image.Point]color.RGBA{{2, 2}: blue, {3, 2}: blue, {2, 3}: blue})
+	want := []time.Duration{100 * time.Millisecond, defaultDelay, 250 * time.Millisecond}
+	for i, d := range a.Delays {
+		if d != want[i] {
+			t.Errorf("frame %d shows for %v, want %v", i, d, want[i])
+		}
+	}
+}
+
+// testFrame is a frame of a test APNG file.
+type testFrame struct {
+	img              *image.NRGBA
+	dispose, blend   byte
+	delayNum, delayD uint16
+}
+
+// encodeAPNG writes an APNG file of the frames, the first of which
+// is the default image and covers the canvas. Every frame has a
+// transparent pixel, so that they share a color type.
+func encodeAPNG(t *testing.T, loops int, frames []testFrame) []byte {
+	var buf bytes.Buffer
+	buf.Write(pngHeader)
+	u32 := func(vs ...uint32) []byte {
+		b := make([]byte, 4*len(vs))
+		for i, v := range vs {
+			binary.BigEndian.PutUint32(b[4*i:], v)
+		}
+		return b
+	}
+	seq := uint32(0)
+	for i, f := range frames {
+		var enc bytes.Buffer
+		sub := image.NewNRGBA(image.Rectangle{Max: f.img.Rect.Size()})
+		copy(sub.Pix, f.img.Pix)
+		if err := png.Encode(&enc, sub); err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := readChunks(enc.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			writeChunk(&buf, "IHDR", chunks[0].data)
+			writeChunk(&buf, "acTL", u32(uint32(len(frames)), uint32(loops)))
+		}
+		r := f.img.Rect
+		fc := u32(seq, uint32(r.Dx()), uint32(r.Dy()), uint32(r.Min.X), uint32(r.Min.Y))
+		fc = append(fc, byte(f.delayNum>>8), byte(f.delayNum), byte(f.delayD>>8), byte(f.delayD), f.dispose, f.blend)
+		writeChunk(&buf, "fcTL", fc)
+		seq++
+		for _, c := range chunks {
+			if c.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writeChunk(&buf, "IDAT", c.data)
+				continue
+			}
+			writeChunk(&buf, "fdAT", append(u32(seq), c.data...))
+			seq++
+		}
+	}
+	writeChunk(&buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+func TestDecodeAPNG(t *testing.T) {
+	fill := func(r image.Rectangle, c color.RGBA, hole image.Point) *image.NRGBA {
+		img := image.NewNRGBA(r)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if (image.Point{X: x, Y: y}) != hole {
+					img.Set(x, y, c)
+				}
+			}
+		}
+		return img
+	}
+	data := encodeAPNG(t, 0, []testFrame{
+		{img: fill(image.Rect(0, 0, 4, 4), red, image.Pt(3, 3)), delayNum: 1, delayD: 10},
+		// Blended over the frame before, disposed of back to it.
+		{img: fill(image.Rect(1, 1, 3, 3), blue, image.Pt(1, 1)), dispose: 2, blend: 1, delayNum: 50},
+		// Copied over the frame before.
+		{img: fill(image.Rect(0, 0, 2, 1), green, image.Pt(1, 0)), dispose: 1},
+	})
+	img, format, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, ok := img.(*Animation)
+	if !ok || format != "png" {
+		t.Fatalf("decoded a %T of format %q, want an APNG", img, format)
+	}
+	if len(a.Frames) != 3 || a.Loops != 0 {
+		t.Fatalf("%d frames played %d times, want 3 frames played forever", len(a.Frames), a.Loops)
+	}
+	checkFrame(t, a, 0, map[image.Point]color.RGBA{{3, 3}: clear})
+	checkFrame(t, a, 1, map[image.Point]color.RGBA{{3, 3}: clear, {2, 1}: blue, {1, 2}: blue, {2, 2}: blue})
+	checkFrame(t, a, 2, map[image.Point]color.RGBA{{3, 3}: clear, {0, 0}: green, {1, 0}: clear})
+	want := []time.Duration{100 * time.Millisecond, 500 * time.Millisecond, defaultDelay}
+	for i, d := range a.Delays {
+		if d != want[i] {
+			t.Errorf("frame %d shows for %v, want %v", i, d, want[i])
+		}
+	}
+}
+
+func TestDecodeStill(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := img.(*Animation); ok || format != "png" || img.Bounds() != src.Rect {
+		t.Errorf("decoded a %T of format %q and size %v, want a still PNG", img, format, img.Bounds())
+	}
+}
+
+// clock is a system.Config with a settable time.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Px(v unit.Value) int {
+	return int(v.V + .5)
+}
+
+func TestPlayer(t *testing.T) {
+	ms := time.Millisecond
+	a := &Animation{Delays: []time.Duration{100 * ms, 200 * ms, 300 * ms}, Loops: 2}
+	for range a.Delays {
+		a.Frames = append(a.Frames, image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	}
+	p := NewPlayer(a, image.Rect(0, 0, 2, 2))
+	start := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	c := &clock{}
+	gtx := layout.NewContext(nil)
+	at := func(d time.Duration) {
+		t.Helper()
+		c.now = start.Add(d)
+		gtx.Reset(c, image.Point{X: 10, Y: 10})
+		p.Update(gtx)
+	}
+	for _, tc := range []struct {
+		at    time.Duration
+		frame int
+	}{
+		{0, 0},
+		{99 * ms, 0},
+		{150 * ms, 1},
+		// The second play starts at 600ms.
+		{650 * ms, 0},
+		// And ends at 1200ms, on the last frame.
+		{5 * time.Second, 2},
+	} {
+		at(tc.at)
+		if got := p.Frame(); got != tc.frame {
+			t.Errorf("at %v frame %d shows, want %d", tc.at, got, tc.frame)
+		}
+	}
+	if p.Playing() {
+		t.Error("still playing after the last play")
+	}
+
+	p.Toggle()
+	if !p.Playing() || p.Frame() != 0 {
+		t.Errorf("Play after the end shows frame %d (playing: %v), want frame 0", p.Frame(), p.Playing())
+	}
+	p.Step(-1)
+	if p.Playing() || p.Frame() != 2 {
+		t.Errorf("Step(-1) shows frame %d (playing: %v), want frame 2, paused", p.Frame(), p.Playing())
+	}
+	p.Step(2)
+	if p.Frame() != 1 {
+		t.Errorf("Step(2) shows frame %d, want 1", p.Frame())
+	}
+	at(10 * time.Second)
+	if p.Frame() != 1 {
+		t.Error("a paused animation moved on")
+	}
+
+	// Long after, the plays missed are skipped, and the animation
+	// keeps its pace.
+	p.Loops = -1
+	p.Play()
+	at(20 * time.Second)
+	at(time.Minute)
+	if got := p.Frame(); got != 2 {
+		t.Errorf("after a long gap frame %d shows, want 2", got)
+	}
+}
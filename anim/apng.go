@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package anim
+
+// APNG, https://wiki.mozilla.org/APNG_Specification, adds three
+// chunks to PNG: acTL before the image data gives the number of
+// frames and plays, an fcTL before each frame gives its size,
+// offset, delay and blend and dispose operations, and the data of
+// the frames after the first is in fdAT chunks, which are IDAT
+// chunks with a sequence number in front. image/png skips all
+// three, so each frame is decoded by wrapping its data in a PNG of
+// its own, with the header and palette of the file.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"time"
+)
+
+// chunk is a PNG chunk.
+type chunk struct {
+	typ  string
+	data []byte
+}
+
+// readChunks splits the PNG file data into its chunks.
+func readChunks(data []byte) ([]chunk, error) {
+	if !bytes.HasPrefix(data, pngHeader) {
+		return nil, errors.New("apng: not a PNG file")
+	}
+	data = data[len(pngHeader):]
+	var chunks []chunk
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return nil, errors.New("apng: truncated chunk")
+		}
+		n := binary.BigEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-12) {
+			return nil, errors.New("apng: truncated chunk")
+		}
+		chunks = append(chunks, chunk{typ: string(data[4:8]), data: data[8 : 8+n]})
+		data = data[12+n:]
+	}
+	return chunks, nil
+}
+
+// isAPNG reports whether the PNG file data has an animation control
+// chunk, which must come before the image data.
+func isAPNG(data []byte) bool {
+	chunks, err := readChunks(data)
+	if err != nil {
+		return false
+	}
+	for _, c := range chunks {
+		switch c.typ {
+		case "acTL":
+			return true
+		case "IDAT":
+			return false
+		}
+	}
+	return false
+}
+
+// frameControl is the content of an fcTL chunk.
+type frameControl struct {
+	width, height, x, y uint32
+	delayNum, delayDen  uint16
+	dispose             disposal
+	blend               draw.Op
+}
+
+func parseFrameControl(data []byte) (frameControl, error) {
+	if len(data) != 26 {
+		return frameControl{}, fmt.Errorf("apng: fcTL is %d bytes, want 26", len(data))
+	}
+	be := binary.BigEndian
+	fc := frameControl{
+		width:    be.Uint32(data[4:]),
+		height:   be.Uint32(data[8:]),
+		x:        be.Uint32(data[12:]),
+		y:        be.Uint32(data[16:]),
+		delayNum: be.Uint16(data[20:]),
+		delayDen: be.Uint16(data[22:]),
+		blend:    draw.Src,
+	}
+	switch data[24] {
+	case 1:
+		fc.dispose = disposeBackground
+	case 2:
+		fc.dispose = disposePrevious
+	}
+	if data[25] == 1 {
+		fc.blend = draw.Over
+	}
+	return fc, nil
+}
+
+func (fc frameControl) delay() time.Duration {
+	den := time.Duration(fc.delayDen)
+	if den == 0 {
+		den = 100
+	}
+	return time.Duration(fc.delayNum) * time.Second / den
+}
+
+// apngFrame is a frame being gathered from the chunks.
+type apngFrame struct {
+	fc   frameControl
+	data [][]byte
+}
+
+// decodeAPNG composes the frames of the APNG file data.
+func decodeAPNG(data []byte) (*Animation, error) {
+	chunks, err := readChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		ihdr   []byte
+		shared []chunk
+		frames []*apngFrame
+		loops  int
+		// cur is the frame the image data chunks belong to, or nil
+		// for a default image that is not part of the animation.
+		cur      *apngFrame
+		seenIDAT bool
+	)
+	for _, c := range chunks {
+		switch c.typ {
+		case "IHDR":
+			if len(c.data) != 13 {
+				return nil, errors.New("apng: bad IHDR")
+			}
+			ihdr = c.data
+		case "acTL":
+			if len(c.data) != 8 {
+				return nil, errors.New("apng: bad acTL")
+			}
+			loops = int(binary.BigEndian.Uint32(c.data[4:]))
+		case "fcTL":
+			fc, err := parseFrameControl(c.data)
+			if err != nil {
+				return nil, err
+			}
+			cur = &apngFrame{fc: fc}
+			frames = append(frames, cur)
+		case "IDAT":
+			seenIDAT = true
+			if cur != nil {
+				cur.data = append(cur.data, c.data)
+			}
+		case "fdAT":
+			if len(c.data) < 4 || cur == nil {
+				return nil, errors.New("apng: fdAT without fcTL")
+			}
+			cur.data = append(cur.data, c.data[4:])
+		case "IEND":
+		default:
+			// The palette, transparency and color chunks apply
+			// to every frame.
+			if !seenIDAT {
+				shared = append(shared, c)
+			}
+		}
+	}
+	if ihdr == nil || len(frames) == 0 {
+		return nil, errors.New("apng: no frames")
+	}
+	be := binary.BigEndian
+	c := newCompositor(image.Point{X: int(be.Uint32(ihdr)), Y: int(be.Uint32(ihdr[4:]))})
+	c.anim.Loops = loops
+	for i, f := range frames {
+		img, err := decodeFrame(ihdr, shared, f)
+		if err != nil {
+			return nil, fmt.Errorf("apng: frame %d: %v", i, err)
+		}
+		dispose := f.fc.dispose
+		if i == 0 && dispose == disposePrevious {
+			// There is nothing to go back to.
+			dispose = disposeBackground
+		}
+		c.add(img, f.fc.blend, f.fc.delay(), dispose)
+	}
+	return c.anim, nil
+}
+
+// decodeFrame decodes frame f as a PNG with the header ihdr, resized
+// to the frame, and the shared chunks, and returns it moved to its
+// offset on the canvas.
+func decodeFrame(ihdr []byte, shared []chunk, f *apngFrame) (image.Image, error) {
+	hdr := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(hdr, f.fc.width)
+	binary.BigEndian.PutUint32(hdr[4:], f.fc.height)
+	var buf bytes.Buffer
+	buf.Write(pngHeader)
+	writeChunk(&buf, "IHDR", hdr)
+	for _, c := range shared {
+		writeChunk(&buf, c.typ, c.data)
+	}
+	for _, d := range f.data {
+		writeChunk(&buf, "IDAT", d)
+	}
+	writeChunk(&buf, "IEND", nil)
+	img, err := png.Decode(&buf)
+	if err != nil {
+		return nil, err
+	}
+	// Move the frame to its offset.
+	off := image.Point{X: int(f.fc.x), Y: int(f.fc.y)}
+	moved := image.NewRGBA(img.Bounds().Add(off))
+	draw.Draw(moved, moved.Rect, img, img.Bounds().Min, draw.Src)
+	return moved, nil
+}
+
+func writeChunk(buf *bytes.Buffer, typ string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	buf.Write(n[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	buf.WriteString(typ)
+	buf.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	buf.Write(n[:])
+}
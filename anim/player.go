@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package anim
+
+import (
+	"image"
+	"image/draw"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+)
+
+// Player plays an Animation. Each frame, call Update, then paint
+// ImageOp; Update asks for a redraw when the next frame is due, so
+// the window only draws as often as the animation changes.
+type Player struct {
+	// Loops, if not zero, overrides how many times the animation
+	// plays. Negative means forever.
+	Loops int
+
+	anim *Animation
+	// ops are the frames as ImageOps.
+	ops []paint.ImageOp
+	// frame is the frame shown, and plays counts the plays
+	// finished.
+	frame, plays int
+	// paused is set by Pause and Step, and done once the last play
+	// ends.
+	paused, done bool
+	// due is when the frame after this one shows. It is zero until
+	// the first Update after the player starts or resumes.
+	due time.Time
+}
+
+// NewPlayer returns a Player, playing, of the r part of the frames
+// of a.
+func NewPlayer(a *Animation, r image.Rectangle) *Player {
+	p := &Player{anim: a}
+	for _, f := range a.Frames {
+		img := f
+		if r != f.Rect || r.Min != (image.Point{}) {
+			img = image.NewRGBA(image.Rectangle{Max: r.Size()})
+			draw.Draw(img, img.Rect, f, r.Min, draw.Src)
+		}
+		p.ops = append(p.ops, paint.NewImageOp(img))
+	}
+	return p
+}
+
+// Update moves on to the frame due at gtx.Now(), and asks for a
+// redraw when the one after it is due.
+func (p *Player) Update(gtx *layout.Context) {
+	if !p.Playing() {
+		return
+	}
+	now := gtx.Now()
+	if p.due.IsZero() {
+		p.due = now.Add(p.anim.Delays[p.frame])
+	}
+	if d := p.anim.Duration(); now.Sub(p.due) > d {
+		// Far behind, after the window was hidden: skip the whole
+		// plays missed rather than race through their frames.
+		n := int(now.Sub(p.due) / d)
+		p.plays += n
+		p.due = p.due.Add(time.Duration(n) * d)
+		if loops := p.loops(); loops > 0 && p.plays >= loops {
+			p.frame, p.done = len(p.ops)-1, true
+			return
+		}
+	}
+	for !now.Before(p.due) {
+		if !p.advance() {
+			return
+		}
+		p.due = p.due.Add(p.anim.Delays[p.frame])
+	}
+	op.InvalidateOp{At: p.due}.Add(gtx.Ops)
+}
+
+// advance moves to the next frame, and reports whether there is
+// one: it stops on the last frame of the last play.
+func (p *Player) advance() bool {
+	if p.frame+1 < len(p.ops) {
+		p.frame++
+		return true
+	}
+	p.plays++
+	if loops := p.loops(); loops > 0 && p.plays >= loops {
+		p.done = true
+		return false
+	}
+	p.frame = 0
+	return true
+}
+
+// loops returns how many times to play; zero means forever.
+func (p *Player) loops() int {
+	switch {
+	case p.Loops < 0:
+		return 0
+	case p.Loops > 0:
+		return p.Loops
+	}
+	return p.anim.Loops
+}
+
+// ImageOp returns the frame shown.
+func (p *Player) ImageOp() paint.ImageOp {
+	return p.ops[p.frame]
+}
+
+// Frame returns the index of the frame shown.
+func (p *Player) Frame() int {
+	return p.frame
+}
+
+// Frames returns the number of frames.
+func (p *Player) Frames() int {
+	return len(p.ops)
+}
+
+// Playing reports whether the animation is running: not paused,
+// and not stopped at the end of its last play.
+func (p *Player) Playing() bool {
+	return !p.paused && !p.done && len(p.ops) > 1
+}
+
+// Play resumes playing, from the start if the last play has ended.
+func (p *Player) Play() {
+	if p.done {
+		p.frame, p.plays, p.done = 0, 0, false
+	}
+	p.paused = false
+	p.due = time.Time{}
+}
+
+// Pause stops on the frame shown.
+func (p *Player) Pause() {
+	p.paused = true
+}
+
+// Toggle pauses a playing animation, and plays a stopped one.
+func (p *Player) Toggle() {
+	if p.Playing() {
+		p.Pause()
+	} else {
+		p.Play()
+	}
+}
+
+// Step pauses and moves n frames on, or back if n is negative,
+// wrapping around at the ends.
+func (p *Player) Step(n int) {
+	p.Pause()
+	p.done = false
+	if len(p.ops) == 0 {
+		return
+	}
+	p.frame = ((p.frame+n)%len(p.ops) + len(p.ops)) % len(p.ops)
+}
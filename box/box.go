@@ -0,0 +1,294 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package box draws labelled rectangles at positions of your
+// choosing, without going through the constraint layout system.
+//
+// It grew out of the box type in hello_gio.go. A Box paints a
+// filled rectangle, optionally with rounded corners and a stroked
+// border, and places a label inside it that is clipped so it never
+// extends beyond the box.
+package box
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// Box describes the look of a positioned box. All sizes are in
+// pixels, so that boxes land exactly where they are asked to.
+type Box struct {
+	// Size is the width and height of the box.
+	Size image.Point
+	// Fill is the background color. The zero value leaves the
+	// inside of the box unpainted.
+	Fill color.RGBA
+
+	// StrokeWidth is the width of the border, drawn inside the box
+	// edge. Zero means no border.
+	StrokeWidth int
+	// Stroke is the border color.
+	Stroke color.RGBA
+	// CornerRadius rounds the corners of both the fill and the
+	// border.
+	CornerRadius int
+
+	// Padding is the space between the border and the label.
+	Padding int
+	// TextSize is the label size. Zero means the theme's TextSize.
+	TextSize unit.Value
+	// TextColor is the label color. The zero value means the
+	// theme's text color.
+	TextColor color.RGBA
+	// Alignment is the horizontal alignment of the label lines.
+	Alignment text.Alignment
+	// Wrap breaks label lines that are wider than the box. Without
+	// it, lines only break at '\n'.
+	Wrap bool
+	// MaxLines limits the number of label lines. Zero means as
+	// many as are given.
+	MaxLines int
+	// Ellipsis ends the label with "…" where text overflows the
+	// box or MaxLines, instead of cutting it off at the edge.
+	Ellipsis bool
+}
+
+const ellipsis = "…"
+
+// inf is the width available to labels that don't wrap.
+const inf = 1e6
+
+// Layout draws the box with its top-left corner at pos, followed
+// by the label. It returns the bounds of the box, in the coordinate
+// system of the current transform, for hit testing.
+func (b *Box) Layout(gtx *layout.Context, th *material.Theme, pos image.Point, label string) image.Rectangle {
+	var stack op.StackOp
+	stack.Push(gtx.Ops)
+	op.TransformOp{}.Offset(toPointF(pos)).Add(gtx.Ops)
+	b.paintFill(gtx.Ops)
+	b.paintStroke(gtx.Ops)
+	b.layoutLabel(gtx, th, label)
+	stack.Pop()
+	return image.Rectangle{Min: pos, Max: pos.Add(b.Size)}
+}
+
+// Inner returns the area left for the label, relative to the
+// top-left corner of the box.
+func (b *Box) Inner() image.Rectangle {
+	inset := b.StrokeWidth + b.Padding
+	return image.Rectangle{
+		Min: image.Point{X: inset, Y: inset},
+		Max: b.Size.Sub(image.Point{X: inset, Y: inset}),
+	}
+}
+
+func (b *Box) paintFill(ops *op.Ops) {
+	if b.Fill == (color.RGBA{}) {
+		return
+	}
+	r := f32.Rectangle{Max: toPointF(b.Size)}
+	rr := b.radius()
+	if rr == 0 {
+		paint.ColorOp{Color: b.Fill}.Add(ops)
+		paint.PaintOp{Rect: r}.Add(ops)
+		return
+	}
+	var stack op.StackOp
+	stack.Push(ops)
+	clip.Rect{Rect: r, SE: rr, SW: rr, NW: rr, NE: rr}.Op(ops).Add(ops)
+	paint.ColorOp{Color: b.Fill}.Add(ops)
+	paint.PaintOp{Rect: r}.Add(ops)
+	stack.Pop()
+}
+
+// paintStroke paints the border as the ring between the box outline
+// and the outline inset by StrokeWidth. The clip path uses the
+// even-odd rule, so two nested contours are all it takes.
+func (b *Box) paintStroke(ops *op.Ops) {
+	if b.StrokeWidth <= 0 {
+		return
+	}
+	sw := float32(b.StrokeWidth)
+	rr := b.radius()
+	outer := f32.Rectangle{Max: toPointF(b.Size)}
+	inner := f32.Rectangle{
+		Min: f32.Point{X: sw, Y: sw},
+		Max: outer.Max.Sub(f32.Point{X: sw, Y: sw}),
+	}
+	var stack op.StackOp
+	stack.Push(ops)
+	var p clip.Path
+	p.Begin(ops)
+	pen := roundRectContour(&p, f32.Point{}, outer, rr)
+	if !inner.Empty() {
+		irr := rr - sw
+		if irr < 0 {
+			irr = 0
+		}
+		roundRectContour(&p, pen, inner, irr)
+	}
+	p.End().Add(ops)
+	paint.ColorOp{Color: b.Stroke}.Add(ops)
+	paint.PaintOp{Rect: outer}.Add(ops)
+	stack.Pop()
+}
+
+// radius returns the corner radius, limited to half the shortest
+// side of the box.
+func (b *Box) radius() float32 {
+	rr := b.CornerRadius
+	if m := b.Size.X / 2; rr > m {
+		rr = m
+	}
+	if m := b.Size.Y / 2; rr > m {
+		rr = m
+	}
+	if rr < 0 {
+		rr = 0
+	}
+	return float32(rr)
+}
+
+// layoutLabel lays out the label in the inner area of the box.
+//
+// The offset and the clip are pushed on the stack together, so the
+// clip is an "in-place" clip at the (0,0) origin, relative to the
+// label position rather than to the screen. The StackOp undoes both
+// afterwards. As in the original drawc, the clip reaches to the box
+// edge, or the inside of its border, rather than stopping at the
+// padding: a label that overflows is cut where the box ends.
+func (b *Box) layoutLabel(gtx *layout.Context, th *material.Theme, label string) {
+	inner := b.Inner()
+	if inner.Empty() || label == "" {
+		return
+	}
+	var stack op.StackOp
+	stack.Push(gtx.Ops)
+	op.TransformOp{}.Offset(toPointF(inner.Min)).Add(gtx.Ops)
+	edge := b.Size.Sub(image.Point{X: b.StrokeWidth, Y: b.StrokeWidth})
+	clip.Rect{Rect: f32.Rectangle{Max: toPointF(edge.Sub(inner.Min))}}.Op(gtx.Ops).Add(gtx.Ops)
+
+	size := b.TextSize
+	if size.V == 0 {
+		size = th.TextSize
+	}
+	lbl := th.Label(size, label)
+	if b.TextColor != (color.RGBA{}) {
+		lbl.Color = b.TextColor
+	}
+	lbl.Alignment = b.Alignment
+	lbl.MaxLines = b.MaxLines
+	maxWidth := int(inf)
+	if b.Wrap {
+		maxWidth = inner.Dx()
+	}
+	if b.Ellipsis {
+		lbl.Text = b.ellipsize(gtx, th.Shaper, lbl.Font, label, inner.Size(), maxWidth)
+	}
+
+	saved := gtx.Constraints
+	gtx.Constraints = layout.Constraints{
+		Width:  layout.Constraint{Min: inner.Dx(), Max: maxWidth},
+		Height: layout.Constraint{Max: inner.Dy()},
+	}
+	lbl.Layout(gtx)
+	gtx.Constraints = saved
+	stack.Pop()
+
+	// Elias comments:
+	//
+	//The StackOp is for undoing the effect of the transformation.
+	//
+	//	If you want rectangular clipping in general, use gioui.org/op/paint.RectClip (as above).
+	//	If you want path based clipping, use paint.PathBuilder.
+	//	Clip operations are also undone by the StackOps.
+	//
+	//	Note that text.Label already clips itself to honor the constraints set
+	// 	in gtx.Constraints.
+}
+
+// ellipsize returns the lines of txt that fit in size, joined by
+// '\n'. A line that is too wide, and the last line when lines were
+// dropped, are shortened to end in an ellipsis.
+func (b *Box) ellipsize(gtx *layout.Context, s *text.Shaper, font text.Font, txt string, size image.Point, maxWidth int) string {
+	lines := s.Layout(gtx, font, txt, text.LayoutOptions{MaxWidth: maxWidth}).Lines
+	n := 0
+	height := 0
+	for _, l := range lines {
+		height += (l.Ascent + l.Descent).Ceil()
+		if n > 0 && height > size.Y {
+			break
+		}
+		n++
+	}
+	if b.MaxLines > 0 && n > b.MaxLines {
+		n = b.MaxLines
+	}
+	ell := s.Layout(gtx, font, ellipsis, text.LayoutOptions{MaxWidth: inf}).Lines[0].Width
+	// Widths are in 26.6 fixed point.
+	avail := size.X*64 - int(ell)
+	out := make([]string, n)
+	for i, l := range lines[:n] {
+		str := strings.TrimSuffix(l.Text.String, "\n")
+		if l.Width.Ceil() <= size.X && (i < n-1 || n == len(lines)) {
+			out[i] = str
+			continue
+		}
+		// Keep the runes that fit alongside the ellipsis.
+		w, end, k := 0, 0, 0
+		for j, r := range str {
+			adv := int(l.Text.Advances[k])
+			k++
+			if w+adv > avail {
+				break
+			}
+			w += adv
+			end = j + utf8.RuneLen(r)
+		}
+		out[i] = strings.TrimRight(str[:end], " ") + ellipsis
+	}
+	return strings.Join(out, "\n")
+}
+
+func toPointF(p image.Point) f32.Point {
+	return f32.Point{X: float32(p.X), Y: float32(p.Y)}
+}
+
+// roundRectContour adds a closed, clockwise contour outlining r with
+// corner radius rr to p, and returns the new pen position. The Path
+// methods take coordinates relative to the pen, which is at pen on
+// entry.
+func roundRectContour(p *clip.Path, pen f32.Point, r f32.Rectangle, rr float32) f32.Point {
+	// https://pomax.github.io/bezierinfo/#circles_cubic.
+	const c = 0.55228475 // 4*(sqrt(2)-1)/3
+	w, h := r.Dx()-2*rr, r.Dy()-2*rr
+	start := f32.Point{X: r.Min.X + rr, Y: r.Min.Y}
+	p.Move(start.Sub(pen))
+	p.Line(f32.Point{X: w})
+	if rr > 0 {
+		p.Cube(f32.Point{X: rr * c}, f32.Point{X: rr, Y: rr - rr*c}, f32.Point{X: rr, Y: rr}) // NE
+	}
+	p.Line(f32.Point{Y: h})
+	if rr > 0 {
+		p.Cube(f32.Point{Y: rr * c}, f32.Point{X: -rr + rr*c, Y: rr}, f32.Point{X: -rr, Y: rr}) // SE
+	}
+	p.Line(f32.Point{X: -w})
+	if rr > 0 {
+		p.Cube(f32.Point{X: -rr * c}, f32.Point{X: -rr, Y: -rr + rr*c}, f32.Point{X: -rr, Y: -rr}) // SW
+	}
+	p.Line(f32.Point{Y: -h})
+	if rr > 0 {
+		p.Cube(f32.Point{Y: -rr * c}, f32.Point{X: rr - rr*c, Y: -rr}, f32.Point{X: rr, Y: -rr}) // NW
+	}
+	return start
+}
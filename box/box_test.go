@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package box
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gioui.org/font/gofont"
+	"gioui.org/layout"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/raster"
+)
+
+// config is a system.Config at one pixel per dp.
+type config struct{}
+
+func (config) Now() time.Time {
+	return time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (config) Px(v unit.Value) int {
+	return int(v.V + .5)
+}
+
+var registerFonts sync.Once
+
+func newContext(size image.Point) (*layout.Context, *material.Theme) {
+	registerFonts.Do(gofont.Register)
+	gtx := layout.NewContext(nil)
+	gtx.Reset(config{}, size)
+	return gtx, material.NewTheme()
+}
+
+var font = text.Font{Size: unit.Px(16)}
+
+// width returns the width in pixels of str on one line.
+func width(gtx *layout.Context, th *material.Theme, str string) int {
+	return th.Shaper.Layout(gtx, font, str, text.LayoutOptions{MaxWidth: inf}).Lines[0].Width.Ceil()
+}
+
+func TestInner(t *testing.T) {
+	for _, tc := range []struct {
+		b    Box
+		want image.Rectangle
+	}{
+		{Box{Size: image.Point{X: 50, Y: 40}}, image.Rect(0, 0, 50, 40)},
+		{Box{Size: image.Point{X: 50, Y: 40}, Padding: 5}, image.Rect(5, 5, 45, 35)},
+		{Box{Size: image.Point{X: 50, Y: 40}, Padding: 3, StrokeWidth: 2}, image.Rect(5, 5, 45, 35)},
+	} {
+		if got := tc.b.Inner(); got != tc.want {
+			t.Errorf("inner of %+v is %v, want %v", tc.b, got, tc.want)
+		}
+	}
+	b := Box{Size: image.Point{X: 10, Y: 40}, Padding: 5}
+	if !b.Inner().Empty() {
+		t.Errorf("inner of %+v is %v, want empty", b, b.Inner())
+	}
+}
+
+func TestRadius(t *testing.T) {
+	for _, tc := range []struct {
+		size   image.Point
+		radius int
+		want   float32
+	}{
+		{image.Point{X: 50, Y: 40}, 0, 0},
+		{image.Point{X: 50, Y: 40}, 4, 4},
+		// At most half the shortest side.
+		{image.Point{X: 50, Y: 40}, 100, 20},
+		{image.Point{X: 30, Y: 40}, 100, 15},
+		{image.Point{X: 50, Y: 40}, -3, 0},
+	} {
+		b := Box{Size: tc.size, CornerRadius: tc.radius}
+		if got := b.radius(); got != tc.want {
+			t.Errorf("radius %d of a %v box is %v, want %v", tc.radius, tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestEllipsize(t *testing.T) {
+	gtx, th := newContext(image.Point{X: 400, Y: 300})
+	const long = "The quick brown fox jumps over the lazy dog"
+	line := th.Shaper.Layout(gtx, font, "Xy", text.LayoutOptions{MaxWidth: inf}).Lines[0]
+	lineHeight := (line.Ascent + line.Descent).Ceil()
+
+	b := Box{}
+	size := image.Point{X: 100, Y: 3 * lineHeight}
+	if got := b.ellipsize(gtx, th.Shaper, font, "fox", size, inf); got != "fox" {
+		t.Errorf("a label that fits became %q", got)
+	}
+
+	// A line too wide is cut to fit, ellipsis and all.
+	got := b.ellipsize(gtx, th.Shaper, font, long, size, inf)
+	if !strings.HasSuffix(got, ellipsis) || strings.Contains(got, "\n") {
+		t.Fatalf("got %q, want one line ending in %q", got, ellipsis)
+	}
+	if w := width(gtx, th, got); w > size.X {
+		t.Errorf("%q is %d wide, more than %d", got, w, size.X)
+	}
+	if !strings.HasPrefix(long, strings.TrimSuffix(got, ellipsis)) {
+		t.Errorf("%q is not the start of %q", got, long)
+	}
+
+	// Lines past the height are dropped, and the last line kept
+	// says so.
+	got = b.ellipsize(gtx, th.Shaper, font, "one\ntwo\nthree\nfour", size, inf)
+	if want := "one\ntwo\nthree…"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	b.MaxLines = 2
+	got = b.ellipsize(gtx, th.Shaper, font, "one\ntwo\nthree", size, inf)
+	if want := "one\ntwo…"; got != want {
+		t.Errorf("with MaxLines 2, got %q, want %q", got, want)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	gtx, th := newContext(image.Point{X: 400, Y: 300})
+	const long = "The quick brown fox jumps over the lazy dog"
+	b := Box{Wrap: true, Ellipsis: true}
+	size := image.Point{X: 100, Y: 300}
+	got := b.ellipsize(gtx, th.Shaper, font, long, size, size.X)
+	lines := strings.Split(got, "\n")
+	if len(lines) < 3 || strings.Contains(got, ellipsis) {
+		t.Fatalf("got %q, want the whole label over several lines", got)
+	}
+	for _, l := range lines {
+		if w := width(gtx, th, l); w > size.X {
+			t.Errorf("line %q is %d wide, more than %d", l, w, size.X)
+		}
+	}
+	if words := strings.Join(strings.Fields(got), " "); words != long {
+		t.Errorf("the lines hold %q, want %q", words, long)
+	}
+}
+
+// ink returns the span of the columns of r in which img has pixels
+// other than fill.
+func ink(img *image.RGBA, r image.Rectangle, fill color.RGBA) (min, max int) {
+	min, max = r.Max.X, r.Min.X
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.RGBAAt(x, y) != fill {
+				if x < min {
+					min = x
+				}
+				if x >= max {
+					max = x + 1
+				}
+			}
+		}
+	}
+	return min, max
+}
+
+// A label that overflows is cut at the box edge, and one that wraps
+// stays inside the padding.
+func TestLabelClip(t *testing.T) {
+	fill := color.RGBA{R: 0xee, G: 0xee, B: 0x88, A: 0xff}
+	for _, wrap := range []bool{false, true} {
+		gtx, th := newContext(image.Point{X: 200, Y: 100})
+		b := Box{
+			Size:     image.Point{X: 100, Y: 80},
+			Fill:     fill,
+			Padding:  6,
+			TextSize: unit.Px(16),
+			Wrap:     wrap,
+		}
+		pos := image.Point{X: 10, Y: 10}
+		b.Layout(gtx, th, pos, "The quick brown fox jumps over the lazy dog")
+		img := raster.Render(gtx.Ops, image.Point{X: 200, Y: 100})
+		r := image.Rectangle{Min: pos, Max: pos.Add(b.Size)}
+		if min, max := ink(img, image.Rect(r.Max.X, r.Min.Y, 200, r.Max.Y), color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}); min < max {
+			t.Errorf("wrap %t: the label is drawn right of the box, in columns %d-%d", wrap, min, max)
+		}
+		_, max := ink(img, r, fill)
+		inner := b.Inner().Add(pos)
+		if max <= inner.Min.X {
+			t.Fatalf("wrap %t: no label", wrap)
+		}
+		if wrap && max > inner.Max.X {
+			t.Errorf("wrap %t: the label reaches column %d, past the padding at %d", wrap, max, inner.Max.X)
+		}
+		if !wrap && max <= inner.Max.X {
+			t.Errorf("wrap %t: the label stops at column %d, short of the box edge at %d", wrap, max, r.Max.X)
+		}
+	}
+}
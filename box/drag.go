@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package box
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+)
+
+// Drag lets the pointer press a box and drag it to a new position.
+// Like gesture.Click, a Drag must be kept across frames; its address
+// is the key of its pointer handler.
+type Drag struct {
+	// Pos is the top-left corner of the box, in the coordinates of
+	// the transform that was current when Add was called.
+	Pos image.Point
+
+	pressed bool
+	pid     pointer.ID
+	// grab is the pointer position relative to Pos when pressed.
+	grab f32.Point
+}
+
+// Add registers the pointer handler of d over the area r. Add it
+// right after the box is painted, so that the handlers of boxes
+// painted on top of it take precedence.
+func (d *Drag) Add(ops *op.Ops, r image.Rectangle) {
+	var stack op.StackOp
+	stack.Push(ops)
+	pointer.Rect(r).Add(ops)
+	// Keep the pointer for the whole drag, even when it moves
+	// over other handlers.
+	pointer.InputOp{Key: d, Grab: d.pressed}.Add(ops)
+	stack.Pop()
+}
+
+// Update processes the pointer events for d, moving Pos along with
+// a dragging pointer. It reports whether the box was pressed since
+// the last call.
+func (d *Drag) Update(q event.Queue) (pressed bool) {
+	for _, evt := range q.Events(d) {
+		e, ok := evt.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Press:
+			if d.pressed || !e.Hit {
+				break
+			}
+			if e.Source == pointer.Mouse && e.Buttons != pointer.ButtonLeft {
+				break
+			}
+			d.pressed = true
+			d.pid = e.PointerID
+			d.grab = e.Position.Sub(toPointF(d.Pos))
+			pressed = true
+		case pointer.Move:
+			if !d.pressed || e.PointerID != d.pid {
+				break
+			}
+			p := e.Position.Sub(d.grab)
+			d.Pos = image.Point{
+				X: int(math.Round(float64(p.X))),
+				Y: int(math.Round(float64(p.Y))),
+			}
+		case pointer.Release:
+			if e.PointerID == d.pid {
+				d.pressed = false
+			}
+		case pointer.Cancel:
+			d.pressed = false
+		}
+	}
+	return pressed
+}
+
+// Dragging reports whether a pointer is holding the box.
+func (d *Drag) Dragging() bool {
+	return d.pressed
+}
@@ -0,0 +1,443 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// The command line: hello_gio [command] [flags] [args]. Every
+// command parses its own flags, so that "hello_gio view -h" lists
+// only what view understands.
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/unit"
+
+	"github.com/glycerine/hello_gio.go/scene"
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+// Exit codes.
+const (
+	exitOK = 0
+	// exitError means the command failed, for instance because a
+	// file could not be loaded.
+	exitError = 1
+	// exitUsage means the command line was wrong.
+	exitUsage = 2
+)
+
+// defaultRenderSize is the size of the image render draws, unless
+// -size says otherwise.
+var defaultRenderSize = image.Point{X: 1400, Y: 900}
+
+// options are the parsed command line.
+type options struct {
+	command string
+	// title and size are the window title and size, in dp. A zero
+	// size leaves the choice to the platform.
+	title string
+	size  image.Point
+	// bg is the background mode: "scene" for the scene's own
+	// background, "none", or a color.
+	bg string
+	// scene is the scene file of demo and render.
+	scene string
+	// scenes, if set, are the scene files render draws in place of
+	// scene, one page each in a PDF.
+	scenes []string
+	// image, if set, replaces the path of the scene's first image.
+	image string
+	// data, x and y are the table to plot and its columns.
+	data, x, y string
+	// out is the PNG, SVG, PDF, text or op list file render writes.
+	out string
+	// files are the images or directories to view.
+	files []string
+	// loops, if not zero, is how many times view plays animated
+	// images; negative means forever.
+	loops int
+	// shots is the directory the window commands save frames in.
+	shots string
+
+	verbose, veryVerbose bool
+	// log and logFormat are the log levels, as for vlog.ParseLevels,
+	// and the log format, text or json. logTZ, logTime and logClock
+	// are the time zone, layout and clock of log times.
+	log, logFormat           string
+	logTZ, logTime, logClock string
+}
+
+// commands are the subcommands, in the order usage lists them.
+var commands = []struct {
+	name, args, summary string
+}{
+	{"demo", "", "draw a scene file: images, plots and boxes (the default)"},
+	{"view", "file|dir...", "show image files one at a time"},
+	{"plot", "file", "plot columns of a CSV or TSV file"},
+	{"render", "[scene...]", "draw scenes, saved frames or a data file into a file, without a window"},
+	{"help", "[command]", "print this help, or the flags of a command"},
+}
+
+// usageError is a mistake on the command line.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, a ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+// usage writes the list of commands to w.
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: hello_gio [command] [flags] [args]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-7s %-12s %s\n", c.name, c.args, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'hello_gio help <command>' for the flags of a command.\n")
+}
+
+// parseArgs parses the command line, without the program name.
+// Help goes to stdout. It returns flag.ErrHelp if help was asked
+// for, and a *usageError if the command line is wrong.
+func parseArgs(args []string, stdout io.Writer) (*options, error) {
+	o := &options{command: "demo"}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		o.command = args[0]
+		args = args[1:]
+	}
+	if o.command == "help" {
+		if len(args) == 0 {
+			usage(stdout)
+			return nil, flag.ErrHelp
+		}
+		o.command = args[0]
+		args = []string{"-h"}
+	}
+	fs, err := o.flagSet()
+	if err != nil {
+		return nil, err
+	}
+	// The flag package prints its errors along with the flags;
+	// we report them ourselves, more briefly.
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			fs.SetOutput(stdout)
+			fs.Usage()
+			return nil, err
+		}
+		return nil, usagef("%s: %v", o.command, err)
+	}
+	if err := o.check(fs.Args()); err != nil {
+		return nil, err
+	}
+	if o.veryVerbose {
+		o.verbose = true
+	}
+	return o, nil
+}
+
+// flagSet returns the flags of o.command, bound to the fields of o.
+func (o *options) flagSet() (*flag.FlagSet, error) {
+	fs := flag.NewFlagSet("hello_gio "+o.command, flag.ContinueOnError)
+	var args string
+	for _, c := range commands {
+		if c.name == o.command {
+			args = c.args
+		}
+	}
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), strings.TrimSpace("usage: hello_gio "+o.command+" [flags] "+args))
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&o.verbose, "v", false, "print debug output")
+	fs.BoolVar(&o.veryVerbose, "vv", false, "print even more debug output")
+	fs.StringVar(&o.log, "log", "", "log `levels`, as in info,reload=debug,tiled=trace")
+	fs.StringVar(&o.logFormat, "log-format", "", "log `format`: text or json")
+	fs.StringVar(&o.logTZ, "log-tz", "", "time `zone` of log times, as in UTC or Local (default America/New_York)")
+	fs.StringVar(&o.logTime, "log-time", "", "`layout` of log times: a Go time layout, or default, rfc3339, clock or none")
+	fs.StringVar(&o.logClock, "log-clock", "", "`clock` of log times: real, or a fixed RFC 3339 time")
+	window := func() {
+		fs.StringVar(&o.title, "title", "hello_gio", "window `title`")
+		fs.Var((*sizeFlag)(&o.size), "size", "window size in dp, as `WxH`")
+		fs.StringVar(&o.shots, "shots", ".", "`directory` to save frames in, on Ctrl+S")
+	}
+	sceneFlags := func() {
+		fs.StringVar(&o.scene, "scene", "scene.json", "JSON scene `file` to draw")
+		fs.StringVar(&o.bg, "bg", "scene", "background: scene, none, or a `color` name or #rrggbb")
+		fs.StringVar(&o.image, "image", "", "image `file` to draw in place of the scene's first image")
+	}
+	dataFlags := func() {
+		fs.StringVar(&o.x, "x", "", "data column to plot along x, by `name` or number")
+		fs.StringVar(&o.y, "y", "", "data column to plot along y, by `name` or number")
+	}
+	switch o.command {
+	case "demo":
+		window()
+		sceneFlags()
+	case "view":
+		window()
+		fs.IntVar(&o.loops, "loops", 0, "times to play animated images: 0 as the file says, -1 forever")
+	case "plot":
+		window()
+		dataFlags()
+	case "render":
+		o.size = defaultRenderSize
+		fs.Var((*sizeFlag)(&o.size), "size", "image size in pixels, as `WxH`")
+		fs.StringVar(&o.out, "o", "hello_gio.png", "PNG, SVG, PDF, .txt op tree or .ops op list `file` to write, by its extension")
+		sceneFlags()
+		fs.StringVar(&o.data, "data", "", "CSV or TSV `file` to plot instead of a scene")
+		dataFlags()
+	default:
+		return nil, usagef("unknown command %q", o.command)
+	}
+	return fs, nil
+}
+
+// check validates the arguments left after the flags.
+func (o *options) check(args []string) error {
+	switch o.command {
+	case "view":
+		if len(args) == 0 {
+			return usagef("view: no image files or directories given")
+		}
+		o.files = args
+		return nil
+	case "plot":
+		if len(args) != 1 {
+			return usagef("plot: want one data file, got %d", len(args))
+		}
+		o.data = args[0]
+		return nil
+	case "render":
+		if len(args) > 0 && o.data != "" {
+			return usagef("render: scene files and -data don't go together")
+		}
+		if len(args) > 1 && !isPDF(o.out) {
+			return usagef("render: only a PDF holds more than one scene")
+		}
+		if len(args) > 0 {
+			o.scenes = args
+			args = nil
+		}
+	}
+	if len(args) > 0 {
+		return usagef("%s: unexpected arguments: %s", o.command, strings.Join(args, " "))
+	}
+	if _, err := vlog.ParseLevels(o.log); err != nil {
+		return usagef("-log: %v", err)
+	}
+	if _, err := parseLogFormat(o.logFormat); err != nil {
+		return usagef("-log-format: %v", err)
+	}
+	if _, err := parseLogZone(o.logTZ); err != nil {
+		return usagef("-log-tz: %v", err)
+	}
+	if _, err := parseLogTime(o.logTime); err != nil {
+		return usagef("-log-time: %v", err)
+	}
+	if _, err := parseLogClock(o.logClock); err != nil {
+		return usagef("-log-clock: %v", err)
+	}
+	switch o.bg {
+	case "", "scene", "none":
+	default:
+		if _, err := scene.ParseColor(o.bg); err != nil {
+			return usagef("-bg: %v", err)
+		}
+	}
+	if o.command == "render" && (o.size.X <= 0 || o.size.Y <= 0) {
+		return usagef("render: -size must be positive")
+	}
+	return nil
+}
+
+// Environment variables that set up logging, under the flags:
+// -v, -vv and -log for the levels, and -log-format, -log-tz,
+// -log-time and -log-clock.
+const (
+	logEnv       = "HELLO_GIO_LOG"
+	logFormatEnv = "HELLO_GIO_LOG_FORMAT"
+	logTZEnv     = "HELLO_GIO_LOG_TZ"
+	logTimeEnv   = "HELLO_GIO_LOG_TIME"
+	logClockEnv  = "HELLO_GIO_LOG_CLOCK"
+)
+
+// setupLogging sets up logging from the environment, as read by
+// getenv, and then from the flags: -v and -vv set the default level
+// to debug and trace, -log adds to the levels, and the other flags
+// win over their environment variables.
+func (o *options) setupLogging(getenv func(string) string) error {
+	spec := []string{getenv(logEnv)}
+	switch {
+	case o.veryVerbose:
+		spec = append(spec, "trace")
+	case o.verbose:
+		spec = append(spec, "debug")
+	}
+	ls, err := vlog.ParseLevels(strings.Join(append(spec, o.log), ","))
+	if err != nil {
+		return fmt.Errorf("%s: %v", logEnv, err)
+	}
+	// The flags were checked by parseArgs, so a bad setting comes
+	// from the environment.
+	setting := func(flag, env string) string {
+		if flag != "" {
+			return flag
+		}
+		return getenv(env)
+	}
+	format, err := parseLogFormat(setting(o.logFormat, logFormatEnv))
+	if err != nil {
+		return fmt.Errorf("%s: %v", logFormatEnv, err)
+	}
+	loc, err := parseLogZone(setting(o.logTZ, logTZEnv))
+	if err != nil {
+		return fmt.Errorf("%s: %v", logTZEnv, err)
+	}
+	layout, err := parseLogTime(setting(o.logTime, logTimeEnv))
+	if err != nil {
+		return fmt.Errorf("%s: %v", logTimeEnv, err)
+	}
+	clock, err := parseLogClock(setting(o.logClock, logClockEnv))
+	if err != nil {
+		return fmt.Errorf("%s: %v", logClockEnv, err)
+	}
+	vlog.SetLevels(ls)
+	vlog.SetFormat(format)
+	vlog.SetLocation(loc)
+	vlog.SetTimeFormat(layout)
+	vlog.SetClock(clock)
+	return nil
+}
+
+// parseLogFormat parses the log format s, text by default.
+func parseLogFormat(s string) (vlog.Format, error) {
+	if s == "" {
+		return vlog.Text, nil
+	}
+	return vlog.ParseFormat(s)
+}
+
+// parseLogZone loads the time zone of log times, NYC by default.
+func parseLogZone(s string) (*time.Location, error) {
+	if s == "" {
+		return NYC, nil
+	}
+	return loadLocation(s)
+}
+
+// logTimeLayouts are the names -log-time takes besides layouts.
+var logTimeLayouts = map[string]string{
+	"default": vlog.TimeFormat,
+	"rfc3339": time.RFC3339Nano,
+	"clock":   "15:04:05.000",
+	"none":    "",
+}
+
+// parseLogTime returns the layout of log times that s names or is.
+func parseLogTime(s string) (string, error) {
+	if s == "" {
+		return vlog.TimeFormat, nil
+	}
+	if layout, ok := logTimeLayouts[s]; ok {
+		return layout, nil
+	}
+	// A layout with no field of the time in it is a mistake: it
+	// formats two times that differ in every field the same.
+	t0 := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	t1 := time.Date(2019, 10, 23, 22, 38, 47, 123456789, time.FixedZone("", 5*3600+30*60))
+	if t0.Format(s) == t1.Format(s) {
+		return "", fmt.Errorf("%q is not a time layout, as in 15:04:05, nor one of default, rfc3339, clock or none", s)
+	}
+	return s, nil
+}
+
+// parseLogClock returns the clock of log times: "real", the default,
+// or a clock that stands still at an RFC 3339 time, for output that
+// is the same every run.
+func parseLogClock(s string) (func() time.Time, error) {
+	if s == "" || s == "real" {
+		return time.Now, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, fmt.Errorf("want real or a time such as 2019-10-01T12:00:00Z, got %q", s)
+	}
+	return func() time.Time { return t }, nil
+}
+
+// windowOptions returns the app options for the window title and
+// size.
+func (o *options) windowOptions() []app.Option {
+	opts := []app.Option{app.Title(o.title)}
+	if o.size.X > 0 && o.size.Y > 0 {
+		opts = append(opts, app.Size(unit.Dp(float32(o.size.X)), unit.Dp(float32(o.size.Y))))
+	}
+	return opts
+}
+
+// loadScene loads the scene file and applies -bg and -image.
+func (o *options) loadScene() (*scene.Scene, error) {
+	sc, err := scene.Load(o.scene)
+	if err != nil {
+		return nil, err
+	}
+	switch o.bg {
+	case "", "scene":
+	case "none":
+		sc.Background = ""
+	default:
+		sc.Background = o.bg
+	}
+	if o.image != "" {
+		// Scene paths are relative to the scene file, but -image
+		// is relative to where we run.
+		path, err := filepath.Abs(o.image)
+		if err != nil {
+			return nil, err
+		}
+		if len(sc.Images) == 0 {
+			sc.Images = append(sc.Images, scene.Image{Place: scene.PlaceFit})
+		}
+		sc.Images[0].Path = path
+	}
+	return sc, nil
+}
+
+// sizeFlag is a flag.Value for sizes written as WxH.
+type sizeFlag image.Point
+
+func (s *sizeFlag) String() string {
+	if s.X == 0 && s.Y == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", s.X, s.Y)
+}
+
+func (s *sizeFlag) Set(v string) error {
+	i := strings.IndexAny(v, "xX")
+	if i < 0 {
+		return errors.New("want WxH, as in 800x600")
+	}
+	w, err1 := strconv.Atoi(v[:i])
+	h, err2 := strconv.Atoi(v[i+1:])
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return errors.New("want two positive numbers, as in 800x600")
+	}
+	*s = sizeFlag{X: w, Y: h}
+	return nil
+}
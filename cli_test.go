@@ -0,0 +1,374 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+func TestParseArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want options
+	}{
+		{nil, options{command: "demo", title: "hello_gio", shots: ".", scene: "scene.json", bg: "scene"}},
+		{
+			[]string{"-scene", "plot.json", "-bg", "none", "-size", "800x600", "-vv"},
+			options{command: "demo", title: "hello_gio", shots: ".", scene: "plot.json", bg: "none",
+				size: image.Point{X: 800, Y: 600}, verbose: true, veryVerbose: true},
+		},
+		{
+			[]string{"view", "-title", "pics", "-loops", "-1", "a.png", "dir"},
+			options{command: "view", title: "pics", shots: ".", files: []string{"a.png", "dir"}, loops: -1},
+		},
+		{
+			[]string{"plot", "-x", "time", "-log", "warn,reload=debug", "-log-format", "json", "weather.csv"},
+			options{command: "plot", title: "hello_gio", shots: ".", data: "weather.csv", x: "time",
+				log: "warn,reload=debug", logFormat: "json"},
+		},
+		{
+			[]string{"render", "-o", "out.png", "-bg", "#ffcc00"},
+			options{command: "render", scene: "scene.json", bg: "#ffcc00", out: "out.png", size: defaultRenderSize},
+		},
+		{
+			[]string{"render", "-o", "report.pdf", "a.json", "b.json"},
+			options{command: "render", scene: "scene.json", scenes: []string{"a.json", "b.json"}, bg: "scene",
+				out: "report.pdf", size: defaultRenderSize},
+		},
+	} {
+		got, err := parseArgs(tc.args, ioutil.Discard)
+		if err != nil {
+			t.Errorf("%q: %v", tc.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tc.want) {
+			t.Errorf("%q: got %+v, want %+v", tc.args, *got, tc.want)
+		}
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"draw"}, `unknown command "draw"`},
+		{[]string{"help", "draw"}, `unknown command "draw"`},
+		{[]string{"view"}, "view: no image files or directories given"},
+		{[]string{"plot", "a.csv", "b.csv"}, "plot: want one data file, got 2"},
+		{[]string{"demo", "extra"}, "demo: unexpected arguments: extra"},
+		{[]string{"-size", "big"}, `demo: invalid value "big" for flag -size: want WxH, as in 800x600`},
+		{[]string{"-bg", "mauve"}, `-bg: invalid color "mauve"`},
+		{[]string{"view", "-scene", "s.json", "a.png"}, "view: flag provided but not defined: -scene"},
+		{[]string{"render", "a.json", "b.json"}, "render: only a PDF holds more than one scene"},
+		{[]string{"render", "-data", "a.csv", "a.json"}, "render: scene files and -data don't go together"},
+		{[]string{"-log", "reload=loud"}, `-log: reload: unknown level "loud"`},
+		{[]string{"-log-format", "xml"}, `-log-format: unknown log format "xml"`},
+		{[]string{"-log-tz", "Mars/Olympus"}, `-log-tz: unknown time zone "Mars/Olympus"`},
+		{[]string{"-log-time", "soon"}, `-log-time: "soon" is not a time layout`},
+		{[]string{"-log-clock", "yesterday"}, `-log-clock: want real or a time`},
+	} {
+		_, err := parseArgs(tc.args, ioutil.Discard)
+		if _, ok := err.(*usageError); !ok {
+			t.Errorf("%q: got error %v, want a usage error", tc.args, err)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("%q: got error %q, want %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestParseArgsHelp(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"help"}, "usage: hello_gio [command] [flags] [args]"},
+		{[]string{"help", "view"}, "usage: hello_gio view [flags] file|dir..."},
+		{[]string{"render", "-h"}, "usage: hello_gio render [flags] [scene...]\n"},
+	} {
+		var out bytes.Buffer
+		_, err := parseArgs(tc.args, &out)
+		if err != flag.ErrHelp {
+			t.Errorf("%q: got error %v, want flag.ErrHelp", tc.args, err)
+		}
+		if !strings.HasPrefix(out.String(), tc.want) {
+			t.Errorf("%q: help starts with %q, want %q", tc.args, strings.SplitN(out.String(), "\n", 2)[0], tc.want)
+		}
+	}
+}
+
+func TestSetupLogging(t *testing.T) {
+	defer vlog.SetFormat(vlog.Text)
+	defer vlog.SetLevels(vlog.CurrentLevels())
+	for _, tc := range []struct {
+		args []string
+		env  map[string]string
+		want string
+		json bool
+	}{
+		{nil, nil, "info", false},
+		{[]string{"-v"}, nil, "debug", false},
+		{nil, map[string]string{logEnv: "warn,tiled=debug", logFormatEnv: "json"}, "warn,tiled=debug", true},
+		// The flags win over the environment.
+		{[]string{"-vv", "-log", "loader=error", "-log-format", "text"},
+			map[string]string{logEnv: "warn,tiled=debug", logFormatEnv: "json"},
+			"trace,loader=error,tiled=debug", false},
+	} {
+		o, err := parseArgs(tc.args, ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		getenv := func(k string) string { return tc.env[k] }
+		if err := o.setupLogging(getenv); err != nil {
+			t.Errorf("%q %v: %v", tc.args, tc.env, err)
+			continue
+		}
+		if got := vlog.CurrentLevels().String(); got != tc.want {
+			t.Errorf("%q %v: levels %q, want %q", tc.args, tc.env, got, tc.want)
+		}
+		var buf bytes.Buffer
+		vlog.SetOutput(&buf)
+		vlog.New("main").Error("hi")
+		vlog.SetOutput(ourStdout{})
+		if json := strings.HasPrefix(buf.String(), "{"); json != tc.json {
+			t.Errorf("%q %v: wrote %q", tc.args, tc.env, buf.String())
+		}
+	}
+
+	o := &options{}
+	err := o.setupLogging(func(k string) string { return map[string]string{logEnv: "shout"}[k] })
+	if err == nil || !strings.HasPrefix(err.Error(), logEnv+": ") {
+		t.Errorf("a bad %s gave error %v", logEnv, err)
+	}
+}
+
+func TestParseLogTime(t *testing.T) {
+	for _, s := range []string{"clock", "15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04:05.000", "Jan _2 15:04", "MST"} {
+		layout, err := parseLogTime(s)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+		}
+		if want, ok := logTimeLayouts[s]; ok && layout != want || !ok && layout != s {
+			t.Errorf("%q gave layout %q", s, layout)
+		}
+	}
+	for _, s := range []string{"soon", "hh:mm:ss"} {
+		if _, err := parseLogTime(s); err == nil {
+			t.Errorf("%q is taken as a layout", s)
+		}
+	}
+}
+
+func TestSetupLogTime(t *testing.T) {
+	defer vlog.SetClock(time.Now)
+	defer vlog.SetLocation(NYC)
+	defer vlog.SetTimeFormat(vlog.TimeFormat)
+	for _, tc := range []struct {
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{
+			[]string{"-log-tz", "America/New_York", "-log-clock", "2019-10-01T16:00:00.25Z"}, nil,
+			"2019-10-01 12:00:00.25 -0400 EDT INFO",
+		},
+		{
+			nil, map[string]string{logTZEnv: "UTC", logTimeEnv: "clock", logClockEnv: "2019-10-01T16:00:00Z"},
+			"16:00:00.000 INFO",
+		},
+		// The flags win over the environment.
+		{
+			[]string{"-log-tz", "Asia/Tokyo", "-log-time", "Jan _2 15:04"},
+			map[string]string{logTZEnv: "UTC", logTimeEnv: "none", logClockEnv: "2019-10-01T16:00:00Z"},
+			"Oct  2 01:00 INFO",
+		},
+		{nil, map[string]string{logTimeEnv: "none", logClockEnv: "2019-10-01T16:00:00Z"}, "INFO"},
+		// New York time by default.
+		{
+			[]string{"-log-clock", "2019-10-01T16:00:00Z"}, nil,
+			"2019-10-01 12:00:00 -0400 EDT INFO",
+		},
+	} {
+		o, err := parseArgs(tc.args, ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := o.setupLogging(func(k string) string { return tc.env[k] }); err != nil {
+			t.Errorf("%q %v: %v", tc.args, tc.env, err)
+			continue
+		}
+		var buf bytes.Buffer
+		vlog.SetOutput(&buf)
+		vlog.New("").Info("hi")
+		vlog.SetOutput(ourStdout{})
+		// Drop the caller.
+		got := strings.SplitN(buf.String(), " ", 2)[1]
+		if want := tc.want + " hi\n"; got != want {
+			t.Errorf("%q %v: wrote %q, want %q", tc.args, tc.env, got, want)
+		}
+	}
+
+	for env, v := range map[string]string{logTZEnv: "Mars/Olympus", logTimeEnv: "soon", logClockEnv: "yesterday"} {
+		err := (&options{}).setupLogging(func(k string) string { return map[string]string{env: v}[k] })
+		if err == nil || !strings.HasPrefix(err.Error(), env+": ") {
+			t.Errorf("a bad %s gave error %v", env, err)
+		}
+	}
+}
+
+// TestRender checks that the render command draws the same frame
+// as the window: the demo_yellow golden.
+func TestRender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "demo.png")
+	o, err := parseArgs([]string{"render", "-o", out, "-size", "1400x900"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := render(o); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "demo_yellow", loadRGBA(t, out))
+}
+
+// TestRenderSVG checks that render writes an SVG document when the
+// output file is named so, with the scene's image embedded.
+func TestRenderSVG(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "demo.svg")
+	o, err := parseArgs([]string{"render", "-o", out, "-size", "1400x900"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := render(o); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	elems := make(map[string]int)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			elems[se.Name.Local]++
+		}
+	}
+	for _, name := range []string{"svg", "rect", "clipPath", "path", "image", "use"} {
+		if elems[name] == 0 {
+			t.Errorf("no <%s> in %s", name, out)
+		}
+	}
+}
+
+// TestRenderOpTree checks that render writes the tree of the ops
+// of the frame for a .txt file.
+func TestRenderOpTree(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "demo.txt")
+	o, err := parseArgs([]string{"render", "-o", out, "-size", "1400x900"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := render(o); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\npush\n", "\n  transform ", "\n  clip ", "\npaint (0,0)-(1400,900)\n", "\n  image 1704x1116\n", "\n      call\n        macro\n          path "} {
+		if !bytes.Contains(tree, []byte(want)) {
+			t.Errorf("no %q in the tree", want)
+		}
+	}
+}
+
+// TestRenderPDF checks that render puts a page for each scene into
+// a PDF.
+func TestRenderPDF(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "report.pdf")
+	o, err := parseArgs([]string{"render", "-o", out, "-size", "800x600", "scene.json", "plot.json"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := render(o); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(doc, []byte("%PDF-")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Fatalf("%s is not a PDF", out)
+	}
+	if n := bytes.Count(doc, []byte("/Type /Page ")); n != 2 {
+		t.Errorf("%d pages, want 2", n)
+	}
+	if !bytes.Contains(doc, []byte("/MediaBox [0 0 600 450]")) {
+		t.Error("pages are not 800x600 pixels")
+	}
+	if !bytes.Contains(doc, []byte("/Subtype /Image")) {
+		t.Error("the scene's image is not embedded")
+	}
+
+	// A scene that fails to load leaves no half-written file.
+	bad := filepath.Join(dir, "bad.pdf")
+	if o, err = parseArgs([]string{"render", "-o", bad, "scene.json", "missing.json"}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := render(o); err == nil {
+		t.Fatal("no error for a missing scene")
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Errorf("%s was left behind: %v", bad, err)
+	}
+}
+
+// TestRenderTiled checks that an image drawn from tiles comes out as
+// it does drawn whole.
+func TestRenderTiled(t *testing.T) {
+	defer func(n int) { tileThreshold = n }(tileThreshold)
+	tileThreshold = 100
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "demo.png")
+	o, err := parseArgs([]string{"render", "-o", out, "-size", "1400x900"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := render(o); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "demo_yellow", loadRGBA(t, out))
+}
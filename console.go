@@ -0,0 +1,456 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// The log console. Everything written to OurStdout, which is where
+// the vlog records go, is also kept in a ring of recent lines, so
+// that the log can be read without a terminal: Ctrl+L (Cmd+L on a
+// Mac) opens a panel over the bottom of the window that lists them.
+// The panel filters the lines by level and by a search text, and
+// follows new lines as they come unless it is paused.
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+
+	"gioui.org/gesture"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/text"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+const (
+	// logRingLines is how many lines the console keeps.
+	logRingLines = 2000
+	// maxLogLine is the longest line kept, in bytes; the rest of a
+	// longer line is dropped.
+	maxLogLine = 4096
+)
+
+// logs keeps the recent lines of OurStdout for the console.
+var logs = newLogRing(logRingLines)
+
+// logLine is a line of the log, with the level of the record it is
+// part of.
+type logLine struct {
+	level vlog.Level
+	text  string
+}
+
+// logRing is an io.Writer that keeps the last lines written to it.
+type logRing struct {
+	mu sync.Mutex
+	// lines holds up to size lines. Once it is full, the oldest is
+	// at next, and is the next to be overwritten.
+	lines []logLine
+	size  int
+	next  int
+	// partial is the start of a line whose end has not been
+	// written yet.
+	partial []byte
+	// level is the level of the last record, for the lines after
+	// its first.
+	level vlog.Level
+	// changed, if set, is called after each Write, on the writing
+	// goroutine.
+	changed func()
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{size: size, level: vlog.Info}
+}
+
+// Write adds the lines of p. An unfinished line waits for the
+// Write that ends it.
+func (r *logRing) Write(p []byte) (int, error) {
+	n := len(p)
+	r.mu.Lock()
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			r.appendPartial(p)
+			break
+		}
+		r.appendPartial(p[:i])
+		r.add(string(r.partial))
+		r.partial = r.partial[:0]
+		p = p[i+1:]
+	}
+	changed := r.changed
+	r.mu.Unlock()
+	if changed != nil {
+		changed()
+	}
+	return n, nil
+}
+
+func (r *logRing) appendPartial(p []byte) {
+	if room := maxLogLine - len(r.partial); len(p) > room {
+		p = p[:room]
+	}
+	r.partial = append(r.partial, p...)
+}
+
+// add keeps the line s, dropping the oldest line if the ring is
+// full. Blank lines are skipped.
+func (r *logRing) add(s string) {
+	s = strings.TrimRight(s, "\r")
+	if strings.TrimSpace(s) == "" {
+		return
+	}
+	if l, ok := vlog.RecordLevel(s); ok {
+		r.level = l
+	}
+	line := logLine{level: r.level, text: s}
+	if len(r.lines) < r.size {
+		r.lines = append(r.lines, line)
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % r.size
+}
+
+// snapshot returns the lines kept, oldest first.
+func (r *logRing) snapshot() []logLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lines := make([]logLine, 0, len(r.lines))
+	lines = append(lines, r.lines[r.next:]...)
+	return append(lines, r.lines[:r.next]...)
+}
+
+// setChanged makes r call f after each Write; nil stops it.
+func (r *logRing) setChanged(f func()) {
+	r.mu.Lock()
+	r.changed = f
+	r.mu.Unlock()
+}
+
+// logConsole is the panel that lists the lines of a logRing.
+type logConsole struct {
+	ring *logRing
+	// changed is given to the ring while the panel is open and
+	// following it. Set it to w.Invalidate.
+	changed func()
+
+	open bool
+	// minLevel is the lowest level listed.
+	minLevel vlog.Level
+	// paused stops the list at frozen, the lines when it was
+	// paused, instead of following the ring.
+	paused bool
+	frozen []logLine
+	// searching is set while the search box has the key focus.
+	searching bool
+
+	search     widget.Editor
+	searchRect image.Rectangle
+	list       layout.List
+	levels     [vlog.Off]gesture.Click
+	pause      gesture.Click
+	// panel is the area of the panel, which takes all the pointer
+	// events over it.
+	panel image.Rectangle
+}
+
+func newLogConsole(ring *logRing, changed func()) *logConsole {
+	return &logConsole{
+		ring:    ring,
+		changed: changed,
+		search:  widget.Editor{SingleLine: true, Submit: true},
+		list:    layout.List{Axis: layout.Vertical, ScrollToEnd: true},
+	}
+}
+
+// The console styles give their sizes in dp, for dpBox.
+var (
+	consoleStyle = box.Box{
+		Fill: color.RGBA{16, 16, 16, 235},
+	}
+	consoleChipStyle = box.Box{
+		Size:         image.Point{X: 64, Y: 26},
+		Fill:         color.RGBA{60, 60, 60, 255},
+		CornerRadius: 4,
+		Padding:      4,
+		TextColor:    color.RGBA{220, 220, 220, 255},
+		Alignment:    text.Middle,
+		Ellipsis:     true,
+	}
+	// consoleChipOn is the fill of the chips of the levels listed.
+	consoleChipOn   = color.RGBA{60, 100, 170, 255}
+	consoleBoxStyle = box.Box{
+		Size:         image.Point{Y: 26},
+		Fill:         color.RGBA{255, 255, 255, 255},
+		CornerRadius: 4,
+		StrokeWidth:  1,
+		Stroke:       color.RGBA{150, 150, 150, 255},
+	}
+	consoleCountStyle = box.Box{
+		Size:      image.Point{X: 150, Y: 26},
+		Padding:   4,
+		TextColor: color.RGBA{170, 170, 170, 255},
+		Alignment: text.End,
+		Ellipsis:  true,
+	}
+	// consoleLineColors are the colors of the lines, by level.
+	consoleLineColors = [vlog.Off]color.RGBA{
+		vlog.Trace: {130, 130, 130, 255},
+		vlog.Debug: {180, 180, 180, 255},
+		vlog.Info:  {235, 235, 235, 255},
+		vlog.Warn:  {255, 200, 80, 255},
+		vlog.Error: {255, 110, 100, 255},
+	}
+)
+
+// The sizes of the panel, like those of the console styles, are in
+// dp.
+const (
+	// consolePad is the space around the parts of the panel.
+	consolePad = 8
+	// consoleMinHeight is the height of the panel in a small
+	// window; it otherwise takes the bottom 40%.
+	consoleMinHeight = 200
+	// consolePauseWidth is the width of the pause chip, and
+	// consoleChipGap the space between the level chips.
+	consolePauseWidth = 90
+	consoleChipGap    = 4
+)
+
+// Key handles the console keys: Ctrl+L (Cmd+L) opens and closes the
+// panel, and while it is open, Escape closes it and / moves to the
+// search box. It reports whether e was one of them.
+func (c *logConsole) Key(e key.Event) bool {
+	switch {
+	case e.Name == "L" && (e.Modifiers.Contain(key.ModCtrl) || e.Modifiers.Contain(key.ModCommand)):
+		c.setOpen(!c.open)
+	case !c.open:
+		return false
+	case e.Name == key.NameEscape:
+		c.setOpen(false)
+	case e.Name == "/":
+		c.searching = true
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *logConsole) setOpen(open bool) {
+	c.open = open
+	c.searching = false
+	c.follow()
+}
+
+// setPaused pauses or resumes following the ring. Resuming scrolls
+// to the newest line.
+func (c *logConsole) setPaused(paused bool) {
+	c.paused = paused
+	c.frozen = nil
+	if paused {
+		c.frozen = c.ring.snapshot()
+	} else {
+		c.list.Position.BeforeEnd = false
+	}
+	c.follow()
+}
+
+// follow has the ring ask for a frame on every new line, while the
+// new lines are shown.
+func (c *logConsole) follow() {
+	if c.open && !c.paused {
+		c.ring.setChanged(c.changed)
+	} else {
+		c.ring.setChanged(nil)
+	}
+}
+
+// lines returns the lines listed: those at minLevel or above that
+// contain the search text, in any case.
+func (c *logConsole) lines() []logLine {
+	all := c.frozen
+	if !c.paused {
+		all = c.ring.snapshot()
+	}
+	query := strings.ToLower(c.search.Text())
+	var lines []logLine
+	for _, l := range all {
+		if l.level < c.minLevel {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(l.text), query) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// update handles the clicks and the search box events since the last
+// frame.
+func (c *logConsole) update(gtx *layout.Context) {
+	for l := range c.levels {
+		for _, e := range c.levels[l].Events(gtx) {
+			if e.Type == gesture.TypeClick {
+				c.minLevel = vlog.Level(l)
+			}
+		}
+	}
+	for _, e := range c.pause.Events(gtx) {
+		if e.Type == gesture.TypeClick {
+			c.setPaused(!c.paused)
+		}
+	}
+	for _, e := range c.search.Events(gtx) {
+		if _, ok := e.(widget.SubmitEvent); ok {
+			c.searching = false
+		}
+	}
+	// A press in the search box starts a search, and a press
+	// anywhere else ends it.
+	for _, evt := range gtx.Events(c) {
+		if e, ok := evt.(pointer.Event); ok && e.Type == pointer.Press {
+			c.searching = inRect(e.Position, c.searchRect)
+		}
+	}
+}
+
+// Layout draws the panel, if it is open, over the bottom of a window
+// of the given size. Lay it out last: while the search box has the
+// key focus, it takes it anew every frame.
+func (c *logConsole) Layout(gtx *layout.Context, th *material.Theme, window image.Point) {
+	if !c.open {
+		return
+	}
+	c.update(gtx)
+	pad := dpPx(gtx, consolePad)
+	h := window.Y * 2 / 5
+	if minHeight := dpPx(gtx, consoleMinHeight); h < minHeight {
+		h = min(minHeight, window.Y)
+	}
+	c.panel = image.Rectangle{Min: image.Point{Y: window.Y - h}, Max: window}
+	ops := gtx.Ops
+
+	var stack op.StackOp
+	stack.Push(ops)
+	pointer.Rect(c.panel).Add(ops)
+	pointer.InputOp{Key: &c.panel}.Add(ops)
+	stack.Pop()
+	bg := consoleStyle
+	bg.Size = c.panel.Size()
+	bg.Layout(gtx, th, c.panel.Min, "")
+
+	lines := c.lines()
+	top := c.panel.Min.Y + pad
+	end := c.layoutHeader(gtx, th, top, len(lines))
+
+	listRect := image.Rectangle{
+		Min: image.Point{X: pad, Y: end + pad},
+		Max: c.panel.Max.Sub(image.Point{X: pad, Y: pad}),
+	}
+	if !listRect.Empty() {
+		c.layoutList(gtx, th, listRect, lines)
+	}
+
+	// Watch the whole window for presses, letting them through.
+	stack.Push(ops)
+	pointer.PassOp{Pass: true}.Add(ops)
+	pointer.Rect(image.Rectangle{Max: window}).Add(ops)
+	pointer.InputOp{Key: c}.Add(ops)
+	stack.Pop()
+}
+
+// layoutHeader draws the row of level chips, the pause chip, the
+// search box and the line count at top, and returns its bottom.
+func (c *logConsole) layoutHeader(gtx *layout.Context, th *material.Theme, top, n int) int {
+	pad := dpPx(gtx, consolePad)
+	chip := dpBox(gtx, consoleChipStyle)
+	chip.TextSize = th.TextSize.Scale(.75)
+	x := pad
+	for l := vlog.Trace; l < vlog.Off; l++ {
+		b := chip
+		if l >= c.minLevel {
+			b.Fill = consoleChipOn
+		}
+		r := b.Layout(gtx, th, image.Point{X: x, Y: top}, l.String())
+		addClick(gtx.Ops, r, &c.levels[l])
+		x = r.Max.X + dpPx(gtx, consoleChipGap)
+	}
+	b := chip
+	b.Size.X = dpPx(gtx, consolePauseWidth)
+	label := "❚❚ pause"
+	if c.paused {
+		b.Fill = consoleChipOn
+		label = "▶ follow"
+	}
+	r := b.Layout(gtx, th, image.Point{X: x + pad, Y: top}, label)
+	addClick(gtx.Ops, r, &c.pause)
+	x = r.Max.X + pad
+
+	count := dpBox(gtx, consoleCountStyle)
+	count.TextSize = chip.TextSize
+	countPos := image.Point{X: c.panel.Max.X - pad - count.Size.X, Y: top}
+	count.Layout(gtx, th, countPos, fmt.Sprintf("%d lines", n))
+
+	sb := dpBox(gtx, consoleBoxStyle)
+	sb.Size.X = countPos.X - pad - x
+	if sb.Size.X > 0 {
+		if c.searching {
+			sb.StrokeWidth = dpPx(gtx, 2)
+			sb.Stroke = consoleChipOn
+		}
+		c.searchRect = sb.Layout(gtx, th, image.Point{X: x, Y: top}, "")
+		inset := dpPoint(gtx, image.Point{X: 6, Y: 4})
+		c.layoutSearch(gtx, th, image.Rectangle{
+			Min: c.searchRect.Min.Add(inset),
+			Max: c.searchRect.Max.Sub(inset),
+		})
+	}
+	return top + chip.Size.Y
+}
+
+// layoutSearch lays out the search box editor in r.
+func (c *logConsole) layoutSearch(gtx *layout.Context, th *material.Theme, r image.Rectangle) {
+	if c.searching {
+		c.search.Focus()
+	}
+	var stack op.StackOp
+	stack.Push(gtx.Ops)
+	op.TransformOp{}.Offset(toPointF(r.Min)).Add(gtx.Ops)
+	saved := gtx.Constraints
+	gtx.Constraints = layout.RigidConstraints(r.Size())
+	ed := th.Editor("search")
+	ed.Font.Size = th.TextSize.Scale(.75)
+	ed.Layout(gtx, &c.search)
+	gtx.Constraints = saved
+	stack.Pop()
+}
+
+// layoutList lists lines in r.
+func (c *logConsole) layoutList(gtx *layout.Context, th *material.Theme, r image.Rectangle, lines []logLine) {
+	var stack op.StackOp
+	stack.Push(gtx.Ops)
+	op.TransformOp{}.Offset(toPointF(r.Min)).Add(gtx.Ops)
+	saved := gtx.Constraints
+	gtx.Constraints = layout.RigidConstraints(r.Size())
+	size := th.TextSize.Scale(.75)
+	c.list.ScrollToEnd = !c.paused
+	c.list.Layout(gtx, len(lines), func(i int) {
+		lbl := th.Label(size, lines[i].text)
+		lbl.Font.Variant = "Mono"
+		lbl.Color = consoleLineColors[lines[i].level]
+		lbl.Layout(gtx)
+	})
+	gtx.Constraints = saved
+	stack.Pop()
+}
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/io/system"
+
+	"github.com/glycerine/hello_gio.go/raster"
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+func TestLogRing(t *testing.T) {
+	r := newLogRing(3)
+	fmt.Fprintf(r, "reload.go:1 2019-10-01 12:00:00 +0000 UTC WARN reload: reload failed\n")
+	// A record written in pieces, with a message of two lines.
+	fmt.Fprintf(r, "loader.go:2 2019-10-01 12:00:00 +0000 UTC DEBUG loader: first")
+	fmt.Fprintf(r, "\nsecond\n\n")
+	fmt.Fprintf(r, "plain\n")
+	got := r.snapshot()
+	want := []logLine{
+		{vlog.Debug, "loader.go:2 2019-10-01 12:00:00 +0000 UTC DEBUG loader: first"},
+		{vlog.Debug, "second"},
+		{vlog.Debug, "plain"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ring holds %v, want %v", got, want)
+	}
+
+	long := strings.Repeat("x", 2*maxLogLine)
+	fmt.Fprintf(r, "%s\n", long)
+	if got := r.snapshot(); len(got[2].text) != maxLogLine {
+		t.Errorf("a long line was kept as %d bytes, want %d", len(got[2].text), maxLogLine)
+	}
+}
+
+// testConsole returns an open console of a ring of lines at every
+// level.
+func testConsole() *logConsole {
+	r := newLogRing(logRingLines)
+	for i := 0; i < 30; i++ {
+		l := vlog.Level(i % int(vlog.Off))
+		sub := []string{"reload", "loader", "tiled"}[i%3]
+		fmt.Fprintf(r, "%s.go:%d 2019-10-01 12:00:%02d -0400 EDT %s %s: record %d n=%d\n",
+			sub, 10+i, i, strings.ToUpper(l.String()), sub, i, i*i)
+	}
+	c := newLogConsole(r, nil)
+	c.Key(key.Event{Name: "L", Modifiers: key.ModCtrl})
+	return c
+}
+
+func TestLogConsoleFilter(t *testing.T) {
+	c := testConsole()
+	if !c.open {
+		t.Fatal("Ctrl+L did not open the console")
+	}
+	if n := len(c.lines()); n != 30 {
+		t.Errorf("%d lines listed, want all 30", n)
+	}
+	c.minLevel = vlog.Warn
+	if n := len(c.lines()); n != 12 {
+		t.Errorf("%d warn and error lines listed, want 12", n)
+	}
+	c.search.SetText("TILED")
+	for _, l := range c.lines() {
+		if l.level < vlog.Warn || !strings.Contains(l.text, "tiled") {
+			t.Errorf("%q listed", l.text)
+		}
+	}
+
+	// Paused, new lines wait.
+	c.search.SetText("")
+	c.setPaused(true)
+	fmt.Fprintf(c.ring, "x.go:1 2019-10-01 12:00:00 +0000 UTC ERROR x: late\n")
+	if n := len(c.lines()); n != 12 {
+		t.Errorf("%d lines listed while paused, want 12", n)
+	}
+	c.setPaused(false)
+	if n := len(c.lines()); n != 13 {
+		t.Errorf("%d lines listed after resuming, want 13", n)
+	}
+
+	c.Key(key.Event{Name: key.NameEscape})
+	if c.open {
+		t.Error("Escape did not close the console")
+	}
+}
+
+func TestGoldenConsole(t *testing.T) {
+	q := make(scriptQueue)
+	m := loadedDrawState(q, testScene(t))
+	c := testConsole()
+	c.minLevel = vlog.Debug
+	e := testFrame()
+	th := testTheme()
+	frame := func() {
+		drawFrame(m, th, e)
+		c.Layout(m.gtx, th, e.Size)
+	}
+	frame()
+	// A press in the search box moves the key focus there.
+	p := c.searchRect.Min.Add(c.searchRect.Max).Div(2)
+	q[c] = append(q[c], pointer.Event{Type: pointer.Press, Position: f32.Point{X: float32(p.X), Y: float32(p.Y)}})
+	frame()
+	if !c.searching {
+		t.Error("a press in the search box did not start a search")
+	}
+	checkGolden(t, "console", raster.Render(m.gtx.Ops, e.Size))
+
+	q[c] = append(q[c], pointer.Event{Type: pointer.Press, Position: f32.Point{X: 10, Y: 10}})
+	frame()
+	if c.searching {
+		t.Error("a press outside the search box did not end the search")
+	}
+}
+
+// TestConsoleHiDPI checks that the panel doubles at two pixels per
+// dp, as its text does.
+func TestConsoleHiDPI(t *testing.T) {
+	th := testTheme()
+	var rects [2]image.Rectangle
+	for i, cfg := range []system.Config{testConfig{}, hidpiConfig{}} {
+		m := loadedDrawState(nil, testScene(t))
+		c := testConsole()
+		e := system.FrameEvent{Config: cfg, Size: goldenWindowSize.Mul(i + 1)}
+		drawFrame(m, th, e)
+		c.Layout(m.gtx, th, e.Size)
+		rects[i] = c.searchRect
+	}
+	if want := (image.Rectangle{Min: rects[0].Min.Mul(2), Max: rects[0].Max.Mul(2)}); rects[1] != want {
+		t.Errorf("at 2x the search box is %v, want %v", rects[1], want)
+	}
+}
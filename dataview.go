@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/plot"
+	"github.com/glycerine/hello_gio.go/table"
+)
+
+// rowNumber is the x option that plots the y values against their
+// row number.
+const rowNumber = "(row number)"
+
+// dataView plots one column of a table against another, with
+// dropdowns along the top for choosing the columns.
+type dataView struct {
+	name  string
+	table *table.Table
+	x, y  dropdown
+	plot  plot.Plot
+}
+
+// newDataView shows the table t, read from path. xcol and ycol
+// name the columns to start with, by name or 1-based number; if
+// empty, x is the first time column, or else the row number, and y
+// the first other column.
+func newDataView(path string, t *table.Table, xcol, ycol string) (*dataView, error) {
+	v := &dataView{
+		name:  filepath.Base(path),
+		table: t,
+		x:     dropdown{label: "x", options: append([]string{rowNumber}, t.Names()...)},
+		y:     dropdown{label: "y", options: t.Names()},
+	}
+	if xcol == "" {
+		for i, c := range t.Columns {
+			if c.Kind == table.Time {
+				v.x.selected = i + 1
+				break
+			}
+		}
+	} else if xcol != rowNumber {
+		i, err := t.ColumnIndex(xcol)
+		if err != nil {
+			return nil, fmt.Errorf("-x: %v", err)
+		}
+		v.x.selected = i + 1
+	}
+	if ycol == "" {
+		for i := range t.Columns {
+			if i+1 != v.x.selected {
+				v.y.selected = i
+				break
+			}
+		}
+	} else {
+		i, err := t.ColumnIndex(ycol)
+		if err != nil {
+			return nil, fmt.Errorf("-y: %v", err)
+		}
+		v.y.selected = i
+	}
+	v.update()
+	return v, nil
+}
+
+// update rebuilds the plot for the chosen columns.
+func (v *dataView) update() {
+	yc := v.table.Columns[v.y.selected]
+	s := plot.Series{Y: yc.Values, LineWidth: 1.5}
+	if v.table.Rows <= 200 {
+		s.Marker = plot.Circle
+		s.MarkerSize = 5
+	}
+	v.plot = plot.Plot{
+		Title:      v.name,
+		X:          plot.Axis{Label: v.x.Value()},
+		Y:          plot.Axis{Label: yc.Name, Time: yc.Kind == table.Time},
+		Series:     []plot.Series{s},
+		Grid:       true,
+		Background: color.RGBA{255, 255, 255, 255},
+	}
+	if v.x.selected > 0 {
+		xc := v.table.Columns[v.x.selected-1]
+		v.plot.Series[0].X = xc.Values
+		v.plot.X.Time = xc.Kind == table.Time
+	}
+}
+
+// Layout draws the dropdowns at the top of r and the plot below
+// them.
+func (v *dataView) Layout(gtx *layout.Context, th *material.Theme, r image.Rectangle) {
+	// Handle the dropdowns first, so that a new choice is plotted
+	// in this very frame.
+	changed := v.x.Update(gtx)
+	if v.y.Update(gtx) {
+		changed = true
+	}
+	if changed {
+		v.update()
+	}
+
+	gap := dpPx(gtx, 10)
+	size := dpPoint(gtx, dropdownStyle.Size)
+	pr := r
+	pr.Min.Y += size.Y + 2*gap
+	// The plot is drawn first so that open dropdowns cover it.
+	v.plot.Layout(gtx, th, pr)
+	pos := image.Point{X: r.Min.X + gap, Y: r.Min.Y + gap}
+	v.x.Layout(gtx, th, pos)
+	pos.X += size.X + gap
+	v.y.Layout(gtx, th, pos)
+}
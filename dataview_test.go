@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+
+	"github.com/glycerine/hello_gio.go/raster"
+	"github.com/glycerine/hello_gio.go/scene"
+	"github.com/glycerine/hello_gio.go/table"
+)
+
+func testDataView(t *testing.T, q scriptQueue, xcol, ycol string) (*myDrawState, *dataView) {
+	t.Helper()
+	const path = "testdata/weather.csv"
+	tb, err := table.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := newDataView(path, tb, xcol, ycol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := loadedDrawState(q, &scene.Scene{Version: scene.Version, Background: "white"})
+	m.data = v
+	return m, v
+}
+
+func TestNewDataViewColumns(t *testing.T) {
+	_, v := testDataView(t, nil, "", "")
+	if got, want := v.x.Value(), "time"; got != want {
+		t.Errorf("default x is %q, want %q", got, want)
+	}
+	if got, want := v.y.Value(), "temperature (C)"; got != want {
+		t.Errorf("default y is %q, want %q", got, want)
+	}
+	if !v.plot.X.Time {
+		t.Error("time column not plotted on a time axis")
+	}
+
+	_, v = testDataView(t, nil, rowNumber, "3")
+	if v.x.selected != 0 || v.y.Value() != "humidity" || v.plot.Series[0].X != nil {
+		t.Errorf("got x %q, y %q; want the row number and humidity", v.x.Value(), v.y.Value())
+	}
+
+	tb, _ := table.Load("testdata/weather.csv")
+	if _, err := newDataView("weather.csv", tb, "", "rain"); err == nil {
+		t.Error("no error for a missing y column")
+	}
+}
+
+// TestGoldenDataView covers a time axis and missing values, with the
+// y dropdown open.
+func TestGoldenDataView(t *testing.T) {
+	m, v := testDataView(t, nil, "", "humidity")
+	v.y.open = true
+	e := testFrame()
+	drawFrame(m, testTheme(), e)
+	checkGolden(t, "dataview", raster.Render(m.gtx.Ops, e.Size))
+}
+
+func TestDropdownChoosesColumn(t *testing.T) {
+	q := make(scriptQueue)
+	m, v := testDataView(t, q, "", "")
+	e := testFrame()
+	th := testTheme()
+	click := func(k interface{}) {
+		q[k] = append(q[k],
+			pointer.Event{Type: pointer.Press, Hit: true, Buttons: pointer.ButtonLeft, Position: f32.Point{X: 1, Y: 1}},
+			pointer.Event{Type: pointer.Release, Position: f32.Point{X: 1, Y: 1}})
+		drawFrame(m, th, e)
+	}
+	drawFrame(m, th, e)
+	click(&v.y.button)
+	if !v.y.open {
+		t.Fatal("clicking the dropdown did not open it")
+	}
+	click(&v.y.items[2])
+	if v.y.open {
+		t.Error("choosing an option did not close the dropdown")
+	}
+	if got, want := v.plot.Y.Label, "humidity"; got != want {
+		t.Errorf("plotting %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/gesture"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+)
+
+// dropdown is a button showing the chosen option. Clicking it lists
+// all the options below it, and clicking one of those chooses it.
+// Like the boxes, a dropdown keeps its state from frame to frame.
+type dropdown struct {
+	// label is shown before the chosen option, as in "x: time".
+	label    string
+	options  []string
+	selected int
+
+	open   bool
+	button gesture.Click
+	items  []gesture.Click
+}
+
+// The dropdown styles give their sizes in dp, for dpBox.
+var (
+	dropdownStyle = box.Box{
+		Size:         image.Point{X: 220, Y: 30},
+		Fill:         color.RGBA{235, 235, 235, 255},
+		StrokeWidth:  1,
+		Stroke:       color.RGBA{150, 150, 150, 255},
+		CornerRadius: 4,
+		Padding:      4,
+		Ellipsis:     true,
+	}
+	dropdownItemStyle = box.Box{
+		Size:        image.Point{X: 220, Y: 30},
+		Fill:        color.RGBA{255, 255, 255, 255},
+		StrokeWidth: 1,
+		Stroke:      color.RGBA{220, 220, 220, 255},
+		Padding:     4,
+		Ellipsis:    true,
+	}
+	dropdownSelectedFill = color.RGBA{200, 220, 255, 255}
+)
+
+// Update handles the clicks since the last frame. It reports
+// whether another option was chosen.
+func (d *dropdown) Update(q event.Queue) (changed bool) {
+	for _, e := range d.button.Events(q) {
+		if e.Type == gesture.TypeClick {
+			d.open = !d.open
+		}
+	}
+	for i := range d.items {
+		for _, e := range d.items[i].Events(q) {
+			if e.Type == gesture.TypeClick && d.open {
+				changed = changed || i != d.selected
+				d.selected = i
+				d.open = false
+			}
+		}
+	}
+	return changed
+}
+
+// Layout draws the dropdown with its top-left corner at pos, and
+// the list of options below it if it is open. Lay out dropdowns
+// after whatever the list may cover, so that the list is drawn, and
+// hit, on top.
+func (d *dropdown) Layout(gtx *layout.Context, th *material.Theme, pos image.Point) {
+	if len(d.items) != len(d.options) {
+		d.items = make([]gesture.Click, len(d.options))
+	}
+	b := dpBox(gtx, dropdownStyle)
+	r := b.Layout(gtx, th, pos, d.label+": "+d.Value()+" ▼")
+	addClick(gtx.Ops, r, &d.button)
+	if !d.open {
+		return
+	}
+	item := dpBox(gtx, dropdownItemStyle)
+	p := image.Point{X: pos.X, Y: r.Max.Y}
+	for i, opt := range d.options {
+		b := item
+		if i == d.selected {
+			b.Fill = dropdownSelectedFill
+		}
+		r := b.Layout(gtx, th, p, opt)
+		addClick(gtx.Ops, r, &d.items[i])
+		p.Y = r.Max.Y
+	}
+}
+
+// Value returns the chosen option.
+func (d *dropdown) Value() string {
+	if d.selected < 0 || d.selected >= len(d.options) {
+		return ""
+	}
+	return d.options[d.selected]
+}
+
+// addClick registers the click handler c over the area r.
+func addClick(ops *op.Ops, r image.Rectangle, c *gesture.Click) {
+	var stack op.StackOp
+	stack.Push(ops)
+	pointer.Rect(r).Add(ops)
+	c.Add(ops)
+	stack.Pop()
+}
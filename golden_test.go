@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// Golden-image snapshot testing: a frame is rasterized on the CPU
+// and compared, pixel by pixel, against a committed PNG under
+// testdata/golden. Regenerate the goldens after an intended change
+// with
+//
+//	go test -run Golden -update
+//
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gioui.org/unit"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden PNGs in testdata/golden")
+var goldenOut = flag.String("golden.out", filepath.Join(os.TempDir(), "hello_gio_golden"),
+	"directory for the actual/expected/diff PNGs of failed comparisons")
+
+// goldenTolerance is the largest difference allowed in any one
+// color channel of a pixel before it counts as a mismatch.
+const goldenTolerance = 2
+
+// testConfig is a fixed system.Config: one pixel per dp and sp,
+// and a frozen clock.
+type testConfig struct{}
+
+func (testConfig) Now() time.Time {
+	return time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (testConfig) Px(v unit.Value) int {
+	return int(v.V + .5)
+}
+
+// checkGolden compares got with testdata/golden/<name>.png. On a
+// mismatch it writes <name>.actual.png, <name>.expected.png and
+// <name>.diff.png into the -golden.out directory.
+func checkGolden(t *testing.T, name string, got *image.RGBA) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".png")
+	if *updateGolden {
+		if err := writePNG(path, got); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, _, err := LoadImage(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	diff, n := diffImages(got, want, goldenTolerance)
+	if n == 0 {
+		return
+	}
+	if err := os.MkdirAll(*goldenOut, 0755); err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(*goldenOut, name)
+	for suffix, img := range map[string]image.Image{
+		".actual.png":   got,
+		".expected.png": want,
+		".diff.png":     diff,
+	} {
+		if err := writePNG(base+suffix, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Errorf("%s: %d pixels differ by more than %d; see %s.{actual,expected,diff}.png",
+		name, n, goldenTolerance, base)
+}
+
+// diffImages returns an image highlighting in red the pixels of got
+// and want that differ by more than tol in any channel, over a faded
+// copy of want, and the number of such pixels. Pixels outside the
+// overlap of the two images always count as different.
+func diffImages(got, want image.Image, tol int) (*image.RGBA, int) {
+	b := got.Bounds().Union(want.Bounds())
+	diff := image.NewRGBA(b)
+	n := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := image.Point{X: x, Y: y}
+			g := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+			w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			same := p.In(got.Bounds()) && p.In(want.Bounds()) &&
+				absDiff(g.R, w.R) <= tol && absDiff(g.G, w.G) <= tol &&
+				absDiff(g.B, w.B) <= tol && absDiff(g.A, w.A) <= tol
+			if !same {
+				n++
+				diff.SetRGBA(x, y, color.RGBA{R: 0xff, A: 0xff})
+				continue
+			}
+			gray := uint8((uint32(w.R) + uint32(w.G) + uint32(w.B)) / 3)
+			fade := 0xff - (0xff-gray)/4
+			diff.SetRGBA(x, y, color.RGBA{R: fade, G: fade, B: fade, A: 0xff})
+		}
+	}
+	return diff, n
+}
+
+func absDiff(a, b uint8) int {
+	d := int(a) - int(b)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -9,27 +9,31 @@
 // plot rectangular boxes at specific screen positions of your choosing.
 //
 // It then demonstrates how to place labels over those boxes, and to clip
-// those labels to stay within their boxes.
+// those labels to stay within their boxes. The boxes themselves are
+// drawn by the box package.
 //
 package main
 
 import (
 	"fmt"
 	"image"
-	"image/color"
 	"log"
+	"os"
+	"sync"
 
 	"gioui.org/app"
-	"gioui.org/f32"
 	"gioui.org/font/gofont"
+	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
-	"gioui.org/op/clip"
 	"gioui.org/op/paint"
-	"gioui.org/unit"
 	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/scene"
+	"github.com/glycerine/hello_gio.go/tooltip"
 )
 
 var _ = paint.ImageOp{}
@@ -39,27 +43,78 @@ var _ = pointer.Event{}
 var _ = fmt.Printf
 
 func main() {
-	// to see just the showimg.go ping display alone:
-	//showImageMain()
-	//return
-
-	go func() {
-		w := app.NewWindow(app.Title("hello_gio!"))
-		if err := loop(w); err != nil {
-			log.Fatal(err)
+	o, err := parseArgs(os.Args[1:], os.Stdout)
+	switch e := err.(type) {
+	case nil:
+	case *usageError:
+		fmt.Fprintf(os.Stderr, "hello_gio: %v\nRun 'hello_gio help' for usage.\n", e)
+		os.Exit(exitUsage)
+	default:
+		// Help was asked for, and printed.
+		os.Exit(exitOK)
+	}
+	if err := o.setupLogging(os.Getenv); err != nil {
+		fmt.Fprintf(os.Stderr, "hello_gio: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if err := run(o); err != nil {
+		fmt.Fprintf(os.Stderr, "hello_gio: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// run carries out the command of o. The window commands only
+// return if they fail to start.
+func run(o *options) error {
+	switch o.command {
+	case "view":
+		files, err := viewerFiles(o.files)
+		if err != nil {
+			return err
+		}
+		showImageMain(files, o.shots, o.loops, o.windowOptions()...)
+	case "render":
+		return render(o)
+	default:
+		// demo and plot.
+		sc, data, err := o.load()
+		if err != nil {
+			return err
 		}
-	}()
-	app.Main()
+		go func() {
+			w := app.NewWindow(o.windowOptions()...)
+			if err := loop(w, sc, data, newFrameSaver(o.shots, w.Invalidate)); err != nil {
+				log.Fatal(err)
+			}
+		}()
+		app.Main()
+	}
+	return nil
+}
+
+var registerFonts sync.Once
+
+// newTheme returns the theme for the Go fonts.
+func newTheme() *material.Theme {
+	registerFonts.Do(gofont.Register)
+	return material.NewTheme()
 }
 
-func loop(w *app.Window) error {
+func loop(w *app.Window, sc *scene.Scene, data *dataView, saver *frameSaver) error {
 
-	gofont.Register()
-	theme := material.NewTheme()
+	theme := newTheme()
 
-	m := setupDrawState(w)
-	_ = m
-	yellowBkg := true
+	m := setupDrawState(w, sc)
+	m.data = data
+	console := newLogConsole(logs, w.Invalidate)
+	prof := newProfiler(saver.dir)
+	insp := newOpInspector(saver.dir)
+	// The saver has the key focus, and passes the keys it doesn't
+	// use on to the console, the profiler, the inspector and the
+	// animations.
+	saver.keys = func(e key.Event) bool {
+		return console.Key(e) || prof.Key(e) || insp.Key(e) || m.animKey(e)
+	}
 
 	for {
 		e := <-w.Events()
@@ -67,105 +122,134 @@ func loop(w *app.Window) error {
 		case system.DestroyEvent:
 			return e.Err
 		case system.FrameEvent:
-			m.gtx.Reset(e.Config, e.Size)
-
-			// draw a pre-rendered png plot on the screen.
-			showImage(e, m, yellowBkg)
-
-			// draw some boxes with labels directly.
-			direct(m.gtx, theme, w, e)
+			prof.Begin()
+			drawFrame(m, theme, e)
+			insp.Layout(m.gtx, theme, e.Size)
+			saver.Layout(m.gtx, theme, e.Size)
+			console.Layout(m.gtx, theme, e.Size)
+			prof.Layout(m.gtx, theme, e.Size)
 
 			// Submit operations to the window.
 			e.Frame(m.gtx.Ops)
+			saver.Submitted(m.gtx, e.Size)
 		}
 	}
 }
 
-func direct(gtx *layout.Context, theme *material.Theme, w *app.Window, e system.FrameEvent) {
+// drawFrame lays out one frame of the scene into m.gtx.Ops.
+func drawFrame(m *myDrawState, theme *material.Theme, e system.FrameEvent) {
+	m.gtx.Reset(e.Config, e.Size)
 
-	//func direct(gtx *layout.Context, w *app.Window, e app.UpdateEvent, face text.Face) {
-	aqua := color.RGBA{A: 0xff, G: 0xcc, B: 200}
-	const borderPix = 5
+	// pick up the files that changed since the last frame.
+	m.applyUpdates()
+	m.tips.Update(m.gtx)
 
-	m := &box{
-		h:         50,
-		w:         50,
-		color:     aqua,
-		borderPix: borderPix,
+	// draw the background and the pre-rendered png plot on the screen.
+	showImage(e, m)
+	drawPlots(m.gtx, theme, m)
+
+	// plot the -data file, if any, across the window.
+	if m.data != nil {
+		m.data.Layout(m.gtx, theme, image.Rectangle{Max: e.Size})
+		addPointTip(&m.tips, &m.data.plot)
 	}
 
-	// draws 5 squares
-	for i := 0; i < 5; i++ {
-		x := 100 + i*100
-		y := 50
-		if i%2 == 0 {
-			y = 0
-		}
-		ci := 50 * byte(i) // color increment
+	// draw some boxes with labels directly.
+	direct(m.gtx, theme, m.canvas, &m.tips)
 
-		// add _0123 to the end so we can see the clipping in action.
-		m.drawc(gtx, theme, x, y, color.RGBA{A: 0xff, G: 0xcc, B: ci, R: 255 - ci}, fmt.Sprintf("%v_0123", i))
-	}
+	// flag the files that failed to load.
+	drawBadges(m.gtx, theme, m)
+
+	// the tooltip, if any, goes over everything.
+	m.tips.Layout(m.gtx, theme, e.Size)
 }
 
-type box struct {
-	h         int        //height
-	w         int        //width
-	color     color.RGBA // default
-	borderPix int        // number of pixels to inset the label from the box edge.
+// canvasBox is one of the demo boxes, with the state that has to
+// survive from frame to frame.
+type canvasBox struct {
+	// spec is the box in the scene, in dp.
+	spec  *scene.Box
+	label string
+	// tip is the text of the box's tooltip.
+	tip  string
+	drag box.Drag
 }
 
-// draw a rectangle at x0,y0 with given color; adding it to the gtx.Ops chain.
-func (e *box) drawc(gtx *layout.Context, theme *material.Theme, x0, y0 int, color color.RGBA, label string) {
-	ops := gtx.Ops
-	paint.ColorOp{Color: color}.Add(ops)
-	re := f32.Rectangle{
-		Min: f32.Point{X: float32(x0), Y: float32(y0)},
-		Max: f32.Point{X: float32(x0 + e.w), Y: float32(y0 + e.h)},
+// canvas holds the demo boxes in paint order. The last box is drawn
+// on top, and since its pointer handler is added last it is also
+// the first to be hit.
+type canvas struct {
+	boxes    []*canvasBox
+	selected *canvasBox
+	// placed is set once the boxes are at their starting
+	// positions, which takes the screen density of a frame.
+	placed bool
+}
+
+// newCanvas holds the boxes of sc. The first frame places them.
+func newCanvas(sc *scene.Scene) *canvas {
+	c := &canvas{}
+	for i := range sc.Boxes {
+		sb := &sc.Boxes[i]
+		cb := &canvasBox{
+			spec:  sb,
+			label: sb.Label,
+			tip:   sb.Tip,
+		}
+		if cb.tip == "" {
+			cb.tip = sb.Label
+		}
+		c.boxes = append(c.boxes, cb)
 	}
-	paint.PaintOp{Rect: re}.Add(ops)
-
-	// add a label:
-	// To position the label, use a TransformOp. This is what
-	// layout.Inset does internally too.
-	var stack op.StackOp
-	stack.Push(ops)
-	op.TransformOp{}.Offset(f32.Point{
-		X: float32(x0 + e.borderPix),
-		Y: float32(y0 + e.borderPix),
-	}).Add(ops)
-
-	// this next line clips the label to the box edges, so it does not
-	// extend beyond or overflow outside the box.
-	e.inPlaceClip(e.borderPix).Add(ops)
-
-	theme.Label(unit.Sp(20), label).Layout(gtx)
-
-	stack.Pop() // ops)
-
-	// Elias comments:
-	//
-	//The StackOp is for undoing the effect of the transformation.
-	//
-	//	If you want rectangular clipping in general, use gioui.org/op/paint.RectClip (as above).
-	//	If you want path based clipping, use paint.PathBuilder.
-	//	Clip operations are also undone by the StackOps.
-	//
-	//	Note that text.Label already clips itself to honor the constraints set
-	// 	in gtx.Constraints.
+	return c
+}
 
+// place moves the boxes to their starting positions, converted to
+// pixels by gtx, unless they are there already. After that they
+// stay where they are dragged, in pixels.
+func (c *canvas) place(gtx *layout.Context) {
+	if c.placed {
+		return
+	}
+	c.placed = true
+	for _, cb := range c.boxes {
+		cb.drag.Pos = cb.spec.Position(gtx)
+	}
 }
 
-// The returned ClipOp is at the (0,0) origin, since the
-// offset/location is typically already on the stack.
-// Thus it is an "in-place" clip, relative to the current
-// stack's position, rather than at an arbitrary screen position.
-//
-func (e *box) inPlaceClip(border int) clip.Op {
-	return clip.Rect{
-		Rect: f32.Rectangle{
-			Min: f32.Point{X: 0, Y: 0},
-			Max: f32.Point{X: float32(e.w - border), Y: float32(e.h - border)},
-		},
-	}.Op(nil)
+// raise moves cb to the top of the paint order.
+func (c *canvas) raise(cb *canvasBox) {
+	for i, b := range c.boxes {
+		if b == cb {
+			copy(c.boxes[i:], c.boxes[i+1:])
+			c.boxes[len(c.boxes)-1] = cb
+			return
+		}
+	}
+}
+
+// direct draws the boxes of c and gives each a tooltip.
+func direct(gtx *layout.Context, theme *material.Theme, c *canvas, tips *tooltip.Tips) {
+	c.place(gtx)
+	// Handle the pointer first, so that a pressed box is raised
+	// and follows the pointer in this very frame.
+	for _, cb := range append([]*canvasBox(nil), c.boxes...) {
+		if cb.drag.Update(gtx) {
+			c.raise(cb)
+			c.selected = cb
+		}
+	}
+
+	for _, cb := range c.boxes {
+		b := cb.spec.Style(gtx)
+		if cb == c.selected && b.StrokeWidth < 2 {
+			b.StrokeWidth = 2
+			b.Stroke = colors["black"]
+		}
+		// Register the hit area right after painting, with the
+		// bounds just painted, so hits follow the paint order.
+		r := b.Layout(gtx, theme, cb.drag.Pos, cb.label)
+		cb.drag.Add(gtx.Ops, r)
+		tips.Add(cb, r, cb.tip)
+	}
 }
@@ -0,0 +1,311 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/io/system"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/raster"
+	"github.com/glycerine/hello_gio.go/scene"
+	"github.com/glycerine/hello_gio.go/tooltip"
+)
+
+// goldenWindowSize fits the whole demo: the boxes along the top and
+// points.png drawn at (300,200) and 1000px wide.
+var goldenWindowSize = image.Point{X: 1400, Y: 900}
+
+func testTheme() *material.Theme {
+	return newTheme()
+}
+
+func testFrame() system.FrameEvent {
+	return system.FrameEvent{
+		Config: testConfig{},
+		Size:   goldenWindowSize,
+	}
+}
+
+// testScene loads the default scene, scene.json.
+func testScene(t *testing.T) *scene.Scene {
+	t.Helper()
+	sc, err := scene.Load("scene.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+// loadedDrawState is newDrawState with the scene images decoded.
+func loadedDrawState(q event.Queue, sc *scene.Scene) *myDrawState {
+	m := newDrawState(q, sc)
+	m.loader.wait()
+	m.loader.apply()
+	return m
+}
+
+func TestGoldenDemo(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		yellowBkg bool
+	}{
+		{"demo_yellow", true},
+		{"demo_border", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := testScene(t)
+			if !tc.yellowBkg {
+				sc.Background = ""
+			}
+			m := loadedDrawState(nil, sc)
+			e := testFrame()
+			drawFrame(m, testTheme(), e)
+			checkGolden(t, tc.name, raster.Render(m.gtx.Ops, e.Size))
+		})
+	}
+}
+
+// TestGoldenBoxes covers box placement and the clipping of the
+// "_0123" label suffix at the box edges.
+func TestGoldenBoxes(t *testing.T) {
+	m := loadedDrawState(nil, testScene(t))
+	e := testFrame()
+	m.gtx.Reset(e.Config, e.Size)
+	direct(m.gtx, testTheme(), m.canvas, &m.tips)
+	checkGolden(t, "boxes", raster.Render(m.gtx.Ops, e.Size))
+}
+
+// TestGoldenImage covers the placement of points.png and the cream
+// border around it.
+func TestGoldenImage(t *testing.T) {
+	sc := testScene(t)
+	sc.Background = ""
+	m := loadedDrawState(nil, sc)
+	e := testFrame()
+	showImage(e, m)
+	checkGolden(t, "image", raster.Render(m.gtx.Ops, e.Size))
+}
+
+// TestGoldenImagePlacement covers points.png fitted to, and filling,
+// a square window, with a letterbox and a border.
+func TestGoldenImagePlacement(t *testing.T) {
+	for _, tc := range []struct {
+		name, place string
+	}{
+		{"image_fit", scene.PlaceFit},
+		{"image_fill", scene.PlaceFill},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := testScene(t)
+			sc.Images[0].Place = tc.place
+			sc.Images[0].Letterbox = "black"
+			m := loadedDrawState(nil, sc)
+			e := testFrame()
+			e.Size = image.Point{X: 600, Y: 600}
+			showImage(e, m)
+			checkGolden(t, tc.name, raster.Render(m.gtx.Ops, e.Size))
+		})
+	}
+}
+
+// TestGoldenBoxStyles covers borders, rounded corners, alignment,
+// wrapping and ellipsis in the box package.
+func TestGoldenBoxStyles(t *testing.T) {
+	m := loadedDrawState(nil, testScene(t))
+	e := testFrame()
+	m.gtx.Reset(e.Config, e.Size)
+	th := testTheme()
+	const long = "The quick brown fox jumps over the lazy dog"
+	for i, b := range []box.Box{
+		{Fill: colors["cream"], StrokeWidth: 3, Stroke: colors["maroon"], Padding: 4},
+		{Fill: colors["cream"], StrokeWidth: 6, Stroke: colors["black"], CornerRadius: 20, Padding: 4},
+		{Fill: colors["cream"], CornerRadius: 75, Padding: 20, Alignment: text.Middle, Wrap: true},
+		{StrokeWidth: 2, Stroke: colors["maroon"], Padding: 4, Alignment: text.End, Wrap: true, Ellipsis: true},
+		{Fill: colors["cream"], Padding: 4, Ellipsis: true},
+		{Fill: colors["cream"], Padding: 4, Wrap: true, MaxLines: 2, Ellipsis: true},
+	} {
+		b.Size = image.Point{X: 150, Y: 150}
+		pos := image.Point{X: 20 + (i%3)*200, Y: 20 + (i/3)*200}
+		b.Layout(m.gtx, th, pos, long)
+	}
+	checkGolden(t, "box_styles", raster.Render(m.gtx.Ops, e.Size))
+}
+
+// scriptQueue hands out, once, the events queued for each key.
+type scriptQueue map[event.Key][]event.Event
+
+func (q scriptQueue) Events(k event.Key) []event.Event {
+	evs := q[k]
+	delete(q, k)
+	return evs
+}
+
+func TestDragRaisesAndMovesBox(t *testing.T) {
+	q := make(scriptQueue)
+	m := loadedDrawState(q, testScene(t))
+	e := testFrame()
+	th := testTheme()
+	c := m.canvas
+	first := c.boxes[0]
+	frame := func(evs ...pointer.Event) {
+		for _, ev := range evs {
+			q[&first.drag] = append(q[&first.drag], ev)
+		}
+		m.gtx.Reset(e.Config, e.Size)
+		direct(m.gtx, th, c, &m.tips)
+	}
+	frame(pointer.Event{Type: pointer.Press, Hit: true, Buttons: pointer.ButtonLeft,
+		Position: f32.Point{X: 110, Y: 10}})
+	if c.boxes[len(c.boxes)-1] != first || c.selected != first {
+		t.Fatal("pressed box was not raised and selected")
+	}
+	if !first.drag.Dragging() {
+		t.Fatal("pressed box is not dragging")
+	}
+	frame(pointer.Event{Type: pointer.Move, Position: f32.Point{X: 410, Y: 210}})
+	frame(pointer.Event{Type: pointer.Release, Position: f32.Point{X: 410, Y: 210}})
+	if got, want := first.drag.Pos, (image.Point{X: 400, Y: 200}); got != want {
+		t.Errorf("dragged box at %v, want %v", got, want)
+	}
+	if first.drag.Dragging() {
+		t.Error("box still dragging after release")
+	}
+}
+
+// TestGoldenPlot covers the plot package: axes, ticks, labels, grid,
+// a polyline and markers, as laid out from plot.json.
+func TestGoldenPlot(t *testing.T) {
+	sc, err := scene.Load("plot.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := loadedDrawState(nil, sc)
+	e := testFrame()
+	drawFrame(m, testTheme(), e)
+	checkGolden(t, "plot", raster.Render(m.gtx.Ops, e.Size))
+}
+
+// hidpiConfig is testConfig at two pixels per dp and sp.
+type hidpiConfig struct {
+	testConfig
+}
+
+func (hidpiConfig) Px(v unit.Value) int {
+	return int(2*v.V + .5)
+}
+
+// TestHiDPI draws the demo at two pixels per dp, and checks that the
+// boxes, the image, a plot and an error badge all double, so that
+// the boxes still sit where they did over the image, and the badge
+// still holds its text.
+func TestHiDPI(t *testing.T) {
+	sc := testScene(t)
+	sc.Plots = append(sc.Plots, scene.Plot{
+		Dest:       scene.Rect{X: 10, Y: 500, W: 280, H: 300},
+		Background: "black",
+		Series:     []scene.Series{{Y: []float64{1, 4, 2, 3}}},
+	})
+	m := loadedDrawState(nil, sc)
+	e := system.FrameEvent{Config: hidpiConfig{}, Size: goldenWindowSize.Mul(2)}
+	drawFrame(m, testTheme(), e)
+	img := raster.Render(m.gtx.Ops, e.Size)
+
+	if got, want := m.images[0].place.View, image.Rect(600, 400, 2600, 1708); got != want {
+		t.Errorf("image at %v, want %v", got, want)
+	}
+	for i, cb := range m.canvas.boxes {
+		sb := sc.Boxes[i]
+		r := image.Rect(sb.Pos.X, sb.Pos.Y, sb.Pos.X+sb.Size.W, sb.Pos.Y+sb.Size.H)
+		r.Min, r.Max = r.Min.Mul(2), r.Max.Mul(2)
+		if cb.drag.Pos != r.Min {
+			t.Errorf("box %d at %v, want %v", i, cb.drag.Pos, r.Min)
+		}
+		fill, _ := scene.ParseColor(sb.Fill)
+		for _, p := range []image.Point{r.Min, r.Max.Sub(image.Point{X: 1, Y: 1})} {
+			if got := img.RGBAAt(p.X, p.Y); got != fill {
+				t.Errorf("box %d: pixel %v is %v, want %v", i, p, got, fill)
+			}
+		}
+	}
+	black := color.RGBA{A: 0xff}
+	for _, p := range []image.Point{{20, 1000}, {579, 1599}} {
+		if got := img.RGBAAt(p.X, p.Y); got != black {
+			t.Errorf("plot: pixel %v is %v, want the plot background", p, got)
+		}
+	}
+	if got := img.RGBAAt(581, 1601); got == black {
+		t.Errorf("plot: pixel (581,1601) is the plot background, past its dest")
+	}
+
+	// The badge of a scene that failed to reload, in the top left
+	// corner, where there are no boxes: inside its bottom right
+	// corner and past it.
+	sc = testScene(t)
+	sc.Boxes = nil
+	var frames [2]*image.RGBA
+	for i, e := range []system.FrameEvent{testFrame(), e} {
+		m := loadedDrawState(nil, sc)
+		m.sceneErr = errors.New("scene.json: invalid")
+		drawFrame(m, testTheme(), e)
+		frames[i] = raster.Render(m.gtx.Ops, e.Size)
+	}
+	for _, p := range []image.Point{{350, 62}, {365, 62}, {350, 75}} {
+		if got, want := frames[1].RGBAAt(2*p.X, 2*p.Y), frames[0].RGBAAt(p.X, p.Y); got != want {
+			t.Errorf("badge: pixel %v is %v, want %v as at %v at one pixel per dp", p.Mul(2), got, want, p)
+		}
+	}
+}
+
+// clockConfig is testConfig with a clock that tests move on.
+type clockConfig struct {
+	testConfig
+	now time.Time
+}
+
+func (c *clockConfig) Now() time.Time {
+	return c.now
+}
+
+// TestGoldenTooltip rests the pointer on a box, whose label is cut
+// short, and on a plot point, and checks their tooltips.
+func TestGoldenTooltip(t *testing.T) {
+	sc := testScene(t)
+	sc.Plots = append(sc.Plots, scene.Plot{
+		Dest:   scene.Rect{X: 10, Y: 500, W: 280, H: 300},
+		Series: []scene.Series{{Y: []float64{1, 4, 2, 3}, Marker: "circle"}},
+	})
+	q := make(scriptQueue)
+	m := loadedDrawState(q, sc)
+	th := testTheme()
+	cfg := &clockConfig{now: testConfig{}.Now()}
+	e := system.FrameEvent{Config: cfg, Size: goldenWindowSize}
+	rest := func(at f32.Point) {
+		q[&m.tips] = []event.Event{pointer.Event{Type: pointer.Move, Position: at}}
+		drawFrame(m, th, e)
+		cfg.now = cfg.now.Add(tooltip.DefaultDelay)
+		drawFrame(m, th, e)
+	}
+
+	rest(f32.Point{X: 120, Y: 20})
+	checkGolden(t, "tooltip_box", raster.Render(m.gtx.Ops, e.Size))
+
+	p := m.plots[len(m.plots)-1]
+	_, _, at, ok := p.Nearest(image.Point{X: 150, Y: 650}, 1000)
+	if !ok {
+		t.Fatal("no point in the plot")
+	}
+	rest(toPointF(at).Add(f32.Point{X: 2, Y: 2}))
+	checkGolden(t, "tooltip_point", raster.Render(m.gtx.Ops, e.Size))
+}
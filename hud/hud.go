@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package hud shows how long frames take to lay out and draw, in a
+// heads-up panel over the window.
+//
+// While the panel is shown, it adds a profile.Op to every frame,
+// which makes the window time how long the frame took in all, how
+// long the GPU goroutine spent drawing it and, where the driver has
+// timer queries, how long the GPU itself took. The window reports
+// these as a profile.Event in the frame after, so each Sample is
+// completed a frame late. To them the HUD adds what it measures
+// itself: the time the program took to lay the frame out, the
+// number of ops in it, and the bytes of the images it paints, as the
+// RGBA textures they are uploaded to.
+package hud
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/io/profile"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+	"github.com/glycerine/hello_gio.go/internal/ops"
+)
+
+// Keep is the number of frames whose samples are kept.
+const Keep = 600
+
+// Sample is what was measured of one frame.
+type Sample struct {
+	// At is the time of the frame.
+	At time.Time
+	// Layout is how long the program took to lay the frame out.
+	Layout time.Duration
+	// Total is the whole frame as the window timed it, Draw the
+	// part the GPU goroutine took, and GPU the time the GPU took,
+	// or zero where the driver cannot tell.
+	Total, Draw, GPU time.Duration
+	// Ops counts the ops of the frame, and Texture the bytes of the
+	// images it paints.
+	Ops     int
+	Texture int
+}
+
+// Frame returns the time the frame took: Total, or Layout if there
+// was no profile of the frame.
+func (s Sample) Frame() time.Duration {
+	if s.Total > 0 {
+		return s.Total
+	}
+	return s.Layout
+}
+
+// timingRE matches the fields of a profile.Event, such as
+// "tot:  12.3ms".
+var timingRE = regexp.MustCompile(`(\w+):\s*(\S+)`)
+
+// parseTimings fills in the times of s from the Timings of a
+// profile.Event. Fields it does not know are skipped.
+func parseTimings(s *Sample, timings string) {
+	for _, m := range timingRE.FindAllStringSubmatch(timings, -1) {
+		d, err := time.ParseDuration(m[2])
+		if err != nil {
+			continue
+		}
+		switch m[1] {
+		case "tot":
+			s.Total = d
+		case "draw":
+			s.Draw = d
+		case "gpu":
+			s.GPU = d
+		}
+	}
+}
+
+// HUD is the panel. Call Begin before laying out a frame, and
+// Layout after everything else.
+type HUD struct {
+	// Note, if set, is shown at the bottom of the panel.
+	Note string
+	// Now is the clock that times layouts; nil means time.Now.
+	Now func() time.Time
+
+	visible bool
+	// samples holds the last Keep samples. Once it is full, the
+	// oldest is at next.
+	samples []Sample
+	next    int
+	// pending is the sample of the last frame, waiting for its
+	// profile.
+	pending *Sample
+	start   time.Time
+	reader  ops.Reader
+}
+
+var (
+	// PanelColor is the fill of the panel, and TextColor the color
+	// of its text.
+	PanelColor = color.RGBA{0, 0, 0, 200}
+	TextColor  = color.RGBA{230, 230, 230, 255}
+	// GraphColor fills the graph of the frame times, and
+	// BudgetColor marks the time of a frame at 60 frames a second.
+	GraphColor  = color.RGBA{70, 170, 90, 255}
+	BudgetColor = color.RGBA{200, 80, 60, 255}
+)
+
+const (
+	// budget is the time of a frame at 60 frames a second.
+	budget = time.Second / 60
+	// graphLen is the number of frames graphed.
+	graphLen = 120
+)
+
+// Visible reports whether the panel is shown.
+func (h *HUD) Visible() bool {
+	return h.visible
+}
+
+// Toggle shows or hides the panel. The samples are kept.
+func (h *HUD) Toggle() {
+	h.visible = !h.visible
+	h.pending = nil
+}
+
+func (h *HUD) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
+
+// Begin marks the start of laying out a frame.
+func (h *HUD) Begin() {
+	h.start = h.now()
+}
+
+// add keeps s, dropping the oldest sample once Keep are kept.
+func (h *HUD) add(s Sample) {
+	if len(h.samples) < Keep {
+		h.samples = append(h.samples, s)
+		return
+	}
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % Keep
+}
+
+// Samples returns the samples kept, oldest first.
+func (h *HUD) Samples() []Sample {
+	s := make([]Sample, 0, len(h.samples))
+	s = append(s, h.samples[h.next:]...)
+	return append(s, h.samples[:h.next]...)
+}
+
+// count returns the number of ops in o, and the bytes of the
+// distinct images they paint.
+func (h *HUD) count(o *op.Ops) (n, texture int) {
+	seen := make(map[*image.RGBA]bool)
+	h.reader.Reset(o)
+	for encOp, ok := h.reader.Decode(); ok; encOp, ok = h.reader.Decode() {
+		n++
+		if opconst.OpType(encOp.Data[0]) != opconst.TypeImage {
+			continue
+		}
+		src := ops.DecodeImageOp(encOp.Data, encOp.Refs).Src
+		if src != nil && !seen[src] {
+			seen[src] = true
+			texture += 4 * src.Rect.Dx() * src.Rect.Dy()
+		}
+	}
+	return n, texture
+}
+
+// Layout completes the sample of the frame before, measures this
+// one, and draws the panel in the top right corner of a window of
+// the given size. It does nothing while the panel is hidden.
+func (h *HUD) Layout(gtx *layout.Context, th *material.Theme, window image.Point) {
+	if !h.visible {
+		return
+	}
+	s := Sample{At: gtx.Now()}
+	if !h.start.IsZero() {
+		s.Layout = h.now().Sub(h.start)
+	}
+	s.Ops, s.Texture = h.count(gtx.Ops)
+	if h.pending != nil {
+		for _, e := range gtx.Events(h) {
+			if e, ok := e.(profile.Event); ok {
+				parseTimings(h.pending, e.Timings)
+			}
+		}
+		h.add(*h.pending)
+	}
+	h.pending = &s
+	profile.Op{Key: h}.Add(gtx.Ops)
+	h.draw(gtx, th, window)
+}
+
+// draw draws the panel: the figures of the last sample, and a graph
+// of the frame times.
+func (h *HUD) draw(gtx *layout.Context, th *material.Theme, window image.Point) {
+	samples := h.Samples()
+	var last Sample
+	if len(samples) > 0 {
+		last = samples[len(samples)-1]
+	}
+	var sum, max time.Duration
+	recent := samples
+	if len(recent) > graphLen {
+		recent = recent[len(recent)-graphLen:]
+	}
+	for _, s := range recent {
+		sum += s.Frame()
+		if s.Frame() > max {
+			max = s.Frame()
+		}
+	}
+	var avg time.Duration
+	if len(recent) > 0 {
+		avg = sum / time.Duration(len(recent))
+	}
+	gpu := "n/a"
+	if last.GPU > 0 {
+		gpu = ms(last.GPU)
+	}
+	lines := []string{
+		fmt.Sprintf("frame  %8s  avg %s max %s", ms(last.Frame()), ms(avg), ms(max)),
+		fmt.Sprintf("layout %8s  draw %s", ms(last.Layout), ms(last.Draw)),
+		fmt.Sprintf("gpu    %8s", gpu),
+		fmt.Sprintf("ops    %8d", last.Ops),
+		fmt.Sprintf("tex    %8s", mb(last.Texture)),
+	}
+	if h.Note != "" {
+		lines = append(lines, h.Note)
+	}
+
+	o := gtx.Ops
+	pad := gtx.Px(unit.Dp(8))
+	width := gtx.Px(unit.Dp(380))
+	lineHeight := gtx.Px(unit.Dp(16))
+	graphHeight := gtx.Px(unit.Dp(48))
+	size := image.Point{X: width, Y: 2*pad + len(lines)*lineHeight + pad + graphHeight}
+	pos := image.Point{X: window.X - size.X - pad, Y: pad}
+
+	var stack op.StackOp
+	stack.Push(o)
+	op.TransformOp{}.Offset(toPointF(pos)).Add(o)
+	paint.ColorOp{Color: PanelColor}.Add(o)
+	paint.PaintOp{Rect: f32.Rectangle{Max: toPointF(size)}}.Add(o)
+
+	textSize := unit.Dp(12)
+	for i, l := range lines {
+		var st op.StackOp
+		st.Push(o)
+		op.TransformOp{}.Offset(toPointF(image.Point{X: pad, Y: pad + i*lineHeight})).Add(o)
+		saved := gtx.Constraints
+		gtx.Constraints = layout.RigidConstraints(image.Point{X: width - 2*pad, Y: lineHeight})
+		lbl := th.Label(textSize, l)
+		lbl.Font.Variant = "Mono"
+		lbl.Color = TextColor
+		lbl.MaxLines = 1
+		lbl.Layout(gtx)
+		gtx.Constraints = saved
+		st.Pop()
+	}
+	graph := image.Rectangle{
+		Min: image.Point{X: pad, Y: size.Y - pad - graphHeight},
+		Max: image.Point{X: width - pad, Y: size.Y - pad},
+	}
+	drawGraph(o, graph, recent)
+	stack.Pop()
+}
+
+// drawGraph fills the area under the frame times of samples in r,
+// one point per frame from the right edge leftwards, on a scale of
+// at least two frame budgets, and marks the budget.
+func drawGraph(o *op.Ops, r image.Rectangle, samples []Sample) {
+	top := 2 * budget
+	for _, s := range samples {
+		if s.Frame() > top {
+			top = s.Frame()
+		}
+	}
+	y := func(d time.Duration) float32 {
+		return float32(r.Max.Y) - float32(r.Dy())*float32(d)/float32(top)
+	}
+	if len(samples) > 1 {
+		step := float32(r.Dx()) / float32(graphLen-1)
+		x0 := float32(r.Max.X) - step*float32(len(samples)-1)
+		var stack op.StackOp
+		stack.Push(o)
+		var p clip.Path
+		p.Begin(o)
+		start := f32.Point{X: x0, Y: float32(r.Max.Y)}
+		p.Move(start)
+		pen := start
+		for i, s := range samples {
+			pt := f32.Point{X: x0 + step*float32(i), Y: y(s.Frame())}
+			p.Line(pt.Sub(pen))
+			pen = pt
+		}
+		end := f32.Point{X: float32(r.Max.X), Y: float32(r.Max.Y)}
+		p.Line(end.Sub(pen))
+		p.Line(start.Sub(end))
+		p.End().Add(o)
+		paint.ColorOp{Color: GraphColor}.Add(o)
+		paint.PaintOp{Rect: toRectF(r)}.Add(o)
+		stack.Pop()
+	}
+	by := y(budget)
+	paint.ColorOp{Color: BudgetColor}.Add(o)
+	paint.PaintOp{Rect: f32.Rectangle{
+		Min: f32.Point{X: float32(r.Min.X), Y: by},
+		Max: f32.Point{X: float32(r.Max.X), Y: by + 1},
+	}}.Add(o)
+}
+
+// WriteCSV writes the samples kept as CSV, oldest first, with a
+// header row. Times are in milliseconds.
+func (h *HUD) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "frame_ms", "layout_ms", "total_ms", "draw_ms", "gpu_ms", "ops", "texture_bytes"})
+	for _, s := range h.Samples() {
+		cw.Write([]string{
+			s.At.Format(time.RFC3339Nano),
+			msf(s.Frame()), msf(s.Layout), msf(s.Total), msf(s.Draw), msf(s.GPU),
+			strconv.Itoa(s.Ops), strconv.Itoa(s.Texture),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// msf formats d as milliseconds, for CSV.
+func msf(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// ms formats d as milliseconds, for the panel.
+func ms(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64) + "ms"
+}
+
+// mb formats n bytes as megabytes.
+func mb(n int) string {
+	s := strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64)
+	return strings.TrimSuffix(s, ".0") + " MB"
+}
+
+func toPointF(p image.Point) f32.Point {
+	return f32.Point{X: float32(p.X), Y: float32(p.Y)}
+}
+
+func toRectF(r image.Rectangle) f32.Rectangle {
+	return f32.Rectangle{Min: toPointF(r.Min), Max: toPointF(r.Max)}
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package hud
+
+import (
+	"bytes"
+	"encoding/csv"
+	"image"
+	"sync"
+	"testing"
+	"time"
+
+	"gioui.org/font/gofont"
+	"gioui.org/io/event"
+	"gioui.org/io/profile"
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// config is a system.Config at one pixel per dp.
+type config struct {
+	now time.Time
+}
+
+func (c *config) Now() time.Time {
+	return c.now
+}
+
+func (c *config) Px(v unit.Value) int {
+	return int(v.V + .5)
+}
+
+// queue hands out, once, the events queued for each key.
+type queue map[event.Key][]event.Event
+
+func (q queue) Events(k event.Key) []event.Event {
+	evs := q[k]
+	delete(q, k)
+	return evs
+}
+
+var registerFonts sync.Once
+
+func TestParseTimings(t *testing.T) {
+	var s Sample
+	parseTimings(&s, "tot:  12.5ms draw:   3ms gpu: 950µs zt:     1ms st: bad cov: 0s")
+	if s.Total != 12500*time.Microsecond || s.Draw != 3*time.Millisecond || s.GPU != 950*time.Microsecond {
+		t.Errorf("parsed %+v", s)
+	}
+	s = Sample{}
+	parseTimings(&s, "tot:   8ms draw:   2ms")
+	if s.GPU != 0 || s.Frame() != 8*time.Millisecond {
+		t.Errorf("parsed %+v without gpu", s)
+	}
+}
+
+func TestHUD(t *testing.T) {
+	registerFonts.Do(gofont.Register)
+	q := make(queue)
+	gtx := layout.NewContext(q)
+	th := material.NewTheme()
+	start := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	cfg := &config{now: start}
+	clock := start
+	h := &HUD{Now: func() time.Time { return clock }}
+	img := paint.NewImageOp(image.NewRGBA(image.Rect(0, 0, 10, 20)))
+	window := image.Point{X: 800, Y: 600}
+	frame := func(i int) {
+		cfg.now = start.Add(time.Duration(i) * budget)
+		gtx.Reset(cfg, window)
+		h.Begin()
+		clock = clock.Add(time.Duration(i+1) * time.Millisecond)
+		// The same image painted twice is one texture.
+		img.Add(gtx.Ops)
+		img.Add(gtx.Ops)
+		h.Layout(gtx, th, window)
+	}
+
+	frame(0)
+	if len(h.Samples()) != 0 {
+		t.Fatal("a hidden HUD took a sample")
+	}
+	h.Toggle()
+	frame(0)
+	if len(h.Samples()) != 0 {
+		t.Fatal("a sample was kept before its profile could come")
+	}
+	q[h] = []event.Event{profile.Event{Timings: "tot:  20ms draw:   4ms"}}
+	frame(1)
+	got := h.Samples()
+	if len(got) != 1 {
+		t.Fatalf("%d samples, want 1", len(got))
+	}
+	s := got[0]
+	if s.Total != 20*time.Millisecond || s.Draw != 4*time.Millisecond || s.Layout != time.Millisecond {
+		t.Errorf("sample %+v", s)
+	}
+	if s.Texture != 4*10*20 || s.Ops < 2 {
+		t.Errorf("counted %d ops and %d texture bytes", s.Ops, s.Texture)
+	}
+	if !s.At.Equal(start) {
+		t.Errorf("sample at %v, want %v", s.At, start)
+	}
+
+	for i := 2; i < Keep+10; i++ {
+		frame(i)
+	}
+	got = h.Samples()
+	if len(got) != Keep {
+		t.Fatalf("%d samples kept, want %d", len(got), Keep)
+	}
+	if want := start.Add(9 * budget); !got[0].At.Equal(want) {
+		t.Errorf("oldest sample at %v, want %v", got[0].At, want)
+	}
+	if got[Keep-1].Layout != time.Duration(Keep+9)*time.Millisecond {
+		t.Errorf("newest sample took %v to lay out", got[Keep-1].Layout)
+	}
+
+	var buf bytes.Buffer
+	if err := h.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != Keep+1 {
+		t.Fatalf("%d CSV rows, want %d", len(recs), Keep+1)
+	}
+	if recs[0][1] != "frame_ms" || recs[Keep][2] != "609.000" || recs[Keep][7] != "800" {
+		t.Errorf("CSV header %v, last row %v", recs[0], recs[Keep])
+	}
+}
@@ -0,0 +1,267 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package inspect shows what a Gio op.Ops frame is made of, for
+// debugging clipping and offsets.
+//
+// Walk decodes the op list into a flat list of Nodes, one for every
+// op, in the order the renderer sees them. Each Node has the depth
+// at which it nests: StackOp pushes, MacroOps and CallOps each open
+// a level, and the ops inside them are one level deeper. The
+// renderer's drawing state is tracked along the way, so that
+// PaintOps, clip.Ops and pointer areas also carry their rectangle
+// in window coordinates. Dump writes the Nodes as an indented text
+// tree, and Outline draws the rectangles over a frame, coloured by
+// depth.
+package inspect
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+	"github.com/glycerine/hello_gio.go/internal/ops"
+)
+
+// Node is one op of a frame.
+type Node struct {
+	// Depth is the nesting depth of the op, from 0.
+	Depth int
+	// Type is the type of the op.
+	Type opconst.OpType
+	// Text describes the op, such as "color #ff0000ff".
+	Text string
+	// Rect, for a PaintOp, a clip.Op or a pointer.AreaOp, is the
+	// rectangle of the op in window coordinates, before clipping.
+	Rect f32.Rectangle
+}
+
+// HasRect reports whether n has a rectangle: whether it is a
+// PaintOp, a clip.Op or a pointer.AreaOp.
+func (n Node) HasRect() bool {
+	switch n.Type {
+	case opconst.TypePaint, opconst.TypeClip, opconst.TypeArea:
+		return true
+	}
+	return false
+}
+
+// String returns the text of n, indented two spaces per depth.
+func (n Node) String() string {
+	return strings.Repeat("  ", n.Depth) + n.Text
+}
+
+// scope is a level of nesting that Walk has opened.
+type scope struct {
+	typ opconst.OpType
+	// calls is the Reader depth inside the scope.
+	calls int
+	// saved is the offset to restore when a push scope is popped.
+	saved op.TransformOp
+}
+
+// Walk returns the ops of root, in order.
+func Walk(root *op.Ops) []Node {
+	var nodes []Node
+	rd := ops.Reader{Calls: true}
+	rd.Reset(root)
+	var (
+		scopes []scope
+		t      op.TransformOp
+	)
+	for encOp, ok := rd.Decode(); ok; encOp, ok = rd.Decode() {
+		typ := opconst.OpType(encOp.Data[0])
+		calls := rd.Depth()
+		if typ == opconst.TypeMacro || typ == opconst.TypeCall {
+			// The Reader is already inside.
+			calls--
+		}
+		// Close the macros and calls that have returned, and the
+		// pushes left open in them.
+		for len(scopes) > 0 && scopes[len(scopes)-1].calls > calls {
+			scopes = scopes[:len(scopes)-1]
+		}
+		n := Node{Depth: len(scopes), Type: typ}
+		off := t.Transform(f32.Point{})
+		switch typ {
+		case opconst.TypeMacro, opconst.TypeCall:
+			n.Text = typ.String()
+			scopes = append(scopes, scope{typ: typ, calls: calls + 1})
+		case opconst.TypePush:
+			n.Text = "push"
+			scopes = append(scopes, scope{typ: typ, calls: calls, saved: t})
+		case opconst.TypePop:
+			n.Text = "pop"
+			if i := lastPush(scopes); i >= 0 {
+				t = scopes[i].saved
+				scopes = scopes[:i]
+				n.Depth = i
+			}
+		case opconst.TypeTransform:
+			d := ops.DecodeTransformOp(encOp.Data).Transform(f32.Point{})
+			t = t.Offset(d)
+			n.Text = fmt.Sprintf("transform %s to %s", point(d), point(t.Transform(f32.Point{})))
+		case opconst.TypeClip:
+			n.Rect = ops.DecodeClipOp(encOp.Data).Add(off)
+			n.Text = "clip " + rect(n.Rect)
+		case opconst.TypeAux:
+			n.Text = fmt.Sprintf("path %d segments", len(ops.DecodePath(encOp.Data)))
+		case opconst.TypeColor:
+			c := ops.DecodeColorOp(encOp.Data)
+			n.Text = fmt.Sprintf("color #%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+		case opconst.TypeImage:
+			src := ops.DecodeImageOp(encOp.Data, encOp.Refs).Src
+			n.Text = fmt.Sprintf("image %dx%d", src.Rect.Dx(), src.Rect.Dy())
+		case opconst.TypePaint:
+			n.Rect = ops.DecodePaintOp(encOp.Data).Rect.Add(off)
+			n.Text = "paint " + rect(n.Rect)
+		case opconst.TypeArea:
+			kind, r := decodeAreaOp(encOp.Data)
+			n.Rect = r.Add(off)
+			n.Text = fmt.Sprintf("area %s %s", kind, rect(n.Rect))
+		case opconst.TypePointerInput:
+			n.Text = "pointer input " + keyName(encOp.Refs[0])
+			if encOp.Data[1] != 0 {
+				n.Text += " grab"
+			}
+		case opconst.TypeKeyInput:
+			n.Text = "key input " + keyName(encOp.Refs[0])
+			if encOp.Data[1] != 0 {
+				n.Text += " focus"
+			}
+		case opconst.TypePass:
+			n.Text = fmt.Sprintf("pass %t", encOp.Data[1] != 0)
+		case opconst.TypeProfile:
+			n.Text = "profile " + keyName(encOp.Refs[0])
+		case opconst.TypeInvalidate:
+			n.Text = "invalidate"
+			if nanos := binary.LittleEndian.Uint64(encOp.Data[1:]); nanos > 0 {
+				n.Text += " at " + time.Unix(0, int64(nanos)).UTC().Format(time.RFC3339Nano)
+			}
+		default:
+			n.Text = typ.String()
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// lastPush returns the index of the innermost push in scopes that
+// is not outside a macro or call, or -1.
+func lastPush(scopes []scope) int {
+	for i := len(scopes) - 1; i >= 0; i-- {
+		switch scopes[i].typ {
+		case opconst.TypePush:
+			return i
+		case opconst.TypeMacro, opconst.TypeCall:
+			return -1
+		}
+	}
+	return -1
+}
+
+// keyName describes the handler key k: by its String method if it
+// has one, or else by its type.
+func keyName(k interface{}) string {
+	if s, ok := k.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", k)
+}
+
+// decodeAreaOp returns the kind and the rectangle of a
+// pointer.AreaOp.
+func decodeAreaOp(d []byte) (string, f32.Rectangle) {
+	bo := binary.LittleEndian
+	c := func(off int) float32 {
+		return float32(int32(bo.Uint32(d[off:])))
+	}
+	kind := "rect"
+	if d[1] == 1 {
+		kind = "ellipse"
+	}
+	return kind, f32.Rectangle{
+		Min: f32.Point{X: c(2), Y: c(6)},
+		Max: f32.Point{X: c(10), Y: c(14)},
+	}
+}
+
+// Dump writes the ops of root to w as a tree, one op a line.
+func Dump(w io.Writer, root *op.Ops) error {
+	bw := bufio.NewWriter(w)
+	for _, n := range Walk(root) {
+		bw.WriteString(n.String())
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// Colors are the outline colors of the depths, in turn.
+var Colors = []color.RGBA{
+	{R: 0xe6, G: 0x19, B: 0x4b, A: 0xff},
+	{R: 0xf5, G: 0x82, B: 0x31, A: 0xff},
+	{R: 0x3c, G: 0xb4, B: 0x4b, A: 0xff},
+	{R: 0x42, G: 0xd4, B: 0xf4, A: 0xff},
+	{R: 0x43, G: 0x63, B: 0xd8, A: 0xff},
+	{R: 0x91, G: 0x1e, B: 0xb4, A: 0xff},
+}
+
+// Color returns the outline color of depth.
+func Color(depth int) color.RGBA {
+	return Colors[depth%len(Colors)]
+}
+
+// Outline adds ops to o that outline the rectangles of nodes in
+// window coordinates: PaintOps one pixel wide, clips two, and
+// pointer areas not at all, each in the Color of its depth. Add it
+// at the top level of a frame, where no transform applies.
+func Outline(o *op.Ops, nodes []Node) {
+	var stack op.StackOp
+	stack.Push(o)
+	for _, n := range nodes {
+		var width float32
+		switch n.Type {
+		case opconst.TypePaint:
+			width = 1
+		case opconst.TypeClip:
+			width = 2
+		default:
+			continue
+		}
+		paint.ColorOp{Color: Color(n.Depth)}.Add(o)
+		frame(o, n.Rect, width)
+	}
+	stack.Pop()
+}
+
+// frame paints the border of r, width wide, inside r.
+func frame(o *op.Ops, r f32.Rectangle, width float32) {
+	if r.Dx() < 2*width || r.Dy() < 2*width {
+		paint.PaintOp{Rect: r}.Add(o)
+		return
+	}
+	for _, side := range []f32.Rectangle{
+		{Min: r.Min, Max: f32.Point{X: r.Max.X, Y: r.Min.Y + width}},
+		{Min: f32.Point{X: r.Min.X, Y: r.Max.Y - width}, Max: r.Max},
+		{Min: f32.Point{X: r.Min.X, Y: r.Min.Y + width}, Max: f32.Point{X: r.Min.X + width, Y: r.Max.Y - width}},
+		{Min: f32.Point{X: r.Max.X - width, Y: r.Min.Y + width}, Max: f32.Point{X: r.Max.X, Y: r.Max.Y - width}},
+	} {
+		paint.PaintOp{Rect: side}.Add(o)
+	}
+}
+
+func point(p f32.Point) string {
+	return "(" + ops.Num(p.X) + "," + ops.Num(p.Y) + ")"
+}
+
+func rect(r f32.Rectangle) string {
+	return point(r.Min) + "-" + point(r.Max)
+}
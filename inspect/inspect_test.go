@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package inspect
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+)
+
+type handler struct{}
+
+func testOps() *op.Ops {
+	ops := new(op.Ops)
+	var stack op.StackOp
+	stack.Push(ops)
+	op.TransformOp{}.Offset(f32.Point{X: 10, Y: 20}).Add(ops)
+	clip.Rect{Rect: f32.Rectangle{Max: f32.Point{X: 50, Y: 40}}}.Op(ops).Add(ops)
+
+	// A macro recorded at one offset and played at another.
+	var m op.MacroOp
+	m.Record(ops)
+	paint.ColorOp{Color: color.RGBA{R: 0xff, A: 0xff}}.Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 5, Y: 5}}}.Add(ops)
+	m.Stop()
+	op.TransformOp{}.Offset(f32.Point{X: 1.5}).Add(ops)
+	m.Add()
+
+	pointer.Rect(image.Rect(0, 0, 8, 8)).Add(ops)
+	pointer.InputOp{Key: new(handler), Grab: true}.Add(ops)
+	stack.Pop()
+
+	sub := new(op.Ops)
+	paint.NewImageOp(image.NewRGBA(image.Rect(0, 0, 3, 2))).Add(sub)
+	paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 3, Y: 2}}}.Add(sub)
+	op.CallOp{Ops: sub}.Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 1, Y: 1}}}.Add(ops)
+	return ops
+}
+
+func TestDump(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Dump(&buf, testOps()); err != nil {
+		t.Fatal(err)
+	}
+	const want = `push
+  transform (10,20) to (10,20)
+  clip (10,20)-(60,60)
+  transform (1.5,0) to (11.5,20)
+  macro
+    color #ff0000ff
+    paint (11.5,20)-(16.5,25)
+  area rect (11.5,20)-(19.5,28)
+  pointer input *inspect.handler grab
+pop
+call
+  image 3x2
+  paint (0,0)-(3,2)
+paint (0,0)-(1,1)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	nodes := Walk(testOps())
+	var rects int
+	for _, n := range nodes {
+		if n.HasRect() {
+			rects++
+		}
+		if n.Type == opconst.TypePaint && n.Depth == 2 && n.Rect != (f32.Rectangle{Min: f32.Point{X: 11.5, Y: 20}, Max: f32.Point{X: 16.5, Y: 25}}) {
+			t.Errorf("macro paint at %v", n.Rect)
+		}
+	}
+	if rects != 5 {
+		t.Errorf("%d nodes with rects, want 5", rects)
+	}
+	o := new(op.Ops)
+	Outline(o, nodes)
+	var colors []string
+	for _, n := range Walk(o) {
+		if n.Type == opconst.TypeColor {
+			colors = append(colors, n.Text)
+		}
+	}
+	// The clip and the three paints, at depths 1, 2, 1 and 0.
+	want := []string{"color #f58231ff", "color #3cb44bff", "color #f58231ff", "color #e6194bff"}
+	if len(colors) != len(want) {
+		t.Fatalf("outline colors %v, want %v", colors, want)
+	}
+	for i := range want {
+		if colors[i] != want[i] {
+			t.Errorf("outline colors %v, want %v", colors, want)
+			break
+		}
+	}
+}
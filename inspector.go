@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// The op inspector. Ctrl+O (Cmd+O on a Mac) outlines every PaintOp
+// and clip of the frame, coloured by how deeply it nests, and
+// Ctrl+Shift+O writes the ops of the frame as a text tree next to
+// the saved frames. render writes the same tree for a .txt file.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"path/filepath"
+	"time"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/inspect"
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+var inspectLog = vlog.New("inspect")
+
+// opInspector outlines the ops of the frames and dumps them.
+type opInspector struct {
+	// dir is where the dumps go.
+	dir string
+	// now returns the time that names the files.
+	now func() time.Time
+
+	overlay bool
+	// dumpNext asks for the next frame to be dumped.
+	dumpNext bool
+	// note announces the last dump until noteUntil.
+	note      string
+	noteUntil time.Time
+}
+
+// newOpInspector writes its dumps into dir.
+func newOpInspector(dir string) *opInspector {
+	return &opInspector{dir: dir, now: time.Now}
+}
+
+// inspectNoteStyle is the look of the note in the corner. Its sizes
+// are in dp.
+var inspectNoteStyle = box.Box{
+	Size:         image.Point{X: 540, Y: 30},
+	Fill:         color.RGBA{0, 0, 0, 180},
+	CornerRadius: 4,
+	Padding:      6,
+	TextColor:    color.RGBA{255, 255, 255, 255},
+	Ellipsis:     true,
+}
+
+// Key handles the hotkeys. It reports whether e was one of them.
+func (in *opInspector) Key(e key.Event) bool {
+	if e.Name != "O" || !(e.Modifiers.Contain(key.ModCtrl) || e.Modifiers.Contain(key.ModCommand)) {
+		return false
+	}
+	if e.Modifiers.Contain(key.ModShift) {
+		in.dumpNext = true
+	} else {
+		in.overlay = !in.overlay
+	}
+	return true
+}
+
+// Layout dumps the ops laid out so far into gtx, if asked to, and
+// outlines them over a window of the given size. Lay it out right
+// after the frame, before the other overlays, which it leaves out.
+// Saved frames keep the outlines.
+func (in *opInspector) Layout(gtx *layout.Context, th *material.Theme, window image.Point) {
+	now := gtx.Now()
+	if in.dumpNext {
+		in.dumpNext = false
+		in.dump(gtx.Ops)
+		in.noteUntil = now.Add(noteDuration)
+	}
+	if in.note != "" && !now.Before(in.noteUntil) {
+		in.note = ""
+	}
+	var label string
+	if in.overlay {
+		nodes := inspect.Walk(gtx.Ops)
+		var paints, clips int
+		for _, n := range nodes {
+			switch n.Type {
+			case opconst.TypePaint:
+				paints++
+			case opconst.TypeClip:
+				clips++
+			}
+		}
+		inspect.Outline(gtx.Ops, nodes)
+		label = fmt.Sprintf("%d ops, %d paints, %d clips", len(nodes), paints, clips)
+	}
+	if in.note != "" {
+		if label != "" {
+			label += " · "
+		}
+		label += in.note
+		op.InvalidateOp{At: in.noteUntil}.Add(gtx.Ops)
+	}
+	if label == "" {
+		return
+	}
+	b := dpBox(gtx, inspectNoteStyle)
+	b.TextSize = th.TextSize.Scale(.85)
+	margin := dpPx(gtx, 10)
+	r := b.Layout(gtx, th, image.Point{X: window.X - b.Size.X - margin, Y: window.Y - b.Size.Y - margin}, label)
+	if !in.overlay {
+		return
+	}
+	// A swatch per depth, from 0, before the note.
+	sw := b.Size.Y / 2
+	gap := dpPx(gtx, 2)
+	for i := range inspect.Colors {
+		min := image.Point{X: r.Min.X - 3*gap - (len(inspect.Colors)-i)*(sw+gap), Y: r.Min.Y + (b.Size.Y-sw)/2}
+		paint.ColorOp{Color: inspect.Color(i)}.Add(gtx.Ops)
+		paint.PaintOp{Rect: toRectF(image.Rectangle{Min: min, Max: min.Add(image.Point{X: sw, Y: sw})})}.Add(gtx.Ops)
+	}
+}
+
+// dump writes the ops of a frame to a timestamped text file.
+func (in *opInspector) dump(ops *op.Ops) {
+	path := outputPath(in.dir, "ops", in.now(), ".txt")
+	if err := writeFile(path, func(w io.Writer) error { return inspect.Dump(w, ops) }); err != nil {
+		inspectLog.Warn("dump failed", "path", path, "err", err)
+		in.note = fmt.Sprintf("dumping failed: %v", err)
+		return
+	}
+	inspectLog.Info("dumped ops", "path", path)
+	in.note = "dumped " + filepath.Base(path)
+}
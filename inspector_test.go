@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gioui.org/io/key"
+
+	"github.com/glycerine/hello_gio.go/raster"
+)
+
+func TestGoldenInspector(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	q := make(scriptQueue)
+	m := loadedDrawState(q, testScene(t))
+	th := testTheme()
+	e := testFrame()
+	in := newOpInspector(dir)
+	in.now = func() time.Time { return time.Date(2019, 10, 1, 12, 30, 5, 250e6, time.UTC) }
+	frame := func() {
+		drawFrame(m, th, e)
+		in.Layout(m.gtx, th, e.Size)
+	}
+
+	if in.Key(key.Event{Name: "O"}) {
+		t.Error("O without Ctrl was taken")
+	}
+	in.Key(key.Event{Name: "O", Modifiers: key.ModCtrl | key.ModShift})
+	if in.overlay {
+		t.Error("Ctrl+Shift+O turned the overlay on")
+	}
+	frame()
+	path := filepath.Join(dir, "hello_gio-ops-20191001-123005.250.txt")
+	tree, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The dump is of the frame alone.
+	if !strings.HasPrefix(string(tree), "color ") || strings.Contains(string(tree), "hello_gio-ops") {
+		t.Errorf("dumped\n%s", tree)
+	}
+	if want := "dumped " + filepath.Base(path); in.note != want {
+		t.Errorf("note is %q, want %q", in.note, want)
+	}
+
+	in.Key(key.Event{Name: "O", Modifiers: key.ModCtrl})
+	frame()
+	checkGolden(t, "inspector", raster.Render(m.gtx.Ops, e.Size))
+}
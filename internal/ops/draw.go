@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package ops
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// State is the drawing state saved and restored by StackOp, as the
+// op walkers outside Gio track it.
+type State struct {
+	// Color and Img are the fill of PaintOps: Img if it is set,
+	// or else Color.
+	Color color.RGBA
+	Img   *image.RGBA
+}
+
+// IsLine reports whether q is a line, stored as a quad with its
+// control point halfway.
+func IsLine(q Quad) bool {
+	mid := q.From.Add(q.To).Mul(.5)
+	d := q.Ctrl.Sub(mid)
+	return d.X*d.X+d.Y*d.Y < 1e-6
+}
+
+// Num formats v rounded to a hundredth of a pixel, which is finer
+// than anything a screen or a printer shows.
+func Num(v float32) string {
+	return Decimal(float64(v), 2)
+}
+
+// Decimal formats v with at most the given number of decimal
+// places, never as -0 and never in exponent form.
+func Decimal(v float64, places int) string {
+	p := math.Pow(10, float64(places))
+	r := math.Round(v*p) / p
+	if r == 0 {
+		// Not -0.
+		r = 0
+	}
+	return strconv.FormatFloat(r, 'f', -1, 64)
+}
+
+// Unmul undoes the premultiplication of a color channel c by
+// alpha a.
+func Unmul(c, a uint8) uint8 {
+	if a == 0 {
+		return 0
+	}
+	v := (int(c)*255 + int(a)/2) / int(a)
+	if v > 255 {
+		v = 255
+	}
+	return uint8(v)
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package ops mirrors gioui.org/internal/ops, so that hello_gio can
+// walk an op.Ops list itself. The Gio package is internal and
+// cannot be imported from outside the gioui.org module, so the
+// Reader and the op decoders are shadowed here, in the same way
+// app/internal/gpu shadows the unexported op types it decodes.
+package ops
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+)
+
+// VertStride is the size in bytes of one encoded clip.Path vertex.
+// It matches gioui.org/internal/path.VertStride.
+const VertStride = 7*4 + 2*2
+
+// Quad is a quadratic Bézier segment of a clip.Path. Lines are
+// encoded as degenerate quads and cubics are approximated by
+// quads when the path is built, so every path is a list of Quads.
+type Quad struct {
+	From, Ctrl, To f32.Point
+}
+
+// ImageOp is the shadow of paint.ImageOp.
+type ImageOp struct {
+	Src    *image.RGBA
+	Handle interface{}
+}
+
+func DecodeTransformOp(d []byte) op.TransformOp {
+	bo := binary.LittleEndian
+	if opconst.OpType(d[0]) != opconst.TypeTransform {
+		panic("invalid op")
+	}
+	return op.TransformOp{}.Offset(f32.Point{
+		X: math.Float32frombits(bo.Uint32(d[1:])),
+		Y: math.Float32frombits(bo.Uint32(d[5:])),
+	})
+}
+
+// DecodeClipOp returns the bounds of a clip.Op.
+func DecodeClipOp(d []byte) f32.Rectangle {
+	if opconst.OpType(d[0]) != opconst.TypeClip {
+		panic("invalid op")
+	}
+	return decodeRect(d[1:])
+}
+
+func DecodeColorOp(d []byte) color.RGBA {
+	if opconst.OpType(d[0]) != opconst.TypeColor {
+		panic("invalid op")
+	}
+	return color.RGBA{
+		R: d[1],
+		G: d[2],
+		B: d[3],
+		A: d[4],
+	}
+}
+
+func DecodeImageOp(d []byte, refs []interface{}) ImageOp {
+	if opconst.OpType(d[0]) != opconst.TypeImage {
+		panic("invalid op")
+	}
+	handle := refs[1]
+	if handle == nil {
+		panic("nil handle")
+	}
+	return ImageOp{
+		Src:    refs[0].(*image.RGBA),
+		Handle: handle,
+	}
+}
+
+func DecodePaintOp(d []byte) paint.PaintOp {
+	if opconst.OpType(d[0]) != opconst.TypePaint {
+		panic("invalid op")
+	}
+	return paint.PaintOp{
+		Rect: decodeRect(d[1:]),
+	}
+}
+
+// DecodePath returns the segments of the clip.Path stored in the
+// data of a TypeAux op. The renderer may have overwritten the
+// contour indices with MaxY values; they are not needed to
+// recover the geometry.
+func DecodePath(d []byte) []Quad {
+	if opconst.OpType(d[0]) != opconst.TypeAux {
+		panic("invalid op")
+	}
+	// Skip the opcode and the byte marking whether MaxY is filled.
+	d = d[opconst.TypeAuxLen+1:]
+	bo := binary.LittleEndian
+	// Every quad is stored as four vertices, one per corner of its
+	// bounding box, all carrying the same curve.
+	const quadStride = 4 * VertStride
+	quads := make([]Quad, 0, len(d)/quadStride)
+	for ; len(d) >= quadStride; d = d[quadStride:] {
+		f := func(off int) float32 {
+			return math.Float32frombits(bo.Uint32(d[off:]))
+		}
+		quads = append(quads, Quad{
+			From: f32.Point{X: f(8), Y: f(12)},
+			Ctrl: f32.Point{X: f(16), Y: f(20)},
+			To:   f32.Point{X: f(24), Y: f(28)},
+		})
+	}
+	return quads
+}
+
+func decodeRect(d []byte) f32.Rectangle {
+	bo := binary.LittleEndian
+	return f32.Rectangle{
+		Min: f32.Point{
+			X: math.Float32frombits(bo.Uint32(d[0:])),
+			Y: math.Float32frombits(bo.Uint32(d[4:])),
+		},
+		Max: f32.Point{
+			X: math.Float32frombits(bo.Uint32(d[8:])),
+			Y: math.Float32frombits(bo.Uint32(d[12:])),
+		},
+	}
+}
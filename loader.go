@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// Image files are decoded, and cropped and copied ready to draw, by
+// an imageLoader, on worker goroutines,
+// so that neither the first frame nor stepping through the viewer
+// waits for a decode. Until its file is decoded, a scene image
+// shows a spinner where it will go; if the decode fails, it shows
+// the error there instead.
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+// loadWorkers is how many files are decoded at the same time.
+var loadWorkers = runtime.NumCPU()
+
+var loaderLog = vlog.New("loader")
+
+// loadResult is the outcome of decoding the file of one image.
+type loadResult struct {
+	si     *sceneImage
+	loaded loadedImage
+	err    error
+}
+
+// imageLoader decodes image files for scene images. Decodes run on
+// goroutines of their own, at most loadWorkers at a time, and their
+// results are handed to the images by apply, on the UI goroutine.
+type imageLoader struct {
+	// sem holds a token for every decode running.
+	sem chan struct{}
+
+	mu sync.Mutex
+	// changed, if set, is called on the decoding goroutine after
+	// each decode. Set it to w.Invalidate.
+	changed func()
+	done    []loadResult
+	// pending counts the decodes queued or running.
+	pending sync.WaitGroup
+}
+
+func newImageLoader() *imageLoader {
+	return &imageLoader{sem: make(chan struct{}, loadWorkers)}
+}
+
+// setChanged makes l call f after each decode. Decodes that
+// finished before are announced at once.
+func (l *imageLoader) setChanged(f func()) {
+	l.mu.Lock()
+	l.changed = f
+	done := len(l.done)
+	l.mu.Unlock()
+	if done > 0 && f != nil {
+		f()
+	}
+}
+
+// load starts decoding the file at path for si.
+func (l *imageLoader) load(si *sceneImage, path string) {
+	l.start(si, func() (image.Image, error) {
+		img, _, err := LoadImage(path)
+		if err != nil {
+			loaderLog.Warn("decode failed", "path", path, "err", err)
+		} else {
+			loaderLog.Debug("decoded", "path", path)
+		}
+		return img, err
+	})
+}
+
+// prepare starts making img, decoded already, ready for si.
+func (l *imageLoader) prepare(si *sceneImage, img image.Image) {
+	l.start(si, func() (image.Image, error) { return img, nil })
+}
+
+// start runs decode on a goroutine of its own, once there is a free
+// worker, and makes what it returns ready to draw for si.
+func (l *imageLoader) start(si *sceneImage, decode func() (image.Image, error)) {
+	l.pending.Add(1)
+	go func() {
+		defer l.pending.Done()
+		l.sem <- struct{}{}
+		r := loadResult{si: si}
+		var img image.Image
+		img, r.err = decode()
+		if r.err == nil {
+			r.loaded = newLoadedImage(si.spec, img)
+		}
+		<-l.sem
+		l.mu.Lock()
+		l.done = append(l.done, r)
+		changed := l.changed
+		l.mu.Unlock()
+		if changed != nil {
+			changed()
+		}
+	}()
+}
+
+// apply gives the decoded files, ready to draw, to their images. An
+// image whose file failed to decode keeps what it showed before, if
+// anything, along with the error.
+func (l *imageLoader) apply() {
+	l.mu.Lock()
+	done := l.done
+	l.done = nil
+	l.mu.Unlock()
+	for _, r := range done {
+		if r.err != nil {
+			r.si.err = r.err
+			continue
+		}
+		r.si.setImage(r.loaded)
+	}
+}
+
+// wait waits for the decodes started so far. Drawing without a
+// window, call it, then apply, before the first frame.
+func (l *imageLoader) wait() {
+	l.pending.Wait()
+}
+
+// placeholderSize stands in for the size of an image that has not
+// loaded, to place its spinner or error tile.
+var placeholderSize = image.Point{X: 320, Y: 240}
+
+var (
+	placeholderFill = color.RGBA{232, 232, 232, 255}
+	spinnerColor    = color.RGBA{90, 90, 90, 255}
+	// errorTileStyle is the look of the tile in place of an image
+	// that failed to load. Its sizes are in dp, but for its Size,
+	// which is the image's.
+	errorTileStyle = box.Box{
+		Fill:        color.RGBA{255, 235, 235, 255},
+		StrokeWidth: 2,
+		Stroke:      color.RGBA{200, 30, 30, 255},
+		Padding:     10,
+		TextColor:   color.RGBA{150, 20, 20, 255},
+		Wrap:        true,
+		Ellipsis:    true,
+	}
+)
+
+const (
+	// spinnerDots is the number of dots around the spinner, and
+	// spinnerStep how long each leads.
+	spinnerDots = 12
+	spinnerStep = 80 * time.Millisecond
+)
+
+// layoutLoading fills the place of si, which is still loading, and
+// draws a spinner in its middle.
+func (si *sceneImage) layoutLoading(gtx *layout.Context) {
+	ops := gtx.Ops
+	view := si.place.View
+	paint.ColorOp{Color: placeholderFill}.Add(ops)
+	paint.PaintOp{Rect: toRectF(view)}.Add(ops)
+
+	radius := float32(gtx.Px(unit.Dp(20)))
+	if m := float32(min(view.Dx(), view.Dy())) / 3; m < radius {
+		radius = m
+	}
+	dot := radius / 4
+	if dot < 1 {
+		return
+	}
+	center := toPointF(view.Min.Add(view.Max).Div(2))
+	now := gtx.Now()
+	lead := int(now.UnixNano()/int64(spinnerStep)) % spinnerDots
+	for i := 0; i < spinnerDots; i++ {
+		// The dots fade behind the leading one, clockwise.
+		age := (lead - i + spinnerDots) % spinnerDots
+		c := spinnerColor
+		c.A = uint8(255 - age*200/spinnerDots)
+		// Premultiply.
+		c.R = uint8(int(c.R) * int(c.A) / 255)
+		c.G = uint8(int(c.G) * int(c.A) / 255)
+		c.B = uint8(int(c.B) * int(c.A) / 255)
+		a := 2 * math.Pi * float64(i) / spinnerDots
+		p := center.Add(f32.Point{
+			X: (radius - dot) * float32(math.Sin(a)),
+			Y: -(radius - dot) * float32(math.Cos(a)),
+		})
+		r := f32.Rectangle{
+			Min: p.Sub(f32.Point{X: dot / 2, Y: dot / 2}),
+			Max: p.Add(f32.Point{X: dot / 2, Y: dot / 2}),
+		}
+		var stack op.StackOp
+		stack.Push(ops)
+		h := dot / 2
+		clip.Rect{Rect: r, SE: h, SW: h, NW: h, NE: h}.Op(ops).Add(ops)
+		paint.ColorOp{Color: c}.Add(ops)
+		paint.PaintOp{Rect: r}.Add(ops)
+		stack.Pop()
+	}
+	next := now.Truncate(spinnerStep).Add(spinnerStep)
+	op.InvalidateOp{At: next}.Add(ops)
+}
+
+// layoutError draws the error of si, which failed to load, in its
+// place.
+func (si *sceneImage) layoutError(gtx *layout.Context, th *material.Theme) {
+	b := dpBox(gtx, errorTileStyle)
+	b.Size = si.place.View.Size()
+	b.TextSize = th.TextSize.Scale(.85)
+	b.Layout(gtx, th, si.place.View.Min, "Could not load "+si.spec.Path+":\n"+si.err.Error())
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
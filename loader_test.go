@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/glycerine/hello_gio.go/raster"
+	"github.com/glycerine/hello_gio.go/scene"
+)
+
+func TestImageLoader(t *testing.T) {
+	sc := testScene(t)
+	sc.Images = append(sc.Images, scene.Image{Path: "missing.png"})
+	m := newDrawState(nil, sc)
+	var changed int32
+	m.loader.setChanged(func() { atomic.AddInt32(&changed, 1) })
+	m.loader.wait()
+	if n := atomic.LoadInt32(&changed); n == 0 {
+		t.Error("the window was not woken after the decodes")
+	}
+	for _, si := range m.images {
+		if si.img != nil || si.err != nil {
+			t.Fatalf("%s was given its result before apply", si.spec.Path)
+		}
+	}
+	m.loader.apply()
+	if si := m.images[0]; si.img == nil || si.err != nil {
+		t.Errorf("points.png did not load: %v", si.err)
+	}
+	if si := m.images[1]; si.img != nil || si.err == nil {
+		t.Error("no error for a missing file")
+	}
+}
+
+// TestGoldenLoading covers the spinner in place of an image that is
+// still loading.
+func TestGoldenLoading(t *testing.T) {
+	sc := testScene(t)
+	sc.Background = ""
+	m := loadedDrawState(nil, sc)
+	// An image whose decode never comes back.
+	m.images = append(m.images, &sceneImage{spec: &scene.Image{
+		Path: "slow.png",
+		Dest: scene.Rect{X: 20, Y: 500, W: 240},
+	}})
+	e := testFrame()
+	showImage(e, m)
+	checkGolden(t, "loading", raster.Render(m.gtx.Ops, e.Size))
+}
@@ -0,0 +1,571 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package opfile saves Gio op.Ops frames to files and loads them
+// back, for rendering offline, diffing and bug reports.
+//
+// An op list is a byte string of encoded ops plus a list of Go
+// values the ops refer to: the images of ImageOps, the handler keys
+// of input ops, and the op lists of CallOps. The bytes are written
+// as they are, so a loaded frame replays exactly as it was drawn,
+// macros included. The references are replaced by numbers: images
+// are stored inline, keys by a stable ID, numbered in the order the
+// ops first use them, and called op lists are stored after the
+// root. Loading makes a *Key for every key ID, so that the input
+// ops of a loaded frame still tell their handlers apart.
+//
+// A file is, with every number an unsigned varint:
+//
+//	"hello_gio ops\n"   the magic
+//	version             Version
+//	width height        the frame size in pixels
+//	images              the number of images, then for each its
+//	                    width and height, and the length and bytes
+//	                    of its premultiplied RGBA pixels, compressed
+//	                    with zlib
+//	keys                the number of keys, then for each the length
+//	                    and bytes of the Go type of the key
+//	handles             the number of image handles
+//	lists               the number of op lists, the root first, then
+//	                    for each the length and bytes of its ops,
+//	                    and the number of its references, each a
+//	                    kind byte and an index
+//
+// The ops are those of the vendored Gio. A file of a later Version
+// is refused rather than misread.
+package opfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	"gioui.org/op"
+
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+)
+
+// Magic starts every file.
+const Magic = "hello_gio ops\n"
+
+// Version is the version of the format that Encode writes. Decode
+// reads it and every earlier version.
+const Version = 1
+
+// Limits on what Decode accepts, against corrupt files.
+const (
+	maxPixels = 1 << 26
+	maxBytes  = 1 << 30
+	maxCount  = 1 << 20
+)
+
+// maxTotal is the most bytes Decode reads into memory and decodes
+// the pixels of the images to, all told. It is a variable so that
+// the tests can lower it.
+var maxTotal = maxBytes
+
+// The kinds of references.
+const (
+	refNil byte = iota
+	refImage
+	refHandle
+	refKey
+	refList
+)
+
+// Key stands in for a handler key of a saved frame.
+type Key struct {
+	// ID numbers the keys in the order the ops first use them.
+	ID int
+	// Type is the Go type of the original key, such as
+	// "*main.imageView".
+	Type string
+}
+
+// String returns the type and the ID of k, as in
+// "*main.imageView#3".
+func (k *Key) String() string {
+	return fmt.Sprintf("%s#%d", k.Type, k.ID)
+}
+
+// encoder numbers the references of a frame.
+type encoder struct {
+	images  []*image.RGBA
+	imageID map[*image.RGBA]int
+	handles map[interface{}]int
+	keys    []string
+	keyID   map[interface{}]int
+	lists   []*op.Ops
+	listID  map[*op.Ops]int
+	// refs are the encoded references of every list.
+	refs [][]ref
+}
+
+type ref struct {
+	kind byte
+	id   int
+}
+
+// Encode writes the frame in root, of the given size, to w.
+func Encode(w io.Writer, root *op.Ops, size image.Point) error {
+	e := &encoder{
+		imageID: make(map[*image.RGBA]int),
+		handles: make(map[interface{}]int),
+		keyID:   make(map[interface{}]int),
+		listID:  make(map[*op.Ops]int),
+	}
+	e.list(root)
+	// Lists are added while they are numbered.
+	for i := 0; i < len(e.lists); i++ {
+		refs, err := e.number(e.lists[i])
+		if err != nil {
+			return fmt.Errorf("op list %d: %v", i, err)
+		}
+		e.refs = append(e.refs, refs)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(Magic)
+	uvarint(bw, Version)
+	uvarint(bw, size.X)
+	uvarint(bw, size.Y)
+	uvarint(bw, len(e.images))
+	for _, img := range e.images {
+		if err := writeImage(bw, img); err != nil {
+			return err
+		}
+	}
+	uvarint(bw, len(e.keys))
+	for _, k := range e.keys {
+		uvarint(bw, len(k))
+		bw.WriteString(k)
+	}
+	uvarint(bw, len(e.handles))
+	uvarint(bw, len(e.lists))
+	for i, l := range e.lists {
+		uvarint(bw, len(l.Data()))
+		bw.Write(l.Data())
+		uvarint(bw, len(e.refs[i]))
+		for _, r := range e.refs[i] {
+			bw.WriteByte(r.kind)
+			uvarint(bw, r.id)
+		}
+	}
+	return bw.Flush()
+}
+
+// list returns the number of l, numbering it if it is new.
+func (e *encoder) list(l *op.Ops) int {
+	id, ok := e.listID[l]
+	if !ok {
+		id = len(e.lists)
+		e.listID[l] = id
+		e.lists = append(e.lists, l)
+	}
+	return id
+}
+
+// number returns the references of the ops of l, numbered.
+func (e *encoder) number(l *op.Ops) ([]ref, error) {
+	src := l.Refs()
+	refs := make([]ref, len(src))
+	err := scan(l.Data(), len(src), func(t opconst.OpType, i int) error {
+		switch t {
+		case opconst.TypeImage:
+			img, ok := src[i].(*image.RGBA)
+			if !ok || src[i+1] == nil {
+				return fmt.Errorf("image op of %T", src[i])
+			}
+			id, ok := e.imageID[img]
+			if !ok {
+				id = len(e.images)
+				e.imageID[img] = id
+				e.images = append(e.images, img)
+			}
+			refs[i] = ref{refImage, id}
+			h, ok := e.handles[src[i+1]]
+			if !ok {
+				h = len(e.handles)
+				e.handles[src[i+1]] = h
+			}
+			refs[i+1] = ref{refHandle, h}
+		case opconst.TypeKeyInput, opconst.TypePointerInput, opconst.TypeProfile:
+			k := src[i]
+			if k == nil {
+				refs[i] = ref{refNil, 0}
+				break
+			}
+			id, ok := e.keyID[k]
+			if !ok {
+				id = len(e.keys)
+				e.keyID[k] = id
+				name := reflect.TypeOf(k).String()
+				if k, ok := k.(*Key); ok {
+					// A key of a loaded frame keeps its type.
+					name = k.Type
+				}
+				e.keys = append(e.keys, name)
+			}
+			refs[i] = ref{refKey, id}
+		case opconst.TypeCall:
+			called, ok := src[i].(*op.Ops)
+			if !ok || called == nil {
+				return fmt.Errorf("call of %T", src[i])
+			}
+			refs[i] = ref{refList, e.list(called)}
+		}
+		return nil
+	})
+	return refs, err
+}
+
+// writeImage writes the size and the compressed pixels of img.
+func writeImage(w *bufio.Writer, img *image.RGBA) error {
+	b := img.Rect
+	uvarint(w, b.Dx())
+	uvarint(w, b.Dy())
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		zw.Write(img.Pix[i : i+4*b.Dx()])
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	uvarint(w, buf.Len())
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func uvarint(w *bufio.Writer, v int) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(v))
+	w.Write(buf[:n])
+}
+
+// scan calls f with the type of every op in data that has
+// references, and the index of its first reference, checking that
+// data is a list of ops with nrefs references. Macros and calls are
+// not followed.
+func scan(data []byte, nrefs int, f func(t opconst.OpType, ref int) error) error {
+	type macroEnd struct {
+		data, refs int
+	}
+	var (
+		refs int
+		// ends are the ends of the macro definitions the scan is
+		// in, innermost last.
+		ends []macroEnd
+		// defs holds the references before every macro
+		// definition, by offset.
+		defs = make(map[int]int)
+	)
+	bo := binary.LittleEndian
+	for pc := 0; pc < len(data); {
+		t := opconst.OpType(data[pc])
+		if t < opconst.TypeMacroDef || t > opconst.TypeCall {
+			return fmt.Errorf("byte %d: unknown op %d", pc, byte(t))
+		}
+		n := t.Size()
+		if t == opconst.TypeAux {
+			// An aux op fills the rest of the macro around it.
+			if len(ends) == 0 {
+				return fmt.Errorf("byte %d: aux op outside a macro", pc)
+			}
+			n = ends[len(ends)-1].data - pc
+			if n < opconst.TypeAuxLen+1 {
+				return fmt.Errorf("byte %d: short aux op", pc)
+			}
+		}
+		if pc+n > len(data) {
+			return fmt.Errorf("byte %d: %s op cut short", pc, t)
+		}
+		if refs+t.NumRefs() > nrefs {
+			return fmt.Errorf("byte %d: %s op is missing references", pc, t)
+		}
+		d := data[pc : pc+n]
+		switch t {
+		case opconst.TypeMacroDef:
+			end := macroEnd{data: int(bo.Uint32(d[1:])), refs: int(bo.Uint32(d[5:]))}
+			if end.data < pc+n || end.data > len(data) || end.refs < refs || end.refs > nrefs {
+				return fmt.Errorf("byte %d: macro ends out of range", pc)
+			}
+			defs[pc] = refs
+			ends = append(ends, end)
+		case opconst.TypeMacro:
+			def := int(bo.Uint32(d[1:]))
+			defRefs, ok := defs[def]
+			if !ok || defRefs != int(bo.Uint32(d[5:])) {
+				return fmt.Errorf("byte %d: macro of no macro definition", pc)
+			}
+		}
+		if t.NumRefs() > 0 {
+			if err := f(t, refs); err != nil {
+				return fmt.Errorf("byte %d: %v", pc, err)
+			}
+		}
+		pc += n
+		refs += t.NumRefs()
+		for len(ends) > 0 && ends[len(ends)-1].data <= pc {
+			end := ends[len(ends)-1]
+			if end.data != pc || end.refs != refs {
+				return fmt.Errorf("byte %d: macro ends inside an op", pc)
+			}
+			ends = ends[:len(ends)-1]
+		}
+	}
+	if len(ends) > 0 {
+		return errors.New("unfinished macro")
+	}
+	if refs != nrefs {
+		return fmt.Errorf("%d references for %d", nrefs, refs)
+	}
+	return nil
+}
+
+// decoder reads a file.
+type decoder struct {
+	r   *bufio.Reader
+	err error
+	// left is what is left of maxTotal.
+	left int
+}
+
+// Decode reads a frame from r, and returns its ops and its size.
+func Decode(r io.Reader) (*op.Ops, image.Point, error) {
+	d := &decoder{r: bufio.NewReader(r), left: maxTotal}
+	ops, size := d.decode()
+	if d.err != nil {
+		return nil, image.Point{}, d.err
+	}
+	return ops, size, nil
+}
+
+func (d *decoder) decode() (*op.Ops, image.Point) {
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(d.r, magic); err != nil || string(magic) != Magic {
+		d.fail(errors.New("not an op list file"))
+		return nil, image.Point{}
+	}
+	if v := d.uvarint(Version + 1); v == 0 || v > Version {
+		d.fail(fmt.Errorf("version %d, want at most %d", v, Version))
+		return nil, image.Point{}
+	}
+	size := image.Point{X: d.uvarint(maxPixels), Y: d.uvarint(maxPixels)}
+	images := make([]*image.RGBA, d.uvarint(maxCount))
+	for i := range images {
+		images[i] = d.image()
+	}
+	keys := make([]*Key, d.uvarint(maxCount))
+	for i := range keys {
+		keys[i] = &Key{ID: i, Type: string(d.bytes(maxBytes))}
+	}
+	handles := make([]*int, d.uvarint(maxCount))
+	for i := range handles {
+		handles[i] = new(int)
+	}
+	lists := make([]*op.Ops, d.uvarint(maxCount))
+	if d.err == nil && len(lists) == 0 {
+		d.fail(errors.New("no op lists"))
+	}
+	if d.err != nil {
+		return nil, image.Point{}
+	}
+	for i := range lists {
+		lists[i] = new(op.Ops)
+	}
+	// calls holds the lists every list calls.
+	calls := make([][]int, len(lists))
+	for i, l := range lists {
+		data := d.bytes(maxBytes)
+		refs := make([]interface{}, d.uvarint(len(data)))
+		kinds := make([]byte, len(refs))
+		for j := range refs {
+			kind, err := d.r.ReadByte()
+			if err != nil {
+				d.fail(err)
+			}
+			kinds[j] = kind
+			switch kind {
+			case refNil:
+				d.uvarint(0)
+			case refImage:
+				refs[j] = images[d.index(len(images))]
+			case refHandle:
+				refs[j] = handles[d.index(len(handles))]
+			case refKey:
+				refs[j] = keys[d.index(len(keys))]
+			case refList:
+				id := d.index(len(lists))
+				refs[j] = lists[id]
+				calls[i] = append(calls[i], id)
+			default:
+				d.fail(fmt.Errorf("op list %d: unknown reference kind %d", i, kind))
+			}
+			if d.err != nil {
+				return nil, image.Point{}
+			}
+		}
+		if d.err != nil {
+			return nil, image.Point{}
+		}
+		err := scan(data, len(refs), func(t opconst.OpType, j int) error {
+			want := []byte{refKey}
+			switch t {
+			case opconst.TypeImage:
+				want = []byte{refImage, refHandle}
+			case opconst.TypeCall:
+				want = []byte{refList}
+			}
+			for k, kind := range want {
+				if kinds[j+k] != kind && !(kind == refKey && kinds[j+k] == refNil) {
+					return fmt.Errorf("%s op with a reference of kind %d", t, kinds[j+k])
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			d.fail(fmt.Errorf("op list %d: %v", i, err))
+			return nil, image.Point{}
+		}
+		copy(l.Write(len(data), refs...), data)
+	}
+	if err := checkCalls(calls); err != nil {
+		d.fail(err)
+		return nil, image.Point{}
+	}
+	return lists[0], size
+}
+
+// checkCalls fails if a list calls itself, directly or not.
+func checkCalls(calls [][]int) error {
+	const (
+		unseen = iota
+		visiting
+		done
+	)
+	state := make([]int, len(calls))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("op list %d calls itself", i)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		for _, c := range calls[i] {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+		state[i] = done
+		return nil
+	}
+	for i := range calls {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// image reads an image.
+func (d *decoder) image() *image.RGBA {
+	w, h := d.uvarint(maxPixels), d.uvarint(maxPixels)
+	z := d.bytes(maxBytes)
+	if d.err != nil {
+		return nil
+	}
+	if w*h > maxPixels {
+		d.fail(fmt.Errorf("%dx%d image is too large", w, h))
+		return nil
+	}
+	if d.take(4 * w * h); d.err != nil {
+		return nil
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	zr, err := zlib.NewReader(bytes.NewReader(z))
+	if err != nil {
+		d.fail(err)
+		return nil
+	}
+	if _, err := io.ReadFull(zr, img.Pix); err != nil {
+		d.fail(fmt.Errorf("%dx%d image: %v", w, h, err))
+		return nil
+	}
+	return img
+}
+
+// uvarint reads a number of at most max.
+func (d *decoder) uvarint(max int) int {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.fail(err)
+		return 0
+	}
+	if v > uint64(max) {
+		d.fail(fmt.Errorf("number %d out of range", v))
+		return 0
+	}
+	return int(v)
+}
+
+// index reads an index into a list of n things.
+func (d *decoder) index(n int) int {
+	if d.err != nil {
+		return 0
+	}
+	if n == 0 {
+		d.fail(errors.New("reference to nothing"))
+		return 0
+	}
+	return d.uvarint(n - 1)
+}
+
+// bytes reads a length of at most max, and that many bytes.
+func (d *decoder) bytes(max int) []byte {
+	n := d.uvarint(max)
+	if d.take(n); d.err != nil {
+		return nil
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(d.r, int64(n)))
+	if err == nil && len(b) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		d.fail(err)
+	}
+	return b
+}
+
+// take counts n bytes against maxTotal.
+func (d *decoder) take(n int) {
+	if d.err != nil {
+		return
+	}
+	if n > d.left {
+		d.fail(fmt.Errorf("more than %d bytes of images and ops", maxTotal))
+		return
+	}
+	d.left -= n
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+	}
+}
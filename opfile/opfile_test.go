@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opfile
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"regexp"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/io/profile"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"github.com/glycerine/hello_gio.go/inspect"
+	"github.com/glycerine/hello_gio.go/raster"
+)
+
+type handler struct{}
+
+var size = image.Point{X: 40, Y: 30}
+
+// testFrame returns a frame that uses every kind of reference.
+func testFrame() *op.Ops {
+	ops := new(op.Ops)
+	paint.ColorOp{Color: color.RGBA{G: 0x80, A: 0xff}}.Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 40, Y: 30}}}.Add(ops)
+
+	var stack op.StackOp
+	stack.Push(ops)
+	op.TransformOp{}.Offset(f32.Point{X: 4, Y: 3}).Add(ops)
+	clip.Rect{Rect: f32.Rectangle{Max: f32.Point{X: 20, Y: 20}}, NW: 6, SE: 6}.Op(ops).Add(ops)
+	// Half transparent, premultiplied.
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Pix = []byte{
+		0xff, 0, 0, 0xff, 0, 0x40, 0, 0x80,
+		0, 0, 0xff, 0xff, 0x10, 0x10, 0x10, 0x20,
+	}
+	paint.NewImageOp(img).Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 20, Y: 20}}}.Add(ops)
+	h := new(handler)
+	pointer.Rect(image.Rect(0, 0, 20, 20)).Add(ops)
+	pointer.InputOp{Key: h}.Add(ops)
+	key.InputOp{Key: h, Focus: true}.Add(ops)
+	stack.Pop()
+
+	var m op.MacroOp
+	m.Record(ops)
+	paint.ColorOp{Color: color.RGBA{R: 0xff, A: 0xff}}.Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Min: f32.Point{X: 30, Y: 2}, Max: f32.Point{X: 36, Y: 8}}}.Add(ops)
+	m.Stop()
+	op.TransformOp{}.Offset(f32.Point{Y: 20}).Add(ops)
+	m.Add()
+
+	sub := new(op.Ops)
+	paint.ColorOp{Color: color.RGBA{B: 0xff, A: 0xff}}.Add(sub)
+	paint.PaintOp{Rect: f32.Rectangle{Min: f32.Point{X: 24, Y: 0}, Max: f32.Point{X: 28, Y: 4}}}.Add(sub)
+	profile.Op{Key: new(handler)}.Add(sub)
+	op.CallOp{Ops: sub}.Add(ops)
+	op.CallOp{Ops: sub}.Add(ops)
+	return ops
+}
+
+func encode(t *testing.T, ops *op.Ops) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, ops, size); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	orig := testFrame()
+	file := encode(t, orig)
+	got, gotSize, err := Decode(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotSize != size {
+		t.Errorf("size %v, want %v", gotSize, size)
+	}
+	if !bytes.Equal(got.Data(), orig.Data()) {
+		t.Error("the ops differ")
+	}
+	if !bytes.Equal(raster.Render(got, size).Pix, raster.Render(orig, size).Pix) {
+		t.Error("the loaded frame draws differently")
+	}
+
+	// The trees are the same but for the key IDs.
+	var want, tree bytes.Buffer
+	inspect.Dump(&want, orig)
+	inspect.Dump(&tree, got)
+	if s := regexp.MustCompile(`(handler)#\d+`).ReplaceAllString(tree.String(), "$1"); s != want.String() {
+		t.Errorf("loaded\n%swant\n%s", s, want.String())
+	}
+	// The two keys of the handler are one.
+	var keys []*Key
+	for _, r := range got.Refs() {
+		if k, ok := r.(*Key); ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) != 2 || keys[0] != keys[1] || *keys[0] != (Key{ID: 0, Type: "*opfile.handler"}) {
+		t.Errorf("keys %v", keys)
+	}
+
+	// Saving again gives the same file.
+	if again := encode(t, got); !bytes.Equal(again, file) {
+		t.Error("the file changed when saved again")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	file := encode(t, testFrame())
+	for _, tc := range []struct {
+		file []byte
+		want string
+	}{
+		{[]byte("hello"), "not an op list file"},
+		{append([]byte(Magic), 2), "version 2, want at most 1"},
+		{file[:len(file)-1], "unexpected EOF"},
+	} {
+		_, _, err := Decode(bytes.NewReader(tc.file))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("got error %v, want %q", err, tc.want)
+		}
+	}
+
+	// Images each within maxPixels, but too many of them.
+	defer func(n int) { maxTotal = n }(maxTotal)
+	maxTotal = 400
+	ops := new(op.Ops)
+	for i := 0; i < 2; i++ {
+		paint.NewImageOp(image.NewRGBA(image.Rect(0, 0, 8, 8))).Add(ops)
+		paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 8, Y: 8}}}.Add(ops)
+	}
+	if _, _, err := Decode(bytes.NewReader(encode(t, ops))); err == nil || err.Error() != "more than 400 bytes of images and ops" {
+		t.Errorf("two 8x8 images in 400 bytes: got error %v", err)
+	}
+	maxTotal = maxBytes
+
+	// No cut file loads, and no damaged one panics.
+	for n := len(Magic); n < len(file); n++ {
+		if _, _, err := Decode(bytes.NewReader(file[:n])); err == nil {
+			t.Errorf("the first %d bytes loaded", n)
+		}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		bad := append([]byte(nil), file...)
+		bad[len(Magic)+rnd.Intn(len(bad)-len(Magic))] = byte(rnd.Intn(256))
+		ops, _, err := Decode(bytes.NewReader(bad))
+		if err == nil {
+			// What loads can be walked. Its numbers may be garbage.
+			inspect.Walk(ops)
+		}
+	}
+}
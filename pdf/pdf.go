@@ -0,0 +1,380 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package pdf writes Gio op.Ops frames as the pages of a PDF
+// document, in pure Go.
+//
+// The op list is walked like the raster and svg packages walk it,
+// and each op maps onto the PDF graphics state: StackOp is a q/Q
+// pair, TransformOp a cm translation, clip.Op clips to its rectangle
+// or to its clip.Path, filled even-odd, and PaintOp fills its
+// rectangle with the current ColorOp or draws the current ImageOp,
+// which is embedded once per document as an image XObject. Quadratic
+// path segments become the equivalent cubic curves, and text, which
+// Gio lays out as clip paths, comes out as glyph outlines.
+//
+// A pixel of the frame is a CSS pixel, 1/96 inch, so a 1400x900
+// frame makes a page of 1050x675 points.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+	"github.com/glycerine/hello_gio.go/internal/ops"
+)
+
+// pointsPerPixel is the size of a frame pixel on the page.
+const pointsPerPixel = 72.0 / 96
+
+// The objects every document has, numbered first.
+const (
+	catalogObj = 1
+	pagesObj   = 2
+)
+
+// Writer writes frames to a PDF document, one page each. Call
+// Close to finish the document.
+type Writer struct {
+	w *bufio.Writer
+	// off is the number of bytes written so far.
+	off int64
+	// offsets holds the file offset of every object, by object
+	// number less one.
+	offsets []int64
+	// pages are the object numbers of the pages.
+	pages []int
+	// images maps every embedded image to its object number.
+	images map[*image.RGBA]int
+	reader ops.Reader
+	err    error
+}
+
+// page is the state of the page being drawn.
+type page struct {
+	content bytes.Buffer
+	// xobjects and alphas are the resources the content uses: the
+	// images by object number, and the fill opacities.
+	xobjects map[int]bool
+	alphas   map[uint8]bool
+}
+
+// NewWriter starts a PDF document on w.
+func NewWriter(w io.Writer) *Writer {
+	pw := &Writer{
+		w:      bufio.NewWriter(w),
+		images: make(map[*image.RGBA]int),
+	}
+	pw.alloc() // catalogObj
+	pw.alloc() // pagesObj
+	// The binary comment marks the file as binary for transfer
+	// programs that care.
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return pw
+}
+
+// Encode writes the operations in root, drawn in a window of the
+// given size, to w as a one-page PDF document.
+func Encode(w io.Writer, root *op.Ops, size image.Point) error {
+	pw := NewWriter(w)
+	if err := pw.Page(root, size); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// Page adds a page showing the operations in root, drawn in a
+// window of the given size. Like the GPU renderer, it starts from a
+// white background.
+func (w *Writer) Page(root *op.Ops, size image.Point) error {
+	if w.err != nil {
+		return w.err
+	}
+	p := &page{
+		xobjects: make(map[int]bool),
+		alphas:   make(map[uint8]bool),
+	}
+	width, height := float32(size.X)*pointsPerPixel, float32(size.Y)*pointsPerPixel
+	// Flip the page to the y-down pixel coordinates of the frame.
+	fmt.Fprintf(&p.content, "q\n%s 0 0 %s 0 %s cm\n", ops.Num(pointsPerPixel), ops.Num(-pointsPerPixel), ops.Num(height))
+	fmt.Fprintf(&p.content, "1 1 1 rg\n0 0 %d %d re f\n", size.X, size.Y)
+	w.reader.Reset(root)
+	w.collect(p, ops.State{Color: color.RGBA{A: 0xff}})
+	p.content.WriteString("Q\n")
+
+	content := w.alloc()
+	w.beginObj(content)
+	w.stream("", p.content.Bytes())
+	w.endObj()
+
+	var res bytes.Buffer
+	if len(p.xobjects) > 0 {
+		res.WriteString(" /XObject <<")
+		for _, n := range sortedKeys(p.xobjects) {
+			fmt.Fprintf(&res, " /Im%d %d 0 R", n, n)
+		}
+		res.WriteString(" >>")
+	}
+	if len(p.alphas) > 0 {
+		res.WriteString(" /ExtGState <<")
+		var alphas []int
+		for a := range p.alphas {
+			alphas = append(alphas, int(a))
+		}
+		sort.Ints(alphas)
+		for _, a := range alphas {
+			fmt.Fprintf(&res, " /A%d << /ca %s >>", a, ops.Decimal(float64(a)/255, 3))
+		}
+		res.WriteString(" >>")
+	}
+	n := w.alloc()
+	w.beginObj(n)
+	w.printf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Contents %d 0 R /Resources <<%s >> >>\n",
+		pagesObj, ops.Num(width), ops.Num(height), content, res.String())
+	w.endObj()
+	w.pages = append(w.pages, n)
+	return w.err
+}
+
+// Close writes the page tree and the cross-reference table that
+// end the document. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.pages) == 0 {
+		return errors.New("pdf: no pages")
+	}
+	w.beginObj(pagesObj)
+	w.printf("<< /Type /Pages /Kids [")
+	for i, n := range w.pages {
+		if i > 0 {
+			w.printf(" ")
+		}
+		w.printf("%d 0 R", n)
+	}
+	w.printf("] /Count %d >>\n", len(w.pages))
+	w.endObj()
+	w.beginObj(catalogObj)
+	w.printf("<< /Type /Catalog /Pages %d 0 R >>\n", pagesObj)
+	w.endObj()
+
+	xref := w.off
+	w.printf("xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, off := range w.offsets {
+		w.printf("%010d 00000 n \n", off)
+	}
+	w.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, catalogObj, xref)
+	if err := w.w.Flush(); w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// collect writes the content of the ops up to the Pop that ends
+// the current stack level.
+func (w *Writer) collect(p *page, st ops.State) {
+	var path []ops.Quad
+loop:
+	for encOp, ok := w.reader.Decode(); ok; encOp, ok = w.reader.Decode() {
+		switch opconst.OpType(encOp.Data[0]) {
+		case opconst.TypeTransform:
+			off := ops.DecodeTransformOp(encOp.Data).Transform(f32.Point{})
+			fmt.Fprintf(&p.content, "1 0 0 1 %s %s cm\n", ops.Num(off.X), ops.Num(off.Y))
+		case opconst.TypeAux:
+			path = ops.DecodePath(encOp.Data)
+		case opconst.TypeClip:
+			if len(path) > 0 {
+				writePath(&p.content, path)
+				p.content.WriteString("W* n\n")
+			} else {
+				fmt.Fprintf(&p.content, "%s re W n\n", rect(ops.DecodeClipOp(encOp.Data)))
+			}
+			path = nil
+		case opconst.TypeColor:
+			st.Color = ops.DecodeColorOp(encOp.Data)
+			st.Img = nil
+		case opconst.TypeImage:
+			st.Img = ops.DecodeImageOp(encOp.Data, encOp.Refs).Src
+		case opconst.TypePaint:
+			w.paint(p, st, ops.DecodePaintOp(encOp.Data).Rect)
+		case opconst.TypePush:
+			p.content.WriteString("q\n")
+			w.collect(p, st)
+			p.content.WriteString("Q\n")
+		case opconst.TypePop:
+			break loop
+		}
+	}
+}
+
+// paint fills r with the material of st. Each fill saves and
+// restores the graphics state, so its color and opacity don't leak
+// into the next.
+func (w *Writer) paint(p *page, st ops.State, r f32.Rectangle) {
+	if r.Empty() {
+		return
+	}
+	if st.Img == nil {
+		c := st.Color
+		if c.A == 0 {
+			return
+		}
+		p.content.WriteString("q ")
+		if c.A != 0xff {
+			p.alphas[c.A] = true
+			fmt.Fprintf(&p.content, "/A%d gs ", c.A)
+		}
+		// PDF colors are not premultiplied.
+		fmt.Fprintf(&p.content, "%s %s %s rg %s re f Q\n",
+			channel(c.R, c.A), channel(c.G, c.A), channel(c.B, c.A), rect(r))
+		return
+	}
+	if sz := st.Img.Bounds().Size(); sz.X == 0 || sz.Y == 0 {
+		return
+	}
+	n := w.image(st.Img)
+	p.xobjects[n] = true
+	// Images fill the unit square with their first row at the top;
+	// map that square onto r, which has y pointing down.
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /Im%d Do Q\n",
+		ops.Num(r.Dx()), ops.Num(-r.Dy()), ops.Num(r.Min.X), ops.Num(r.Max.Y), n)
+}
+
+// image returns the object number of img, embedding it the first
+// time it is used. The color goes into an RGB image and the alpha,
+// if any pixel is not opaque, into a soft mask.
+func (w *Writer) image(img *image.RGBA) int {
+	if n, ok := w.images[img]; ok {
+		return n
+	}
+	b := img.Bounds()
+	rgb := make([]byte, 0, b.Dx()*b.Dy()*3)
+	alpha := make([]byte, 0, b.Dx()*b.Dy())
+	opaque := true
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+4 {
+			pix := img.Pix[i : i+4 : i+4]
+			a := pix[3]
+			rgb = append(rgb, ops.Unmul(pix[0], a), ops.Unmul(pix[1], a), ops.Unmul(pix[2], a))
+			alpha = append(alpha, a)
+			opaque = opaque && a == 0xff
+		}
+	}
+	var smask string
+	if !opaque {
+		m := w.alloc()
+		w.beginObj(m)
+		w.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 ",
+			b.Dx(), b.Dy()), alpha)
+		w.endObj()
+		smask = fmt.Sprintf("/SMask %d 0 R ", m)
+	}
+	n := w.alloc()
+	w.images[img] = n
+	w.beginObj(n)
+	w.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 %s",
+		b.Dx(), b.Dy(), smask), rgb)
+	w.endObj()
+	return n
+}
+
+// writePath writes the path construction operators of path. Each
+// run of joined segments is a subpath, closed back to its start.
+func writePath(b *bytes.Buffer, path []ops.Quad) {
+	var pen f32.Point
+	for i, q := range path {
+		if i == 0 || q.From != pen {
+			if i > 0 {
+				b.WriteString("h\n")
+			}
+			fmt.Fprintf(b, "%s %s m\n", ops.Num(q.From.X), ops.Num(q.From.Y))
+		}
+		if ops.IsLine(q) {
+			fmt.Fprintf(b, "%s %s l\n", ops.Num(q.To.X), ops.Num(q.To.Y))
+		} else {
+			// The cubic with control points 2/3 of the way to the
+			// quad's control point is the same curve.
+			c1 := q.From.Add(q.Ctrl.Sub(q.From).Mul(2.0 / 3))
+			c2 := q.To.Add(q.Ctrl.Sub(q.To).Mul(2.0 / 3))
+			fmt.Fprintf(b, "%s %s %s %s %s %s c\n",
+				ops.Num(c1.X), ops.Num(c1.Y), ops.Num(c2.X), ops.Num(c2.Y), ops.Num(q.To.X), ops.Num(q.To.Y))
+		}
+		pen = q.To
+	}
+	if len(path) > 0 {
+		b.WriteString("h\n")
+	}
+}
+
+// rect returns the operands of the re operator for r.
+func rect(r f32.Rectangle) string {
+	return fmt.Sprintf("%s %s %s %s", ops.Num(r.Min.X), ops.Num(r.Min.Y), ops.Num(r.Dx()), ops.Num(r.Dy()))
+}
+
+// alloc returns the number of a new object.
+func (w *Writer) alloc() int {
+	w.offsets = append(w.offsets, 0)
+	return len(w.offsets)
+}
+
+func (w *Writer) beginObj(n int) {
+	w.offsets[n-1] = w.off
+	w.printf("%d 0 obj\n", n)
+}
+
+func (w *Writer) endObj() {
+	w.printf("endobj\n")
+}
+
+// stream writes data compressed, as a stream with the given
+// dictionary entries.
+func (w *Writer) stream(dict string, data []byte) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	w.printf("<< %s/Length %d /Filter /FlateDecode >>\nstream\n", dict, buf.Len())
+	w.write(buf.Bytes())
+	w.printf("\nendstream\n")
+}
+
+func (w *Writer) printf(format string, a ...interface{}) {
+	w.write([]byte(fmt.Sprintf(format, a...)))
+}
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.off += int64(n)
+	w.err = err
+}
+
+// channel returns the PDF color component, from 0 to 1, of the
+// color channel c premultiplied by alpha a, with the
+// premultiplication undone.
+func channel(c, a uint8) string {
+	return ops.Decimal(float64(ops.Unmul(c, a))/255, 3)
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+func rectF(x0, y0, x1, y1 float32) f32.Rectangle {
+	return f32.Rectangle{Min: f32.Point{X: x0, Y: y0}, Max: f32.Point{X: x1, Y: y1}}
+}
+
+// TestEncode covers what maps onto the PDF graphics state: nested
+// stacks, fills of two opacities, which each get an ExtGState, and an
+// image with a soft mask.
+func TestEncode(t *testing.T) {
+	ops := new(op.Ops)
+	paint.ColorOp{Color: color.RGBA{G: 0x80, A: 0xff}}.Add(ops)
+	paint.PaintOp{Rect: rectF(0, 0, 40, 4)}.Add(ops)
+
+	var outer, inner op.StackOp
+	outer.Push(ops)
+	op.TransformOp{}.Offset(f32.Point{X: 2, Y: 4}).Add(ops)
+	// A quarter opaque red, premultiplied.
+	paint.ColorOp{Color: color.RGBA{R: 0x40, A: 0x40}}.Add(ops)
+	paint.PaintOp{Rect: rectF(0, 0, 6, 6)}.Add(ops)
+	inner.Push(ops)
+	op.TransformOp{}.Offset(f32.Point{X: 10}).Add(ops)
+	clip.Rect{Rect: rectF(0, 0, 5, 5)}.Op(ops).Add(ops)
+	// Three quarters opaque white.
+	paint.ColorOp{Color: color.RGBA{R: 0xc0, G: 0xc0, B: 0xc0, A: 0xc0}}.Add(ops)
+	paint.PaintOp{Rect: rectF(0, 0, 8, 8)}.Add(ops)
+	inner.Pop()
+	paint.PaintOp{Rect: rectF(0, 8, 2, 10)}.Add(ops)
+	outer.Pop()
+
+	// Half transparent red over opaque blue, premultiplied.
+	img := image.NewRGBA(image.Rect(0, 0, 1, 2))
+	copy(img.Pix, []byte{0x80, 0, 0, 0x80, 0, 0, 0xff, 0xff})
+	paint.NewImageOp(img).Add(ops)
+	paint.PaintOp{Rect: rectF(30, 10, 32, 14)}.Add(ops)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, ops, image.Point{X: 40, Y: 20}); err != nil {
+		t.Fatal(err)
+	}
+	objs := checkDocument(t, buf.Bytes())
+	page := objs[pageObjs(t, objs)[0]]
+	if want := "/MediaBox [0 0 30 15]"; !strings.Contains(page, want) {
+		t.Errorf("page has no %s: %s", want, page)
+	}
+	for _, want := range []string{"/A64 << /ca 0.251 >> /A192 << /ca 0.753 >>", "/XObject << /Im"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page resources have no %s: %s", want, page)
+		}
+	}
+	got := streamData(t, objs[ref(t, page, "Contents")])
+	want := `q
+0.75 0 0 -0.75 0 15 cm
+1 1 1 rg
+0 0 40 20 re f
+q 0 0.502 0 rg 0 0 40 4 re f Q
+q
+1 0 0 1 2 4 cm
+q /A64 gs 1 0 0 rg 0 0 6 6 re f Q
+q
+1 0 0 1 10 0 cm
+0 0 5 5 re W n
+q /A192 gs 1 1 1 rg 0 0 8 8 re f Q
+Q
+q /A64 gs 1 0 0 rg 0 8 2 2 re f Q
+Q
+q 2 0 0 -4 30 14 cm /Im4 Do Q
+Q
+`
+	if got != want {
+		t.Errorf("got content\n%s\nwant\n%s", got, want)
+	}
+
+	im := objs[4]
+	for _, want := range []string{"/Width 1 /Height 2", "/DeviceRGB", "/SMask"} {
+		if !strings.Contains(im, want) {
+			t.Errorf("image has no %s: %s", want, im)
+		}
+	}
+	// The colors are stored with the premultiplication undone, and
+	// the alpha apart.
+	if got, want := streamData(t, im), "\xff\x00\x00\x00\x00\xff"; got != want {
+		t.Errorf("image data is %q, want %q", got, want)
+	}
+	if got, want := streamData(t, objs[ref(t, im, "SMask")]), "\x80\xff"; got != want {
+		t.Errorf("soft mask is %q, want %q", got, want)
+	}
+}
+
+// TestEncodePath covers a clip path of two subpaths, one of them
+// curved, which PDF fills even-odd.
+func TestEncodePath(t *testing.T) {
+	ops := new(op.Ops)
+	var p clip.Path
+	p.Begin(ops)
+	p.Move(f32.Point{X: 2, Y: 6})
+	p.Quad(f32.Point{X: 3, Y: -4}, f32.Point{X: 6})
+	p.Line(f32.Point{X: -6})
+	p.Move(f32.Point{X: 1, Y: 2})
+	p.Line(f32.Point{X: 2})
+	p.Line(f32.Point{X: -1, Y: 1})
+	p.End().Add(ops)
+	paint.PaintOp{Rect: rectF(0, 0, 10, 10)}.Add(ops)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, ops, image.Point{X: 10, Y: 10}); err != nil {
+		t.Fatal(err)
+	}
+	objs := checkDocument(t, buf.Bytes())
+	got := streamData(t, objs[ref(t, objs[pageObjs(t, objs)[0]], "Contents")])
+	// The quad becomes the cubic with the same curve.
+	const want = "2 6 m\n4 3.33 6 3.33 8 6 c\n2 6 l\nh\n3 8 m\n5 8 l\n4 9 l\nh\nW* n\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("no\n%s\nin\n%s", want, got)
+	}
+}
+
+// TestWriterPages checks that frames go onto pages in order, and
+// that an image shared by the frames is embedded once.
+func TestWriterPages(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 1; i <= 3; i++ {
+		ops := new(op.Ops)
+		paint.NewImageOp(img).Add(ops)
+		paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 2, Y: 2}}}.Add(ops)
+		if err := w.Page(ops, image.Point{X: 96 * i, Y: 96}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	objs := checkDocument(t, buf.Bytes())
+	for i, n := range pageObjs(t, objs) {
+		want := fmt.Sprintf("/MediaBox [0 0 %d 72]", 72*(i+1))
+		if !strings.Contains(objs[n], want) {
+			t.Errorf("page %d has no %s: %s", i+1, want, objs[n])
+		}
+	}
+	if n := strings.Count(buf.String(), "/Subtype /Image"); n != 1 {
+		t.Errorf("%d images embedded, want 1", n)
+	}
+	if strings.Contains(buf.String(), "/SMask") {
+		t.Error("opaque image has a soft mask")
+	}
+}
+
+func TestWriterNoPages(t *testing.T) {
+	if err := NewWriter(ioutil.Discard).Close(); err == nil {
+		t.Error("closed a document with no pages")
+	}
+}
+
+var (
+	xrefRE   = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	objRE    = regexp.MustCompile(`^(\d+) 0 obj\n`)
+	lengthRE = regexp.MustCompile(`/Length (\d+)`)
+)
+
+// checkDocument checks the header, trailer and cross-reference
+// table of doc, and returns its objects by number.
+func checkDocument(t *testing.T, doc []byte) map[int]string {
+	t.Helper()
+	s := string(doc)
+	if !strings.HasPrefix(s, "%PDF-1.4\n") {
+		t.Fatalf("no PDF header in %.20q", s)
+	}
+	m := xrefRE.FindStringSubmatch(s)
+	if m == nil {
+		t.Fatalf("no startxref at the end of %q", s[len(s)-40:])
+	}
+	xref, _ := strconv.Atoi(m[1])
+	lines := strings.Split(s[xref:], "\n")
+	if lines[0] != "xref" {
+		t.Fatalf("startxref points at %q", lines[0])
+	}
+	var first, count int
+	fmt.Sscanf(lines[1], "%d %d", &first, &count)
+	if !strings.Contains(s, fmt.Sprintf("/Size %d /Root 1 0 R", count)) {
+		t.Errorf("trailer does not match the %d xref entries", count)
+	}
+	objs := make(map[int]string)
+	for n := 1; n < count; n++ {
+		var off int
+		fmt.Sscanf(lines[2+n], "%d", &off)
+		m := objRE.FindStringSubmatch(s[off:])
+		if m == nil || m[1] != strconv.Itoa(n) {
+			t.Fatalf("xref entry %d points at %.20q", n, s[off:])
+		}
+		end := strings.Index(s[off:], "endobj\n")
+		if end < 0 {
+			t.Fatalf("object %d has no end", n)
+		}
+		objs[n] = s[off : off+end]
+	}
+	if !strings.Contains(objs[1], "/Type /Catalog /Pages 2 0 R") {
+		t.Errorf("object 1 is not the catalog: %s", objs[1])
+	}
+	return objs
+}
+
+// pageObjs returns the object numbers of the pages of a document.
+func pageObjs(t *testing.T, objs map[int]string) []int {
+	t.Helper()
+	kids := regexp.MustCompile(`/Kids \[([^]]*)\] /Count (\d+)`).FindStringSubmatch(objs[2])
+	if kids == nil {
+		t.Fatalf("object 2 is not the page tree: %s", objs[2])
+	}
+	var pages []int
+	for _, f := range strings.Fields(kids[1]) {
+		if n, err := strconv.Atoi(f); err == nil && n > 0 {
+			pages = append(pages, n)
+		}
+	}
+	if strconv.Itoa(len(pages)) != kids[2] {
+		t.Errorf("%d kids, but a count of %s", len(pages), kids[2])
+	}
+	return pages
+}
+
+// ref returns the object number the key of obj refers to.
+func ref(t *testing.T, obj, key string) int {
+	t.Helper()
+	m := regexp.MustCompile(`/` + key + ` (\d+) 0 R`).FindStringSubmatch(obj)
+	if m == nil {
+		t.Fatalf("no %s in %s", key, obj)
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+// streamData returns the inflated data of the stream object obj.
+func streamData(t *testing.T, obj string) string {
+	t.Helper()
+	m := lengthRE.FindStringSubmatch(obj)
+	i := strings.Index(obj, "stream\n")
+	if m == nil || i < 0 {
+		t.Fatalf("not a stream: %.80q", obj)
+	}
+	n, _ := strconv.Atoi(m[1])
+	data := obj[i+len("stream\n"):]
+	if len(data) != n+len("\nendstream\n") {
+		t.Fatalf("stream is %d bytes, but its /Length is %d", len(data)-len("\nendstream\n"), n)
+	}
+	r, err := zlib.NewReader(strings.NewReader(data[:n]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,455 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package plot draws scatter and line plots straight into the op
+// list, so they stay sharp at any size, instead of showing a plot
+// pre-rendered to a PNG.
+//
+// A Plot has an x and a y axis with round tick values, optional grid
+// lines, and any number of series. Each series is a []float64 of y
+// values, with optional x values, drawn as markers, as a polyline,
+// or both. Like the box package, a plot is placed at a position of
+// your choosing: Layout draws it into a given rectangle, in pixels.
+package plot
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// Marker is the shape drawn at each point of a series.
+type Marker uint8
+
+const (
+	NoMarker Marker = iota
+	Circle
+	Square
+	Triangle
+)
+
+// Series is one set of points.
+type Series struct {
+	// X holds the x coordinates. If it is nil, the points are at
+	// x = 0, 1, 2, ...
+	X []float64
+	// Y holds the y coordinates. NaN and infinite values leave a
+	// gap: no marker, and a break in the line.
+	Y []float64
+	// Color is the color of the line and markers. The zero value
+	// picks the next color from Palette.
+	Color color.RGBA
+	// LineWidth is the width of the line joining the points, in
+	// pixels. Zero draws no line.
+	LineWidth float32
+	// Marker is the shape drawn at each point.
+	Marker Marker
+	// MarkerSize is the marker width in pixels. Zero means 6.
+	MarkerSize float32
+}
+
+// Axis describes the range and title of one axis.
+type Axis struct {
+	Label string
+	// Min and Max fix the range of the axis. If Max is not above
+	// Min, the range is fitted to the data and rounded out to
+	// the nearest ticks.
+	Min, Max float64
+	// Time marks an axis of times, given in seconds since the
+	// Unix epoch. Its ticks fall on round seconds, minutes, hours
+	// or days, and are labelled as UTC times.
+	Time bool
+}
+
+// Plot describes the look of a plot.
+type Plot struct {
+	Title string
+	X, Y  Axis
+	// Series are drawn in order, the last on top.
+	Series []Series
+	// Grid draws a line across the plot at every tick.
+	Grid bool
+	// Background fills the whole plot rectangle. The zero value
+	// leaves it unpainted.
+	Background color.RGBA
+	// AxisColor is the color of the axes and ticks, and GridColor
+	// that of the grid. The zero values mean black and light
+	// gray.
+	AxisColor, GridColor color.RGBA
+	// TextSize is the size of the tick labels. The axis labels and
+	// title are a little larger. Zero means 0.75 times the theme's
+	// TextSize.
+	TextSize unit.Value
+
+	// last maps data to pixels as in the last Layout, for Nearest.
+	// Its area is empty if nothing was drawn.
+	last transform
+}
+
+// Palette holds the colors given to series without one.
+var Palette = []color.RGBA{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+}
+
+const (
+	// tickLen is the length of the tick marks.
+	tickLen = 5
+	// pad is the space between labels and what they label, and
+	// around the plot.
+	pad = 4
+	// defaultMarkerSize is the marker width when MarkerSize is 0.
+	defaultMarkerSize = 6
+	// numTicks is roughly how many ticks an axis gets.
+	numTicks = 6
+)
+
+// textOptions lays out labels on one line.
+var textOptions = text.LayoutOptions{MaxWidth: 1e6}
+
+// Layout draws the plot into r. The axes, labels and title are
+// inside r; the data area is what remains after room for them is
+// made.
+func (p *Plot) Layout(gtx *layout.Context, th *material.Theme, r image.Rectangle) {
+	p.last = transform{}
+	if r.Empty() {
+		return
+	}
+	ops := gtx.Ops
+	if p.Background != (color.RGBA{}) {
+		paint.ColorOp{Color: p.Background}.Add(ops)
+		paint.PaintOp{Rect: toRectF(r)}.Add(ops)
+	}
+
+	tickSize := p.TextSize
+	if tickSize.V == 0 {
+		tickSize = th.TextSize.Scale(.75)
+	}
+	labelSize := tickSize.Scale(1.2)
+
+	xmin, xmax, ymin, ymax := p.dataRange()
+	xs := axisTicks(p.X, xmin, xmax)
+	ys := axisTicks(p.Y, ymin, ymax)
+
+	// Make room for the labels around the data area.
+	var yTickW int
+	for _, t := range ys.ticks {
+		if w := measure(gtx, th, tickSize, ys.format(t)).X; w > yTickW {
+			yTickW = w
+		}
+	}
+	tickH := measure(gtx, th, tickSize, "0").Y
+	labelH := measure(gtx, th, labelSize, "0").Y
+	area := r
+	area.Min.X += pad + yTickW + pad + tickLen
+	area.Max.X -= pad + measure(gtx, th, tickSize, xs.format(xs.max)).X/2
+	area.Max.Y -= tickLen + pad + tickH + pad
+	area.Min.Y += pad + tickH/2
+	if p.Title != "" || p.Y.Label != "" {
+		area.Min.Y += labelH + pad
+	}
+	if p.X.Label != "" {
+		area.Max.Y -= labelH + pad
+	}
+	if area.Empty() {
+		return
+	}
+	tr := transform{area: area, xs: xs, ys: ys}
+	p.last = tr
+
+	axisColor := p.AxisColor
+	if axisColor == (color.RGBA{}) {
+		axisColor = color.RGBA{0, 0, 0, 255}
+	}
+	gridColor := p.GridColor
+	if gridColor == (color.RGBA{}) {
+		gridColor = color.RGBA{220, 220, 220, 255}
+	}
+
+	// Grid lines first, so everything else is drawn over them.
+	if p.Grid {
+		for _, t := range xs.ticks {
+			x := tr.px(t)
+			fillRect(ops, gridColor, image.Rect(x, area.Min.Y, x+1, area.Max.Y))
+		}
+		for _, t := range ys.ticks {
+			y := tr.py(t)
+			fillRect(ops, gridColor, image.Rect(area.Min.X, y, area.Max.X, y+1))
+		}
+	}
+
+	p.drawSeries(ops, tr)
+
+	// The axes run along the left and bottom of the data area.
+	fillRect(ops, axisColor, image.Rect(area.Min.X-1, area.Min.Y, area.Min.X, area.Max.Y+1))
+	fillRect(ops, axisColor, image.Rect(area.Min.X-1, area.Max.Y, area.Max.X, area.Max.Y+1))
+	for _, t := range xs.ticks {
+		x := tr.px(t)
+		fillRect(ops, axisColor, image.Rect(x, area.Max.Y, x+1, area.Max.Y+tickLen))
+		drawText(gtx, th, tickSize, xs.format(t), image.Point{X: x, Y: area.Max.Y + tickLen + pad}, layout.N)
+	}
+	for _, t := range ys.ticks {
+		y := tr.py(t)
+		fillRect(ops, axisColor, image.Rect(area.Min.X-1-tickLen, y, area.Min.X-1, y+1))
+		drawText(gtx, th, tickSize, ys.format(t), image.Point{X: area.Min.X - 1 - tickLen - pad, Y: y}, layout.E)
+	}
+
+	if p.X.Label != "" {
+		at := image.Point{X: (area.Min.X + area.Max.X) / 2, Y: r.Max.Y - pad}
+		drawText(gtx, th, labelSize, p.X.Label, at, layout.S)
+	}
+	// Labels can't be rotated, so the y axis label sits above the
+	// axis, across from the title.
+	if p.Y.Label != "" {
+		drawText(gtx, th, labelSize, p.Y.Label, image.Point{X: r.Min.X + pad, Y: r.Min.Y + pad}, layout.NW)
+	}
+	if p.Title != "" {
+		at := image.Point{X: (area.Min.X + area.Max.X) / 2, Y: r.Min.Y + pad}
+		drawText(gtx, th, labelSize, p.Title, at, layout.N)
+	}
+}
+
+// Nearest returns the point nearest to pos among the points the
+// last Layout drew within radius pixels of it, as a series and an
+// index into its Y, along with where the point was drawn.
+func (p *Plot) Nearest(pos image.Point, radius float32) (series, index int, at image.Point, ok bool) {
+	tr := p.last
+	if tr.area.Empty() {
+		return 0, 0, image.Point{}, false
+	}
+	area := toRectF(tr.area)
+	best := radius * radius
+	for i := range p.Series {
+		s := &p.Series[i]
+		for j, y := range s.Y {
+			x := s.x(j)
+			if !finite(x) || !finite(y) {
+				continue
+			}
+			pt := tr.pt(x, y)
+			if pt.X < area.Min.X || pt.X > area.Max.X || pt.Y < area.Min.Y || pt.Y > area.Max.Y {
+				continue
+			}
+			d := pt.Sub(toPointF(pos))
+			if d2 := d.X*d.X + d.Y*d.Y; d2 <= best {
+				best = d2
+				series, index, ok = i, j, true
+				at = image.Point{X: int(math.Round(float64(pt.X))), Y: int(math.Round(float64(pt.Y)))}
+			}
+		}
+	}
+	return series, index, at, ok
+}
+
+// PointText describes point i of series s, with its coordinates
+// named after the axis labels, one per line.
+func (p *Plot) PointText(s, i int) string {
+	ser := &p.Series[s]
+	name := func(a Axis, def string) string {
+		if a.Label != "" {
+			return a.Label
+		}
+		return def
+	}
+	value := func(a Axis, v float64) string {
+		if a.Time {
+			return time.Unix(0, int64(v*1e9)).UTC().Format(time.RFC3339)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	txt := fmt.Sprintf("%s: %s\n%s: %s", name(p.X, "x"), value(p.X, ser.x(i)), name(p.Y, "y"), value(p.Y, ser.Y[i]))
+	if len(p.Series) > 1 {
+		txt = fmt.Sprintf("series %d, point %d\n%s", s+1, i+1, txt)
+	}
+	return txt
+}
+
+// dataRange returns the bounds of the finite points of all series.
+func (p *Plot) dataRange() (xmin, xmax, ymin, ymax float64) {
+	xmin, ymin = math.Inf(1), math.Inf(1)
+	xmax, ymax = math.Inf(-1), math.Inf(-1)
+	for i := range p.Series {
+		s := &p.Series[i]
+		for j, y := range s.Y {
+			x := s.x(j)
+			if !finite(x) || !finite(y) {
+				continue
+			}
+			xmin, xmax = math.Min(xmin, x), math.Max(xmax, x)
+			ymin, ymax = math.Min(ymin, y), math.Max(ymax, y)
+		}
+	}
+	return xmin, xmax, ymin, ymax
+}
+
+// x returns the x coordinate of point i.
+func (s *Series) x(i int) float64 {
+	if s.X == nil {
+		return float64(i)
+	}
+	if i >= len(s.X) {
+		return math.NaN()
+	}
+	return s.X[i]
+}
+
+// drawSeries draws the lines and markers of every series. Lines
+// are clipped to the data area. Markers are not, so that points on
+// the edge of the range show in full, but those outside it are
+// left out.
+func (p *Plot) drawSeries(ops *op.Ops, tr transform) {
+	area := toRectF(tr.area)
+	for i := range p.Series {
+		s := &p.Series[i]
+		c := s.Color
+		if c == (color.RGBA{}) {
+			c = Palette[i%len(Palette)]
+		}
+		if s.LineWidth > 0 {
+			var stack op.StackOp
+			stack.Push(ops)
+			clip.Rect{Rect: area}.Op(ops).Add(ops)
+			var prev f32.Point
+			havePrev := false
+			for j, y := range s.Y {
+				x := s.x(j)
+				if !finite(x) || !finite(y) {
+					havePrev = false
+					continue
+				}
+				pt := tr.pt(x, y)
+				if havePrev {
+					drawSegment(ops, c, prev, pt, s.LineWidth)
+				}
+				prev, havePrev = pt, true
+			}
+			stack.Pop()
+		}
+		if s.Marker != NoMarker {
+			size := s.MarkerSize
+			if size <= 0 {
+				size = defaultMarkerSize
+			}
+			for j, y := range s.Y {
+				x := s.x(j)
+				if !finite(x) || !finite(y) {
+					continue
+				}
+				pt := tr.pt(x, y)
+				if pt.X < area.Min.X || pt.X > area.Max.X || pt.Y < area.Min.Y || pt.Y > area.Max.Y {
+					continue
+				}
+				drawMarker(ops, c, s.Marker, pt, size)
+			}
+		}
+	}
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// transform maps data coordinates to pixels in the data area.
+type transform struct {
+	area   image.Rectangle
+	xs, ys ticks
+}
+
+func (t transform) fx(x float64) float32 {
+	return float32(t.area.Min.X) + float32(t.xs.frac(x))*float32(t.area.Dx())
+}
+
+func (t transform) fy(y float64) float32 {
+	return float32(t.area.Max.Y) - float32(t.ys.frac(y))*float32(t.area.Dy())
+}
+
+func (t transform) pt(x, y float64) f32.Point {
+	return f32.Point{X: t.fx(x), Y: t.fy(y)}
+}
+
+// px and py return the pixel column and row of an x or y value,
+// for drawing one pixel wide lines.
+func (t transform) px(x float64) int {
+	return int(math.Floor(float64(t.fx(x))))
+}
+
+func (t transform) py(y float64) int {
+	return int(math.Floor(float64(t.fy(y))))
+}
+
+// measure returns the size of txt laid out in one line.
+func measure(gtx *layout.Context, th *material.Theme, size unit.Value, txt string) image.Point {
+	lbl := th.Label(size, txt)
+	lines := th.Shaper.Layout(gtx, lbl.Font, txt, textOptions).Lines
+	var sz image.Point
+	for _, l := range lines {
+		if w := l.Width.Ceil(); w > sz.X {
+			sz.X = w
+		}
+		sz.Y += (l.Ascent + l.Descent).Ceil()
+	}
+	return sz
+}
+
+// drawText lays out txt so that the point of its bounds named by
+// anchor is at. For example, layout.N centers the top of the text
+// on at.
+func drawText(gtx *layout.Context, th *material.Theme, size unit.Value, txt string, at image.Point, anchor layout.Direction) {
+	var macro op.MacroOp
+	macro.Record(gtx.Ops)
+	saved := gtx.Constraints
+	gtx.Constraints = layout.Constraints{
+		Width:  layout.Constraint{Max: textOptions.MaxWidth},
+		Height: layout.Constraint{Max: textOptions.MaxWidth},
+	}
+	th.Label(size, txt).Layout(gtx)
+	gtx.Constraints = saved
+	macro.Stop()
+	sz := gtx.Dimensions.Size
+
+	switch anchor {
+	case layout.N, layout.S, layout.Center:
+		at.X -= sz.X / 2
+	case layout.NE, layout.E, layout.SE:
+		at.X -= sz.X
+	}
+	switch anchor {
+	case layout.W, layout.Center, layout.E:
+		at.Y -= sz.Y / 2
+	case layout.SW, layout.S, layout.SE:
+		at.Y -= sz.Y
+	}
+	var stack op.StackOp
+	stack.Push(gtx.Ops)
+	op.TransformOp{}.Offset(toPointF(at)).Add(gtx.Ops)
+	macro.Add()
+	stack.Pop()
+}
+
+func fillRect(ops *op.Ops, c color.RGBA, r image.Rectangle) {
+	paint.ColorOp{Color: c}.Add(ops)
+	paint.PaintOp{Rect: toRectF(r)}.Add(ops)
+}
+
+func toPointF(p image.Point) f32.Point {
+	return f32.Point{X: float32(p.X), Y: float32(p.Y)}
+}
+
+func toRectF(r image.Rectangle) f32.Rectangle {
+	return f32.Rectangle{Min: toPointF(r.Min), Max: toPointF(r.Max)}
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package plot
+
+import (
+	"image"
+	"math"
+	"sync"
+	"testing"
+
+	"gioui.org/font/gofont"
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+)
+
+var registerFonts sync.Once
+
+func TestNearest(t *testing.T) {
+	p := &Plot{
+		X: Axis{Label: "t", Min: 0, Max: 10},
+		Y: Axis{Min: 0, Max: 10},
+		Series: []Series{
+			{Y: []float64{1, 2, math.NaN(), 4}, Marker: Circle},
+			{X: []float64{5}, Y: []float64{20}},
+		},
+	}
+	if _, _, _, ok := p.Nearest(image.Point{}, 1e6); ok {
+		t.Fatal("found a point before the plot was laid out")
+	}
+	registerFonts.Do(gofont.Register)
+	gtx := layout.NewContext(nil)
+	gtx.Reset(nil, image.Point{X: 400, Y: 300})
+	p.Layout(gtx, material.NewTheme(), image.Rect(0, 0, 400, 300))
+
+	s, i, at, ok := p.Nearest(image.Point{Y: 300}, 1e6)
+	if !ok || s != 0 || i != 0 {
+		t.Fatalf("nearest to the bottom left corner is %d/%d (%v), want the first point", s, i, ok)
+	}
+	if s, i, got, ok := p.Nearest(at.Add(image.Point{X: 2, Y: -2}), 5); !ok || s != 0 || i != 0 || got != at {
+		t.Errorf("near %v: got %d/%d at %v (%v)", at, s, i, got, ok)
+	}
+	if _, _, _, ok := p.Nearest(at.Add(image.Point{X: 10}), 5); ok {
+		t.Error("found a point further away than the radius")
+	}
+	if got, want := p.PointText(0, 3), "series 1, point 4\nt: 3\ny: 4"; got != want {
+		t.Errorf("text is %q, want %q", got, want)
+	}
+}
+
+// DBL_MAX placeholders lay out, with their points on the plot.
+func TestLayoutHugeValues(t *testing.T) {
+	p := &Plot{Series: []Series{{Y: []float64{-math.MaxFloat64, 0, 1e308}, Marker: Circle}}}
+	registerFonts.Do(gofont.Register)
+	gtx := layout.NewContext(nil)
+	gtx.Reset(nil, image.Point{X: 400, Y: 300})
+	r := image.Rect(0, 0, 400, 300)
+	p.Layout(gtx, material.NewTheme(), r)
+	for i, y := range p.Series[0].Y {
+		pt := p.last.pt(float64(i), y)
+		if _, j, at, ok := p.Nearest(image.Point{X: int(pt.X), Y: int(pt.Y)}, 2); !ok || j != i || !at.In(r) {
+			t.Errorf("point %d is at %v (%v)", i, pt, ok)
+		}
+	}
+}
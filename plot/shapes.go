@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package plot
+
+import (
+	"image/color"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// The shapes are clip paths painted with a color. Each one gets a
+// path of its own: the clip uses the even-odd rule, so overlapping
+// shapes in a single path would cancel each other out.
+
+// drawSegment paints the line from a to b, width w wide. The ends
+// are extended by half the width, so that the segments of a
+// polyline overlap at the joints instead of leaving notches.
+func drawSegment(ops *op.Ops, c color.RGBA, a, b f32.Point, w float32) {
+	d := b.Sub(a)
+	l := float32(math.Hypot(float64(d.X), float64(d.Y)))
+	if l == 0 {
+		return
+	}
+	d = d.Mul(w / 2 / l)
+	n := f32.Point{X: -d.Y, Y: d.X}
+	a, b = a.Sub(d), b.Add(d)
+	fillPolygon(ops, c, a.Add(n), b.Add(n), b.Sub(n), a.Sub(n))
+}
+
+// drawMarker paints marker m of width size centered on p.
+func drawMarker(ops *op.Ops, c color.RGBA, m Marker, p f32.Point, size float32) {
+	r := size / 2
+	switch m {
+	case Circle:
+		var stack op.StackOp
+		stack.Push(ops)
+		var path clip.Path
+		path.Begin(ops)
+		circle(&path, p, r)
+		path.End().Add(ops)
+		paint.ColorOp{Color: c}.Add(ops)
+		paint.PaintOp{Rect: square(p, r)}.Add(ops)
+		stack.Pop()
+	case Square:
+		paint.ColorOp{Color: c}.Add(ops)
+		paint.PaintOp{Rect: square(p, r)}.Add(ops)
+	case Triangle:
+		// Pointing up, with its centroid on p.
+		h := size * float32(math.Sqrt(3)) / 2
+		fillPolygon(ops, c,
+			f32.Point{X: p.X, Y: p.Y - h*2/3},
+			f32.Point{X: p.X + r, Y: p.Y + h/3},
+			f32.Point{X: p.X - r, Y: p.Y + h/3},
+		)
+	}
+}
+
+// fillPolygon paints the closed polygon through pts.
+func fillPolygon(ops *op.Ops, c color.RGBA, pts ...f32.Point) {
+	var stack op.StackOp
+	stack.Push(ops)
+	var path clip.Path
+	path.Begin(ops)
+	bounds := f32.Rectangle{Min: pts[0], Max: pts[0]}
+	pen := f32.Point{}
+	for i, pt := range pts {
+		if i == 0 {
+			path.Move(pt)
+		} else {
+			path.Line(pt.Sub(pen))
+		}
+		pen = pt
+		bounds = bounds.Union(f32.Rectangle{Min: pt, Max: pt})
+	}
+	path.Line(pts[0].Sub(pen))
+	path.End().Add(ops)
+	paint.ColorOp{Color: c}.Add(ops)
+	paint.PaintOp{Rect: bounds}.Add(ops)
+	stack.Pop()
+}
+
+// circle adds a circle of radius r around c to p, whose pen is at
+// the origin.
+func circle(p *clip.Path, c f32.Point, r float32) {
+	// https://pomax.github.io/bezierinfo/#circles_cubic.
+	const k = 0.55228475 // 4*(sqrt(2)-1)/3
+	p.Move(f32.Point{X: c.X + r, Y: c.Y})
+	p.Cube(f32.Point{Y: r * k}, f32.Point{X: -r + r*k, Y: r}, f32.Point{X: -r, Y: r})
+	p.Cube(f32.Point{X: -r * k}, f32.Point{X: -r, Y: -r + r*k}, f32.Point{X: -r, Y: -r})
+	p.Cube(f32.Point{Y: -r * k}, f32.Point{X: r - r*k, Y: -r}, f32.Point{X: r, Y: -r})
+	p.Cube(f32.Point{X: r * k}, f32.Point{X: r, Y: r - r*k}, f32.Point{X: r, Y: r})
+}
+
+// square returns the square of half width r centered on p.
+func square(p f32.Point, r float32) f32.Rectangle {
+	return f32.Rectangle{
+		Min: f32.Point{X: p.X - r, Y: p.Y - r},
+		Max: f32.Point{X: p.X + r, Y: p.Y + r},
+	}
+}
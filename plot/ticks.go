@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package plot
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// ticks is the range of an axis and the round values marked on it.
+type ticks struct {
+	min, max float64
+	// step is the distance between ticks.
+	step  float64
+	ticks []float64
+	// time formats the ticks as times.
+	time bool
+}
+
+// axisTicks returns the ticks for a, whose data runs from min to
+// max. The range is a's if it is fixed, or else [min, max] rounded
+// out to whole steps.
+func axisTicks(a Axis, min, max float64) ticks {
+	stepFor := niceStep
+	if a.Time {
+		stepFor = timeStep
+	}
+	if a.Max > a.Min {
+		t := ticks{min: a.Min, max: a.Max, time: a.Time}
+		t.step = stepFor(span(t.min, t.max) / numTicks)
+		t.ticks = tickValues(t.min, t.max, t.step)
+		return t
+	}
+	switch {
+	case min > max:
+		// No data at all.
+		min, max = 0, 1
+	case min == max:
+		d := math.Abs(min) / 10
+		if d == 0 {
+			d = 1
+		}
+		min = math.Max(min-d, -math.MaxFloat64)
+		max = math.Min(max+d, math.MaxFloat64)
+	}
+	step := stepFor(span(min, max) / numTicks)
+	t := ticks{
+		min:  math.Floor(min/step) * step,
+		max:  math.Ceil(max/step) * step,
+		step: step,
+		time: a.Time,
+	}
+	// Near the ends of the float64 range, rounding out overflows.
+	if !finite(t.min) {
+		t.min = min
+	}
+	if !finite(t.max) {
+		t.max = max
+	}
+	t.ticks = tickValues(t.min, t.max, step)
+	return t
+}
+
+// span returns max-min, or the largest float64 where that
+// overflows, as it does for the DBL_MAX that some CSVs hold in
+// place of missing values.
+func span(min, max float64) float64 {
+	d := max - min
+	if math.IsInf(d, 0) {
+		d = math.Copysign(math.MaxFloat64, d)
+	}
+	return d
+}
+
+// niceStep rounds d up to 1, 2 or 5 times a power of ten.
+func niceStep(d float64) float64 {
+	if d <= 0 || !finite(d) {
+		return 1
+	}
+	p := math.Pow(10, math.Floor(math.Log10(d)))
+	for _, m := range []float64{1, 2, 5} {
+		if d <= m*p {
+			return m * p
+		}
+	}
+	return 10 * p
+}
+
+// timeSteps are the tick steps of time axes, in seconds, up to a
+// day. Longer steps are whole numbers of days from niceStep.
+var timeSteps = []float64{
+	1, 2, 5, 10, 15, 30,
+	60, 2 * 60, 5 * 60, 10 * 60, 15 * 60, 30 * 60,
+	3600, 2 * 3600, 3 * 3600, 6 * 3600, 12 * 3600,
+	86400,
+}
+
+// timeStep rounds d seconds up to a round time interval.
+func timeStep(d float64) float64 {
+	if d < 1 {
+		return niceStep(d)
+	}
+	for _, s := range timeSteps {
+		if d <= s {
+			return s
+		}
+	}
+	return niceStep(d/86400) * 86400
+}
+
+// maxTicks bounds the ticks of an axis, whatever its range and step.
+const maxTicks = 100
+
+// tickValues returns the multiples of step from min to max, up to
+// maxTicks of them. Values within a small fraction of a step of the
+// ends are included, so that rounding errors don't lose the first or
+// last tick.
+func tickValues(min, max, step float64) []float64 {
+	if !(step > 0) || !finite(step) || !finite(min) || !finite(max) {
+		return nil
+	}
+	eps := step * 1e-9
+	first := math.Ceil(min/step-1e-9) * step
+	var vs []float64
+	for i := 0; i < maxTicks; i++ {
+		// Halved, exactly, so that i*step can't overflow where v
+		// doesn't.
+		v := 2 * (first/2 + float64(i)*(step/2))
+		if !finite(v) || v-eps > max {
+			break
+		}
+		// Snap values that should be 0 but aren't quite.
+		if math.Abs(v) < eps {
+			v = 0
+		}
+		vs = append(vs, v)
+	}
+	return vs
+}
+
+// format returns the label of tick value v, with as many decimals
+// as the step needs.
+func (t ticks) format(v float64) string {
+	if t.time {
+		return t.formatTime(v)
+	}
+	av := math.Max(math.Abs(t.min), math.Abs(t.max))
+	if av >= 1e6 || t.step < 1e-4 {
+		return strconv.FormatFloat(v, 'g', 4, 64)
+	}
+	decimals := 0
+	if t.step < 1 {
+		decimals = int(math.Ceil(-math.Log10(t.step) - 1e-9))
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// formatTime returns the label of time tick v, leaving out the
+// parts that don't change from tick to tick.
+func (t ticks) formatTime(v float64) string {
+	sec := math.Floor(v)
+	tm := time.Unix(int64(sec), int64((v-sec)*1e9)).UTC()
+	switch {
+	case t.step >= 86400:
+		return tm.Format("2006-01-02")
+	case t.max-t.min > 86400:
+		return tm.Format("01-02 15:04")
+	case t.step >= 60:
+		return tm.Format("15:04")
+	case t.step >= 1:
+		return tm.Format("15:04:05")
+	}
+	return tm.Format("15:04:05.000")
+}
+
+// frac returns where v lies between min and max, from 0 to 1. The
+// values are halved first, which is exact, so that the differences
+// can't overflow.
+func (t ticks) frac(v float64) float64 {
+	return (v/2 - t.min/2) / (t.max/2 - t.min/2)
+}
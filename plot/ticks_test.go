@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package plot
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAxisTicks(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		axis     Axis
+		min, max float64
+		want     []string
+	}{
+		{"fitted", Axis{}, 0.3, 9.2, []string{"0", "2", "4", "6", "8", "10"}},
+		{"negative", Axis{}, -1, 1, []string{"-1.0", "-0.5", "0.0", "0.5", "1.0"}},
+		{"fixed", Axis{Min: -0.05, Max: 0.32}, 0, 100, []string{"0.0", "0.1", "0.2", "0.3"}},
+		{"one value", Axis{}, 5, 5, []string{"4.4", "4.6", "4.8", "5.0", "5.2", "5.4", "5.6"}},
+		{"no data", Axis{}, 1, -1, []string{"0.0", "0.2", "0.4", "0.6", "0.8", "1.0"}},
+		{"large", Axis{}, 0, 3e7, []string{"0", "5e+06", "1e+07", "1.5e+07", "2e+07", "2.5e+07", "3e+07"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := axisTicks(tc.axis, tc.min, tc.max)
+			var got []string
+			for _, v := range ts.ticks {
+				got = append(got, ts.format(v))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got ticks %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNiceStep(t *testing.T) {
+	for d, want := range map[float64]float64{
+		0.7:  1,
+		1:    1,
+		1.1:  2,
+		3:    5,
+		7:    10,
+		0.03: 0.05,
+		0:    1,
+	} {
+		if got := niceStep(d); got != want {
+			t.Errorf("niceStep(%v) = %v, want %v", d, got, want)
+		}
+	}
+}
+
+// Data near the ends of the float64 range, such as DBL_MAX
+// placeholders, still gets a few finite ticks.
+func TestHugeRange(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		axis     Axis
+		min, max float64
+	}{
+		{"both ends", Axis{}, -1e308, 1e308},
+		{"max", Axis{}, 0, math.MaxFloat64},
+		{"one value", Axis{}, 1.7e308, 1.7e308},
+		{"fixed", Axis{Min: -math.MaxFloat64, Max: math.MaxFloat64}, 0, 1},
+		{"time", Axis{Time: true}, -1e308, 1e308},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := axisTicks(tc.axis, tc.min, tc.max)
+			if !finite(ts.min) || !finite(ts.max) || !finite(ts.step) {
+				t.Fatalf("range %v to %v, step %v", ts.min, ts.max, ts.step)
+			}
+			if n := len(ts.ticks); n < 2 || n > maxTicks {
+				t.Errorf("%d ticks", n)
+			}
+			for _, v := range ts.ticks {
+				if f := ts.frac(v); !(f >= 0 && f <= 1) {
+					t.Errorf("tick %v lies at %v", v, f)
+				}
+			}
+		})
+	}
+}
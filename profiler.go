@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// The profiling HUD. Ctrl+P (Cmd+P on a Mac) shows the frame times
+// over the window, and Ctrl+Shift+P writes the samples kept so far
+// to a CSV file next to the saved frames.
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"time"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/hud"
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+var profileLog = vlog.New("profile")
+
+// profiler is the HUD with its hotkeys.
+type profiler struct {
+	hud hud.HUD
+	// dir is where the CSV files go.
+	dir string
+	// now returns the time that names the files.
+	now func() time.Time
+}
+
+// newProfiler writes its CSV files into dir.
+func newProfiler(dir string) *profiler {
+	return &profiler{dir: dir, now: time.Now}
+}
+
+// Key handles the hotkeys. It reports whether e was one of them.
+func (p *profiler) Key(e key.Event) bool {
+	if e.Name != "P" || !(e.Modifiers.Contain(key.ModCtrl) || e.Modifiers.Contain(key.ModCommand)) {
+		return false
+	}
+	if !e.Modifiers.Contain(key.ModShift) {
+		p.hud.Toggle()
+		return true
+	}
+	path := outputPath(p.dir, "profile", p.now(), ".csv")
+	if err := writeFile(path, p.hud.WriteCSV); err != nil {
+		profileLog.Warn("save failed", "path", path, "err", err)
+		p.hud.Note = fmt.Sprintf("saving failed: %v", err)
+	} else {
+		profileLog.Info("saved samples", "path", path, "n", len(p.hud.Samples()))
+		p.hud.Note = "saved " + filepath.Base(path)
+	}
+	return true
+}
+
+// Begin marks the start of laying out a frame.
+func (p *profiler) Begin() {
+	p.hud.Begin()
+}
+
+// Layout draws the HUD over a window of the given size. Lay it out
+// last, so that its op count covers the whole frame.
+func (p *profiler) Layout(gtx *layout.Context, th *material.Theme, window image.Point) {
+	p.hud.Layout(gtx, th, window)
+}
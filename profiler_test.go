@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/profile"
+
+	"github.com/glycerine/hello_gio.go/raster"
+)
+
+func TestGoldenProfiler(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	q := make(scriptQueue)
+	m := loadedDrawState(q, testScene(t))
+	th := testTheme()
+	e := testFrame()
+	p := newProfiler(dir)
+	p.now = func() time.Time { return time.Date(2019, 10, 1, 12, 30, 5, 250e6, time.UTC) }
+	// Each frame takes 1ms to lay out.
+	clock := time.Date(2019, 10, 1, 12, 30, 0, 0, time.UTC)
+	p.hud.Now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	frame := func(timings string) {
+		if timings != "" {
+			q[&p.hud] = []event.Event{profile.Event{Timings: timings}}
+		}
+		p.Begin()
+		drawFrame(m, th, e)
+		p.Layout(m.gtx, th, e.Size)
+	}
+
+	if p.Key(key.Event{Name: "P"}) {
+		t.Error("P without Ctrl was taken")
+	}
+	p.Key(key.Event{Name: "P", Modifiers: key.ModCtrl})
+	if !p.hud.Visible() {
+		t.Fatal("Ctrl+P did not show the HUD")
+	}
+	frame("")
+	for i := 0; i < 40; i++ {
+		total := []string{"8ms", "12ms", "30ms", "9ms"}[i%4]
+		frame("tot: " + total + " draw: 3ms gpu: 2.5ms")
+	}
+
+	p.Key(key.Event{Name: "P", Modifiers: key.ModCtrl | key.ModShift})
+	path := filepath.Join(dir, "hello_gio-profile-20191001-123005.250.csv")
+	if want := "saved " + filepath.Base(path); p.hud.Note != want {
+		t.Errorf("note is %q, want %q", p.hud.Note, want)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	recs, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 41 || recs[1][1] != "8.000" || recs[1][2] != "1.000" || recs[1][5] != "2.500" {
+		t.Errorf("%d rows, first sample %v", len(recs), recs[1])
+	}
+
+	frame("tot: 8ms draw: 3ms gpu: 2.5ms")
+	checkGolden(t, "profiler", raster.Render(m.gtx.Ops, e.Size))
+
+	p.Key(key.Event{Name: "P", Modifiers: key.ModCommand})
+	if p.hud.Visible() {
+		t.Error("Cmd+P did not hide the HUD")
+	}
+}
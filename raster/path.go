@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package raster
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"gioui.org/f32"
+
+	"github.com/glycerine/hello_gio.go/internal/ops"
+)
+
+// samplesPerPixel is the number of sample columns per pixel used for
+// anti-aliasing path edges. Coverage is exact in the vertical direction.
+const samplesPerPixel = 4
+
+// clipMask returns the coverage mask of path, offset by off and
+// restricted to bounds, intersected with the parent mask if any.
+func clipMask(parent *image.Alpha, bounds image.Rectangle, path []ops.Quad, off f32.Point) *image.Alpha {
+	mask := image.NewAlpha(bounds)
+	fillPath(mask, path, off)
+	if parent == nil {
+		return mask
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := mask.PixOffset(x, y)
+			a := uint32(mask.Pix[i]) * uint32(parent.AlphaAt(x, y).A)
+			mask.Pix[i] = uint8((a + 127) / 255)
+		}
+	}
+	return mask
+}
+
+// fillPath rasterizes path into mask with the even-odd rule.
+//
+// Like Gio's stencil shader, inside-ness is decided by counting
+// curve crossings along vertical rays. Every path segment is monotone
+// in x, so a ray crosses a segment at most once, and vertical
+// segments never contribute. That also means a contour that is not
+// explicitly closed behaves as if it were closed by a vertical line,
+// which is what clip.Rect relies on for its right edge.
+func fillPath(mask *image.Alpha, path []ops.Quad, off f32.Point) {
+	b := mask.Rect
+	w, h := b.Dx(), b.Dy()
+	if w <= 0 || h <= 0 {
+		return
+	}
+	cols := make([][]float32, w*samplesPerPixel)
+	x0 := float32(b.Min.X)
+	for _, q := range path {
+		q.From = q.From.Add(off)
+		q.Ctrl = q.Ctrl.Add(off)
+		q.To = q.To.Add(off)
+		minx, maxx := q.From.X, q.To.X
+		if minx > maxx {
+			minx, maxx = maxx, minx
+		}
+		// Sample s sits at x0 + (s+.5)/samplesPerPixel.
+		first := int(math.Ceil(float64((minx-x0)*samplesPerPixel - .5)))
+		if first < 0 {
+			first = 0
+		}
+		for s := first; s < len(cols); s++ {
+			x := x0 + (float32(s)+.5)/samplesPerPixel
+			if x >= maxx {
+				break
+			}
+			if x < minx {
+				continue
+			}
+			cols[s] = append(cols[s], crossY(q, x))
+		}
+	}
+	cover := make([]float32, w*h)
+	y0 := float32(b.Min.Y)
+	for s, ys := range cols {
+		if len(ys) < 2 {
+			continue
+		}
+		sort.Slice(ys, func(i, j int) bool { return ys[i] < ys[j] })
+		px := s / samplesPerPixel
+		for i := 0; i+1 < len(ys); i += 2 {
+			top, bot := ys[i]-y0, ys[i+1]-y0
+			if top < 0 {
+				top = 0
+			}
+			if bot > float32(h) {
+				bot = float32(h)
+			}
+			for row := int(top); float32(row) < bot; row++ {
+				c := minf(bot, float32(row+1)) - maxf(top, float32(row))
+				cover[row*w+px] += c / samplesPerPixel
+			}
+		}
+	}
+	for row := 0; row < h; row++ {
+		line := mask.Pix[row*mask.Stride:]
+		for px := 0; px < w; px++ {
+			c := cover[row*w+px]
+			if c > 1 {
+				c = 1
+			}
+			line[px] = uint8(c*255 + .5)
+		}
+	}
+}
+
+// crossY returns the y coordinate where the x-monotone quad q
+// crosses the vertical line at x.
+func crossY(q ops.Quad, x float32) float32 {
+	// x(t) = a t² + b t + c, solved for x(t) = x.
+	a := q.From.X - 2*q.Ctrl.X + q.To.X
+	b := 2 * (q.Ctrl.X - q.From.X)
+	c := q.From.X - x
+	var t float32
+	if math.Abs(float64(a)) < 1e-6 {
+		t = -c / b
+	} else {
+		// The roots are r/a and c/r, which unlike the textbook
+		// formula stays accurate when a is tiny, as it is for
+		// the quads that model lines.
+		d := float32(math.Sqrt(math.Max(float64(b*b-4*a*c), 0)))
+		if b < 0 {
+			d = -d
+		}
+		r := -(b + d) / 2
+		t = r / a
+		if (t < 0 || t > 1) && r != 0 {
+			t = c / r
+		}
+	}
+	t = maxf(0, minf(1, t))
+	mt := 1 - t
+	return mt*mt*q.From.Y + 2*mt*t*q.Ctrl.Y + t*t*q.To.Y
+}
+
+func minf(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxf(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package raster renders a Gio op.Ops frame into an *image.RGBA
+// entirely on the CPU, for machines that have neither a GPU nor
+// an EGL context.
+//
+// The op list is walked the same way gioui.org/app/internal/gpu
+// walks it: StackOp pushes and pops the drawing state, MacroOp and
+// CallOp are followed by the Reader, TransformOp offsets what comes
+// after it, clip.Op intersects the clip with a rectangle or an
+// even-odd filled clip.Path, and PaintOp fills its rectangle with the
+// current ColorOp or ImageOp material.
+//
+// Blending is done in sRGB space with draw.Over, so anti-aliased
+// edges come out slightly different from the gamma-correct GPU
+// renderer; solid areas and image pixels are the same.
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+	"github.com/glycerine/hello_gio.go/internal/ops"
+)
+
+// Renderer draws op lists into images. The zero value is ready
+// to use, and a Renderer may be reused for many frames.
+type Renderer struct {
+	reader ops.Reader
+	dst    *image.RGBA
+}
+
+type materialType uint8
+
+const (
+	materialColor materialType = iota
+	materialTexture
+)
+
+// drawState is the state saved and restored by StackOp.
+type drawState struct {
+	t op.TransformOp
+	// clip is the current clip bounds, in window coordinates.
+	clip f32.Rectangle
+	// mask holds the coverage of the current clip path, or
+	// nil when the clip is a plain rectangle.
+	mask *image.Alpha
+
+	matType materialType
+	color   color.RGBA
+	image   ops.ImageOp
+}
+
+// Render draws the operations in root into a new image of the
+// given size.
+func Render(root *op.Ops, size image.Point) *image.RGBA {
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	var r Renderer
+	r.Render(root, dst)
+	return dst
+}
+
+// Render draws the operations in root into dst, replacing its
+// contents.
+func (r *Renderer) Render(root *op.Ops, dst *image.RGBA) {
+	// The GPU renderer clears to white; so do we.
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	r.dst = dst
+	r.reader.Reset(root)
+	state := drawState{
+		clip:  toRectF(dst.Bounds()),
+		color: color.RGBA{A: 0xff},
+	}
+	r.collectOps(&r.reader, state)
+	r.dst = nil
+}
+
+func (r *Renderer) collectOps(rd *ops.Reader, state drawState) {
+	var path []ops.Quad
+loop:
+	for encOp, ok := rd.Decode(); ok; encOp, ok = rd.Decode() {
+		switch opconst.OpType(encOp.Data[0]) {
+		case opconst.TypeTransform:
+			dop := ops.DecodeTransformOp(encOp.Data)
+			state.t = state.t.Multiply(dop)
+		case opconst.TypeAux:
+			path = ops.DecodePath(encOp.Data)
+		case opconst.TypeClip:
+			bounds := ops.DecodeClipOp(encOp.Data)
+			off := state.t.Transform(f32.Point{})
+			state.clip = state.clip.Intersect(bounds.Add(off))
+			if len(path) > 0 && !state.clip.Empty() {
+				state.mask = clipMask(state.mask, boundRectF(state.clip), path, off)
+			}
+			path = nil
+		case opconst.TypeColor:
+			state.matType = materialColor
+			state.color = ops.DecodeColorOp(encOp.Data)
+		case opconst.TypeImage:
+			state.matType = materialTexture
+			state.image = ops.DecodeImageOp(encOp.Data, encOp.Refs)
+		case opconst.TypePaint:
+			r.paint(state, ops.DecodePaintOp(encOp.Data))
+		case opconst.TypePush:
+			r.collectOps(rd, state)
+		case opconst.TypePop:
+			break loop
+		}
+	}
+}
+
+// paint fills the PaintOp rectangle with the current material,
+// restricted to the current clip.
+func (r *Renderer) paint(state drawState, p paint.PaintOp) {
+	off := state.t.Transform(f32.Point{})
+	dr := p.Rect.Add(off)
+	b := roundRectF(state.clip.Intersect(dr))
+	if state.mask != nil {
+		b = b.Intersect(state.mask.Rect)
+	}
+	b = b.Intersect(r.dst.Rect)
+	if b.Empty() {
+		return
+	}
+	var src image.Image
+	switch state.matType {
+	case materialColor:
+		src = image.NewUniform(state.color)
+	case materialTexture:
+		src = scaleImage(state.image.Src, dr, b)
+	}
+	if state.mask == nil {
+		draw.Draw(r.dst, b, src, b.Min, draw.Over)
+		return
+	}
+	draw.DrawMask(r.dst, b, src, b.Min, state.mask, b.Min, draw.Over)
+}
+
+// scaleImage returns the part of src that lands in b when src is
+// stretched over dr, sampled bilinearly like a GL_LINEAR texture.
+func scaleImage(src *image.RGBA, dr f32.Rectangle, b image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(b)
+	sb := src.Bounds()
+	if sb.Empty() || dr.Dx() <= 0 || dr.Dy() <= 0 {
+		return dst
+	}
+	sx := float32(sb.Dx()) / dr.Dx()
+	sy := float32(sb.Dy()) / dr.Dy()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		v := (float32(y)+.5-dr.Min.Y)*sy - .5
+		for x := b.Min.X; x < b.Max.X; x++ {
+			u := (float32(x)+.5-dr.Min.X)*sx - .5
+			dst.SetRGBA(x, y, bilinear(src, u, v))
+		}
+	}
+	return dst
+}
+
+// bilinear samples src at the texel coordinates (u, v), clamping
+// to the edges.
+func bilinear(src *image.RGBA, u, v float32) color.RGBA {
+	sb := src.Bounds()
+	x0, fx := splitCoord(u, sb.Min.X, sb.Max.X)
+	y0, fy := splitCoord(v, sb.Min.Y, sb.Max.Y)
+	x1 := clampInt(x0+1, sb.Min.X, sb.Max.X-1)
+	y1 := clampInt(y0+1, sb.Min.Y, sb.Max.Y-1)
+	c00 := src.RGBAAt(x0, y0)
+	c10 := src.RGBAAt(x1, y0)
+	c01 := src.RGBAAt(x0, y1)
+	c11 := src.RGBAAt(x1, y1)
+	mix := func(a, b, c, d uint8) uint8 {
+		top := float32(a)*(1-fx) + float32(b)*fx
+		bot := float32(c)*(1-fx) + float32(d)*fx
+		return uint8(top*(1-fy) + bot*fy + .5)
+	}
+	return color.RGBA{
+		R: mix(c00.R, c10.R, c01.R, c11.R),
+		G: mix(c00.G, c10.G, c01.G, c11.G),
+		B: mix(c00.B, c10.B, c01.B, c11.B),
+		A: mix(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+// splitCoord splits c into a clamped integer texel index and the
+// fractional weight of the texel after it.
+func splitCoord(c float32, min, max int) (int, float32) {
+	f := float32(math.Floor(float64(c)))
+	i := int(f) + min
+	if i < min {
+		return min, 0
+	}
+	if i >= max-1 {
+		return max - 1, 0
+	}
+	return i, c - f
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func toRectF(r image.Rectangle) f32.Rectangle {
+	return f32.Rectangle{
+		Min: f32.Point{X: float32(r.Min.X), Y: float32(r.Min.Y)},
+		Max: f32.Point{X: float32(r.Max.X), Y: float32(r.Max.Y)},
+	}
+}
+
+// roundRectF rounds the edges of r to the nearest pixel, which
+// is what a GL scissor rectangle amounts to.
+func roundRectF(r f32.Rectangle) image.Rectangle {
+	round := func(v float32) int {
+		return int(math.Floor(float64(v) + .5))
+	}
+	return image.Rectangle{
+		Min: image.Point{X: round(r.Min.X), Y: round(r.Min.Y)},
+		Max: image.Point{X: round(r.Max.X), Y: round(r.Max.Y)},
+	}
+}
+
+// boundRectF returns the smallest pixel rectangle containing r.
+func boundRectF(r f32.Rectangle) image.Rectangle {
+	return image.Rectangle{
+		Min: image.Point{
+			X: int(math.Floor(float64(r.Min.X))),
+			Y: int(math.Floor(float64(r.Min.Y))),
+		},
+		Max: image.Point{
+			X: int(math.Ceil(float64(r.Max.X))),
+			Y: int(math.Ceil(float64(r.Max.Y))),
+		},
+	}
+}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"github.com/glycerine/hello_gio.go/internal/ops"
+)
+
+var (
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	red   = color.RGBA{R: 0xff, A: 0xff}
+	blue  = color.RGBA{B: 0xff, A: 0xff}
+)
+
+func rectF(x0, y0, x1, y1 float32) f32.Rectangle {
+	return f32.Rectangle{Min: f32.Point{X: x0, Y: y0}, Max: f32.Point{X: x1, Y: y1}}
+}
+
+// checkRect fails t unless the pixels of img in r are c, and those
+// outside it are white.
+func checkRect(t *testing.T, img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	t.Helper()
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := white
+			if (image.Point{X: x, Y: y}).In(r) {
+				want = c
+			}
+			if got := img.RGBAAt(x, y); got != want {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRenderRect(t *testing.T) {
+	o := new(op.Ops)
+	paint.ColorOp{Color: red}.Add(o)
+	// The edges round to the nearest pixel.
+	paint.PaintOp{Rect: rectF(2.4, 3, 6, 5.6)}.Add(o)
+	checkRect(t, Render(o, image.Point{X: 10, Y: 8}), image.Rect(2, 3, 6, 6), red)
+}
+
+func TestRenderTransform(t *testing.T) {
+	o := new(op.Ops)
+	var stack op.StackOp
+	stack.Push(o)
+	op.TransformOp{}.Offset(f32.Point{X: 3, Y: 2}).Add(o)
+	op.TransformOp{}.Offset(f32.Point{X: 1}).Add(o)
+	paint.ColorOp{Color: red}.Add(o)
+	paint.PaintOp{Rect: rectF(0, 0, 2, 2)}.Add(o)
+	stack.Pop()
+	// The pop restores both the offset and the color.
+	paint.PaintOp{Rect: rectF(0, 0, 1, 1)}.Add(o)
+	img := Render(o, image.Point{X: 8, Y: 6})
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{A: 0xff}) {
+		t.Errorf("pixel (0,0) is %v, want black", got)
+	}
+	img.SetRGBA(0, 0, white)
+	checkRect(t, img, image.Rect(4, 2, 6, 4), red)
+}
+
+func TestRenderImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, blue)
+	src.SetRGBA(0, 1, blue)
+	src.SetRGBA(1, 1, red)
+	o := new(op.Ops)
+	paint.NewImageOp(src).Add(o)
+	// Stretched to twice its size, one pixel in.
+	paint.PaintOp{Rect: rectF(1, 1, 5, 5)}.Add(o)
+	img := Render(o, image.Point{X: 6, Y: 6})
+	for _, tc := range []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, white},
+		{1, 1, red},
+		{4, 1, blue},
+		{1, 4, blue},
+		{4, 4, red},
+		{5, 5, white},
+		// Between the texels, bilinearly.
+		{2, 1, color.RGBA{R: 0xbf, B: 0x40, A: 0xff}},
+	} {
+		if got := img.RGBAAt(tc.x, tc.y); got != tc.want {
+			t.Errorf("pixel (%d,%d) is %v, want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestRenderClipPath(t *testing.T) {
+	o := new(op.Ops)
+	// A circle of radius 10 about (12,12), of four quads a corner.
+	clip.Rect{Rect: rectF(2, 2, 22, 22), NW: 10, NE: 10, SW: 10, SE: 10}.Op(o).Add(o)
+	paint.ColorOp{Color: red}.Add(o)
+	paint.PaintOp{Rect: rectF(0, 0, 24, 24)}.Add(o)
+	img := Render(o, image.Point{X: 24, Y: 24})
+	for _, p := range []image.Point{{12, 12}, {2, 12}, {21, 12}, {12, 3}} {
+		if got := img.RGBAAt(p.X, p.Y); got != red {
+			t.Errorf("pixel %v is %v, want red", p, got)
+		}
+	}
+	for _, p := range []image.Point{{2, 2}, {21, 21}, {1, 12}} {
+		if got := img.RGBAAt(p.X, p.Y); got != white {
+			t.Errorf("pixel %v is %v, want white", p, got)
+		}
+	}
+	// The edge is anti-aliased, and the area is about right.
+	var area float64
+	partial := 0
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 24; x++ {
+			g := img.RGBAAt(x, y).G
+			if g != 0 && g != 0xff {
+				partial++
+			}
+			area += 1 - float64(g)/0xff
+		}
+	}
+	if partial == 0 {
+		t.Error("no anti-aliased pixels")
+	}
+	if want := math.Pi * 100; math.Abs(area-want) > 3 {
+		t.Errorf("area %.1f, want %.1f", area, want)
+	}
+}
+
+func TestRenderEvenOdd(t *testing.T) {
+	o := new(op.Ops)
+	var p clip.Path
+	p.Begin(o)
+	// Two squares wound the same way; the inner one is a hole.
+	var pen f32.Point
+	for _, r := range []f32.Rectangle{rectF(1, 1, 9, 9), rectF(3, 3, 7, 7)} {
+		p.Move(r.Min.Sub(pen))
+		pen = r.Min
+		p.Line(f32.Point{X: r.Dx()})
+		p.Line(f32.Point{Y: r.Dy()})
+		p.Line(f32.Point{X: -r.Dx()})
+		p.Line(f32.Point{Y: -r.Dy()})
+	}
+	p.End().Add(o)
+	paint.ColorOp{Color: red}.Add(o)
+	paint.PaintOp{Rect: rectF(0, 0, 10, 10)}.Add(o)
+	img := Render(o, image.Point{X: 10, Y: 10})
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			want := white
+			if x >= 1 && x < 9 && y >= 1 && y < 9 && !(x >= 3 && x < 7 && y >= 3 && y < 7) {
+				want = red
+			}
+			if got := img.RGBAAt(x, y); got != want {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// Lines are quads with the control point halfway, where rounding
+// leaves a tiny second-order term.
+func TestCrossYLine(t *testing.T) {
+	from := f32.Point{X: 1000.3, Y: 0}
+	to := f32.Point{X: 1099.9, Y: 100}
+	q := ops.Quad{From: from, Ctrl: from.Add(to).Mul(.5), To: to}
+	for _, x := range []float32{1000.5, 1025, 1050, 1099.5} {
+		want := (x - from.X) / (to.X - from.X) * to.Y
+		if got := crossY(q, x); math.Abs(float64(got-want)) > .01 {
+			t.Errorf("crossY at %v is %v, want %v", x, got, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// Live reload: a watcher polls the modification times of the scene
+// file and of the images it draws, and decodes whatever changed on
+// its own goroutine. The UI goroutine picks up the results at the
+// start of the next frame, so the ops are never touched while a
+// frame is being laid out. The images of a reloaded scene are
+// decoded afresh by the imageLoader, and show the old pictures of
+// their files until then.
+
+import (
+	"image"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/glycerine/hello_gio.go/scene"
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+// reloadInterval is how often the watched files are checked.
+const reloadInterval = 500 * time.Millisecond
+
+var reloadLog = vlog.New("reload")
+
+// logReload logs the outcome of reloading path, as from its caller.
+func logReload(path string, err error) {
+	if err != nil {
+		reloadLog.Output(2, vlog.Warn, "reload failed", "path", path, "err", err)
+		return
+	}
+	reloadLog.Output(2, vlog.Info, "reloaded", "path", path)
+}
+
+// update is the result of reloading one changed file.
+type update struct {
+	// path is the file that changed, as the scene resolves it.
+	path string
+	// scene is set when the scene file reloaded.
+	scene *scene.Scene
+	// img is set when the image at path reloaded.
+	img image.Image
+	// err is why path failed to reload.
+	err error
+}
+
+// fileStamp is what the watcher compares to spot a changed file.
+type fileStamp struct {
+	mtime time.Time
+	size  int64
+}
+
+// watcher polls a scene file and its images for changes.
+type watcher struct {
+	// scene is the last scene that loaded, whose images are
+	// watched. Only the polling goroutine uses it.
+	scene  *scene.Scene
+	stamps map[string]fileStamp
+	// changed is called, on the polling goroutine, after an
+	// update has been queued. Set it to w.Invalidate.
+	changed func()
+
+	mu      sync.Mutex
+	updates []update
+}
+
+// newWatcher starts from sc, as loaded from sc.File. Files are
+// compared with their state at the time of the call.
+func newWatcher(sc *scene.Scene, changed func()) *watcher {
+	wt := &watcher{
+		scene:   sc,
+		stamps:  make(map[string]fileStamp),
+		changed: changed,
+	}
+	for _, path := range wt.paths() {
+		wt.stamps[path] = stat(path)
+	}
+	return wt
+}
+
+// run polls every interval, forever.
+func (wt *watcher) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		wt.poll()
+	}
+}
+
+// paths returns the watched files: the scene file first, then the
+// images.
+func (wt *watcher) paths() []string {
+	var paths []string
+	if wt.scene.File != "" {
+		paths = append(paths, wt.scene.File)
+	}
+	for _, im := range wt.scene.Images {
+		paths = append(paths, wt.scene.Resolve(im.Path))
+	}
+	return paths
+}
+
+// poll checks every watched file once, and reloads those that
+// changed since the last check. A file that cannot be stat'ed, as
+// happens while it is being rewritten, is tried again next time.
+func (wt *watcher) poll() {
+	for _, path := range wt.paths() {
+		st := stat(path)
+		old, seen := wt.stamps[path]
+		if seen && st == old {
+			continue
+		}
+		wt.stamps[path] = st
+		if !seen || st.mtime.IsZero() {
+			continue
+		}
+		if path == wt.scene.File {
+			wt.reloadScene()
+			// The image list may have changed; the
+			// images are all decoded anew anyway.
+			return
+		}
+		img, _, err := LoadImage(path)
+		logReload(path, err)
+		wt.queue(update{path: path, img: img, err: err})
+	}
+}
+
+func (wt *watcher) reloadScene() {
+	path := wt.scene.File
+	sc, err := scene.Load(path)
+	logReload(path, err)
+	if err != nil {
+		wt.queue(update{path: path, err: err})
+		return
+	}
+	wt.scene = sc
+	for _, p := range wt.paths() {
+		if _, seen := wt.stamps[p]; !seen {
+			wt.stamps[p] = stat(p)
+		}
+	}
+	wt.queue(update{path: path, scene: sc})
+}
+
+func (wt *watcher) queue(u update) {
+	wt.mu.Lock()
+	wt.updates = append(wt.updates, u)
+	wt.mu.Unlock()
+	if wt.changed != nil {
+		wt.changed()
+	}
+}
+
+// take returns and forgets the queued updates.
+func (wt *watcher) take() []update {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	u := wt.updates
+	wt.updates = nil
+	return u
+}
+
+func stat(path string) fileStamp {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{mtime: fi.ModTime(), size: fi.Size()}
+}
+
+// apply swaps the reloaded files into m. A reloaded scene replaces
+// the images, which start loading, and the boxes, back at their
+// starting positions; until they load, the images keep the pictures
+// of the old ones with the same file. A reloaded image is handed to
+// the loader to be made ready to draw, and shows from the frame
+// after. A file that failed to reload keeps what was drawn before,
+// and gets an error badge instead.
+func (m *myDrawState) apply(u update) {
+	if u.path == m.scene.File {
+		if u.err != nil {
+			m.sceneErr = u.err
+			return
+		}
+		old := make(map[string]*sceneImage)
+		for _, si := range m.images {
+			if si.img != nil {
+				old[m.scene.Resolve(si.spec.Path)] = si
+			}
+		}
+		m.scene = u.scene
+		m.images = loadSceneImages(u.scene, m.loader)
+		for _, si := range m.images {
+			if o := old[u.scene.Resolve(si.spec.Path)]; o != nil {
+				si.loadedImage, si.view = o.loadedImage, o.view
+			}
+		}
+		m.plots = scenePlots(u.scene)
+		m.canvas = newCanvas(u.scene)
+		m.sceneErr = nil
+		return
+	}
+	for _, si := range m.images {
+		if m.scene.Resolve(si.spec.Path) != u.path {
+			continue
+		}
+		if u.err != nil {
+			si.err = u.err
+			continue
+		}
+		m.loader.prepare(si, u.img)
+	}
+}
+
+// applyUpdates applies what the watcher has reloaded, if there is
+// a watcher.
+func (m *myDrawState) applyUpdates() {
+	if m.watcher == nil {
+		return
+	}
+	for _, u := range m.watcher.take() {
+		m.apply(u)
+	}
+}
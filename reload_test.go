@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glycerine/hello_gio.go/raster"
+	"github.com/glycerine/hello_gio.go/scene"
+)
+
+// reloadDir holds a scene drawing one 4x4 image, pic.png, scaled up to 400x400, and lets
+// tests rewrite both files.
+type reloadDir struct {
+	t   *testing.T
+	dir string
+	// mtime is moved forward on every write, so that a change is
+	// seen however coarse the file system clock.
+	mtime time.Time
+}
+
+func newReloadDir(t *testing.T) *reloadDir {
+	dir, err := ioutil.TempDir("", "hello_gio_reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &reloadDir{t: t, dir: dir, mtime: time.Now().Add(-time.Hour)}
+	d.writePNG(color.RGBA{255, 0, 0, 255})
+	d.write("scene.json", `{"version": 1, "images": [{"path": "pic.png", "dest": {"x": 10, "y": 10, "w": 400}}]}`)
+	return d
+}
+
+func (d *reloadDir) write(name, data string) {
+	d.t.Helper()
+	path := filepath.Join(d.dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		d.t.Fatal(err)
+	}
+	d.touch(path)
+}
+
+func (d *reloadDir) writePNG(c color.RGBA) {
+	d.t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	path := filepath.Join(d.dir, "pic.png")
+	if err := writePNG(path, img); err != nil {
+		d.t.Fatal(err)
+	}
+	d.touch(path)
+}
+
+func (d *reloadDir) touch(path string) {
+	d.mtime = d.mtime.Add(time.Second)
+	if err := os.Chtimes(path, d.mtime, d.mtime); err != nil {
+		d.t.Fatal(err)
+	}
+}
+
+// reloadFrame polls for changes and draws a frame of m, then waits
+// for the loader and draws the frame after, where what it made
+// ready shows.
+func reloadFrame(m *myDrawState) *image.RGBA {
+	m.watcher.poll()
+	drawFrame(m, testTheme(), testFrame())
+	m.loader.wait()
+	drawFrame(m, testTheme(), testFrame())
+	return raster.Render(m.gtx.Ops, goldenWindowSize)
+}
+
+func TestReloadImage(t *testing.T) {
+	d := newReloadDir(t)
+	defer os.RemoveAll(d.dir)
+	sc, err := scene.Load(filepath.Join(d.dir, "scene.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := loadedDrawState(nil, sc)
+	invalidated := 0
+	m.watcher = newWatcher(sc, func() { invalidated++ })
+
+	if got := reloadFrame(m).RGBAAt(30, 30); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Fatalf("before reload got %v, want red", got)
+	}
+	if invalidated != 0 {
+		t.Errorf("invalidated %d times with no change", invalidated)
+	}
+
+	d.writePNG(color.RGBA{0, 0, 255, 255})
+	if got := reloadFrame(m).RGBAAt(30, 30); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("after reload got %v, want blue", got)
+	}
+	if invalidated != 1 {
+		t.Errorf("invalidated %d times, want 1", invalidated)
+	}
+
+	// A broken file keeps the old image, with a badge over it.
+	d.write("pic.png", "not a png")
+	reloadFrame(m)
+	if m.images[0].err == nil {
+		t.Fatal("no error for a broken image")
+	}
+	if m.images[0].img == nil {
+		t.Fatal("broken image dropped the old one")
+	}
+	if got := reloadFrame(m).RGBAAt(200, 200); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("below the badge got %v, want the old blue image", got)
+	}
+}
+
+func TestReloadScene(t *testing.T) {
+	d := newReloadDir(t)
+	defer os.RemoveAll(d.dir)
+	sc, err := scene.Load(filepath.Join(d.dir, "scene.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := loadedDrawState(nil, sc)
+	m.watcher = newWatcher(sc, nil)
+
+	d.write("scene.json", `{"version": 1, "background": "#00ff00",
+		"boxes": [{"pos": {"x": 100, "y": 100}, "size": {"w": 20, "h": 20}}]}`)
+	img := reloadFrame(m)
+	if m.scene.Background != "#00ff00" || len(m.images) != 0 || len(m.canvas.boxes) != 1 {
+		t.Fatalf("scene not reloaded: %+v", m.scene)
+	}
+	if got := img.RGBAAt(30, 30); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("got %v, want the new green background", got)
+	}
+
+	d.write("scene.json", `{"version": 1, "background": "#00ff0"}`)
+	reloadFrame(m)
+	if m.sceneErr == nil {
+		t.Fatal("no error for an invalid scene")
+	}
+	if m.scene.Background != "#00ff00" {
+		t.Error("invalid scene replaced the old one")
+	}
+}
+
+// TestReloadSceneKeepsImages checks that the images of a reloaded
+// scene show the old pictures of their files while they decode
+// again, and after, if the decode fails.
+func TestReloadSceneKeepsImages(t *testing.T) {
+	d := newReloadDir(t)
+	defer os.RemoveAll(d.dir)
+	sc, err := scene.Load(filepath.Join(d.dir, "scene.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := loadedDrawState(nil, sc)
+	m.watcher = newWatcher(sc, nil)
+	red := color.RGBA{255, 0, 0, 255}
+
+	d.write("scene.json", `{"version": 1, "background": "#00ff00",
+		"images": [{"path": "pic.png", "dest": {"x": 10, "y": 10, "w": 400}}]}`)
+	m.watcher.poll()
+	drawFrame(m, testTheme(), testFrame())
+	if m.scene.Background != "#00ff00" {
+		t.Fatalf("scene not reloaded: %+v", m.scene)
+	}
+	if got := raster.Render(m.gtx.Ops, goldenWindowSize).RGBAAt(30, 30); got != red {
+		t.Errorf("while decoding got %v, want the old red image", got)
+	}
+	m.loader.wait()
+
+	// The file breaks, and the scene reloads before the watcher
+	// sees it.
+	d.write("pic.png", "not a png")
+	d.write("scene.json", `{"version": 1,
+		"images": [{"path": "pic.png", "dest": {"x": 10, "y": 10, "w": 400}}]}`)
+	img := reloadFrame(m)
+	if si := m.images[0]; si.err == nil || si.img == nil {
+		t.Fatalf("got image %v and error %v, want the old image and an error", si.img, si.err)
+	}
+	if got := img.RGBAAt(200, 200); got != red {
+		t.Errorf("below the badge got %v, want the old red image", got)
+	}
+}
+
+// TestGoldenBadge covers the error tile in place of an image that
+// failed to load, and the badge of a scene that failed to reload.
+func TestGoldenBadge(t *testing.T) {
+	sc := testScene(t)
+	sc.Images[0].Path = "missing.png"
+	m := loadedDrawState(nil, sc)
+	m.images[0].err = &os.PathError{Op: "open", Path: "missing.png", Err: os.ErrNotExist}
+	m.sceneErr = &scene.Error{Filename: "scene.json", Line: 3, Col: 17,
+		Field: "background", Msg: `invalid color "#00ff0"; want #rrggbb, #rrggbbaa or a color name`}
+	e := testFrame()
+	drawFrame(m, testTheme(), e)
+	checkGolden(t, "badge", raster.Render(m.gtx.Ops, e.Size))
+}
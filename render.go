@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/io/system"
+	"gioui.org/op"
+	"gioui.org/unit"
+
+	"github.com/glycerine/hello_gio.go/inspect"
+	"github.com/glycerine/hello_gio.go/opfile"
+	"github.com/glycerine/hello_gio.go/pdf"
+	"github.com/glycerine/hello_gio.go/raster"
+	"github.com/glycerine/hello_gio.go/scene"
+	"github.com/glycerine/hello_gio.go/svg"
+	"github.com/glycerine/hello_gio.go/table"
+)
+
+// renderConfig is the system.Config of frames drawn without a
+// window: one pixel per dp and sp, so sizes in a scene file come
+// out as pixels.
+type renderConfig struct{}
+
+func (renderConfig) Now() time.Time {
+	return time.Now()
+}
+
+func (renderConfig) Px(v unit.Value) int {
+	return int(v.V + .5)
+}
+
+// render draws a frame of the scene, or of the data plot, and
+// writes it to o.out: as a PDF if its name ends in .pdf, as SVG if
+// it ends in .svg, as a text tree of its ops if it ends in .txt, as
+// an op list file if it ends in .ops, or else drawn on the CPU into
+// a PNG. A PDF has a page for each of o.scenes.
+func render(o *options) error {
+	if isPDF(o.out) {
+		return writePDF(o)
+	}
+	if len(o.scenes) > 0 {
+		o.scene = o.scenes[0]
+	}
+	ops, size, err := o.frame()
+	if err != nil {
+		return err
+	}
+	switch ext := filepath.Ext(o.out); {
+	case strings.EqualFold(ext, ".svg"):
+		return writeFile(o.out, func(w io.Writer) error { return svg.Encode(w, ops, size) })
+	case strings.EqualFold(ext, ".txt"):
+		return writeFile(o.out, func(w io.Writer) error { return inspect.Dump(w, ops) })
+	case isOpFile(o.out):
+		return writeOpFile(o.out, ops, size)
+	}
+	return writePNG(o.out, raster.Render(ops, size))
+}
+
+func isPDF(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pdf")
+}
+
+// frame draws what o draws, at o.size, and returns its ops and
+// its size. An op list file is loaded, at the size it was saved at.
+func (o *options) frame() (*op.Ops, image.Point, error) {
+	if o.data == "" && isOpFile(o.scene) {
+		return readOpFile(o.scene)
+	}
+	sc, data, err := o.load()
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	m := newDrawState(nil, sc)
+	m.data = data
+	m.loader.wait()
+	m.loader.apply()
+	for _, si := range m.images {
+		if si.err != nil {
+			return nil, image.Point{}, si.err
+		}
+	}
+	// Draw until no tiled image is waiting for tiles.
+	th := newTheme()
+	for {
+		drawFrame(m, th, system.FrameEvent{Config: renderConfig{}, Size: o.size})
+		if !m.waitTiles() {
+			return m.gtx.Ops, o.size, nil
+		}
+	}
+}
+
+// load returns what o draws: the scene, or a blank scene and the
+// plot of the data file.
+func (o *options) load() (*scene.Scene, *dataView, error) {
+	if o.data == "" {
+		sc, err := o.loadScene()
+		return sc, nil, err
+	}
+	t, err := table.Load(o.data)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := newDataView(o.data, t, o.x, o.y)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &scene.Scene{Version: scene.Version, Background: "white"}, data, nil
+}
+
+// opFileExt is the extension of op list files.
+const opFileExt = ".ops"
+
+func isOpFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), opFileExt)
+}
+
+// writeOpFile saves the frame in ops, of the given size, into the
+// op list file at path.
+func writeOpFile(path string, ops *op.Ops, size image.Point) error {
+	return writeFile(path, func(w io.Writer) error { return opfile.Encode(w, ops, size) })
+}
+
+// readOpFile loads the frame saved in the op list file at path.
+func readOpFile(path string) (*op.Ops, image.Point, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	defer f.Close()
+	ops, size, err := opfile.Decode(f)
+	if err != nil {
+		return nil, image.Point{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return ops, size, nil
+}
+
+// writePDF writes a PDF with a page for each of o.scenes, or for
+// the one frame o draws if there are none. The frames are all laid
+// out first, so a scene that fails to load leaves no file behind.
+func writePDF(o *options) error {
+	scenes := o.scenes
+	if len(scenes) == 0 {
+		scenes = []string{o.scene}
+	}
+	type frame struct {
+		ops  *op.Ops
+		size image.Point
+	}
+	var frames []frame
+	for _, s := range scenes {
+		po := *o
+		po.scene = s
+		ops, size, err := po.frame()
+		if err != nil {
+			return err
+		}
+		frames = append(frames, frame{ops, size})
+	}
+	return writeFile(o.out, func(w io.Writer) error {
+		pw := pdf.NewWriter(w)
+		for _, f := range frames {
+			if err := pw.Page(f.ops, f.size); err != nil {
+				return err
+			}
+		}
+		return pw.Close()
+	})
+}
+
+// writePNG encodes img into the file at path.
+func writePNG(path string, img image.Image) error {
+	return writeFile(path, func(w io.Writer) error { return png.Encode(w, img) })
+}
+
+// writeFile writes the file at path with encode, creating its
+// directory if needed. If encode fails, the file is removed.
+func writeFile(path string, encode func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// outputPath returns the path in dir of a file the app saves, named
+// after its kind, if any, and the time t:
+// hello_gio-profile-20191001-123005.250.csv.
+func outputPath(dir, kind string, t time.Time, ext string) string {
+	name := "hello_gio-"
+	if kind != "" {
+		name += kind + "-"
+	}
+	return filepath.Join(dir, name+t.Format("20060102-150405.000")+ext)
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package scene
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/unit"
+)
+
+// The placement modes of an image.
+const (
+	// PlaceFixed draws the image at its dest rectangle.
+	PlaceFixed = "fixed"
+	// PlaceFit scales the image to the largest size that fits in
+	// the window, keeping its aspect ratio, and centers it.
+	PlaceFit = "fit"
+	// PlaceFill scales the image to the smallest size that covers
+	// the window, keeping its aspect ratio, centers it, and cuts
+	// off what sticks out.
+	PlaceFill = "fill"
+	// PlaceActual draws the image at one device pixel per image
+	// pixel, whatever the screen density, centers it, and cuts
+	// off what sticks out of the window.
+	PlaceActual = "actual"
+)
+
+var placeModes = map[string]bool{
+	"":          true,
+	PlaceFixed:  true,
+	PlaceFit:    true,
+	PlaceFill:   true,
+	PlaceActual: true,
+}
+
+// Placement is where an image goes in the window, in pixels.
+type Placement struct {
+	// Area is the part of the window given to the image, which the
+	// letterbox fills.
+	Area image.Rectangle
+	// View is the part of the window that shows the image.
+	View image.Rectangle
+	// Base is where the whole source lands, unzoomed. It is View
+	// except in fill mode, and for an actual size image bigger than
+	// the window, where it overflows View and should be clipped to
+	// it.
+	Base image.Rectangle
+}
+
+// Placement returns where im goes in a window of the given size,
+// given the size of the source rectangle it draws. Dp are converted
+// to pixels with c, usually the layout.Context, so the placement
+// follows the screen density.
+func (im *Image) Placement(src, window image.Point, c unit.Converter) Placement {
+	bw := c.Px(unit.Dp(float32(im.Border.Width)))
+	// The border stays inside the window.
+	area := image.Rectangle{Max: window}
+	area.Min = area.Min.Add(image.Point{X: bw, Y: bw})
+	area.Max = area.Max.Sub(image.Point{X: bw, Y: bw})
+	if area.Empty() {
+		area = image.Rectangle{}
+	}
+	var view, base image.Rectangle
+	switch im.Place {
+	case PlaceFit, PlaceFill:
+		if src.X <= 0 || src.Y <= 0 {
+			view, base = area, area
+			break
+		}
+		sx := float64(area.Dx()) / float64(src.X)
+		sy := float64(area.Dy()) / float64(src.Y)
+		s := math.Min(sx, sy)
+		if im.Place == PlaceFill {
+			s = math.Max(sx, sy)
+		}
+		size := image.Point{
+			X: int(math.Round(float64(src.X) * s)),
+			Y: int(math.Round(float64(src.Y) * s)),
+		}
+		base = center(area, size)
+		view = base.Intersect(area)
+	case PlaceActual:
+		base = center(area, src)
+		view = base.Intersect(area)
+	default:
+		view = dpRect(c, im.Dest.place(src))
+		base = view
+		area = view
+	}
+	return Placement{Area: area, View: view, Base: base}
+}
+
+// dp converts v dp to pixels.
+func dp(c unit.Converter, v int) int {
+	return c.Px(unit.Dp(float32(v)))
+}
+
+// dpRect converts r from dp to pixels. Its size is converted apart
+// from its position, so that rectangles of the same size in dp stay
+// the same size in pixels.
+func dpRect(c unit.Converter, r image.Rectangle) image.Rectangle {
+	min := image.Point{X: dp(c, r.Min.X), Y: dp(c, r.Min.Y)}
+	return image.Rectangle{Min: min, Max: min.Add(image.Point{X: dp(c, r.Dx()), Y: dp(c, r.Dy())})}
+}
+
+// center returns the rectangle of the given size centered in r.
+func center(r image.Rectangle, size image.Point) image.Rectangle {
+	min := r.Min.Add(r.Size().Sub(size).Div(2))
+	return image.Rectangle{Min: min, Max: min.Add(size)}
+}
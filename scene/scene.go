@@ -0,0 +1,378 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package scene reads the JSON files that describe what hello_gio
+// draws: a background color, boxes with labels, images and plots,
+// each at a position of your choosing.
+//
+// A scene file looks like
+//
+//	{
+//	  "version": 1,
+//	  "background": "cream",
+//	  "images": [
+//	    {"path": "points.png", "dest": {"x": 300, "y": 200, "w": 1000}}
+//	  ],
+//	  "boxes": [
+//	    {"pos": {"x": 100, "y": 0}, "size": {"w": 50, "h": 50},
+//	     "fill": "#ffcc00", "label": "hello", "padding": 5}
+//	  ]
+//	}
+//
+// Colors are "#rrggbb", "#rrggbbaa" or one of the names in Colors.
+// Positions and sizes are in dp, and text sizes in sp, so that a
+// scene looks the same on screens of any density. The exceptions
+// are the src rectangles of images, in image pixels, and the line
+// widths and marker sizes of plots, in pixels.
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gioui.org/unit"
+
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/plot"
+)
+
+// Version is the schema version this package reads. Files must
+// declare it in their "version" field.
+const Version = 1
+
+// Scene is the decoded content of a scene file.
+type Scene struct {
+	Version int `json:"version"`
+	// Background fills the whole window. Empty means no fill.
+	Background string  `json:"background,omitempty"`
+	Images     []Image `json:"images,omitempty"`
+	// Plots are drawn after the images.
+	Plots []Plot `json:"plots,omitempty"`
+	// Boxes are drawn after, and so on top of, the images. Later
+	// boxes are drawn on top of earlier ones.
+	Boxes []Box `json:"boxes,omitempty"`
+
+	// File is the path the scene was loaded from, if any.
+	File string `json:"-"`
+	// Dir is the directory of the scene file. Relative image paths
+	// are relative to it.
+	Dir string `json:"-"`
+}
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type Size struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type Rect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Border is drawn inside the edge of a box, or around an image. Its
+// width is in dp. The zero Border, as for a scene that gives none,
+// draws nothing.
+type Border struct {
+	Width int    `json:"width"`
+	Color string `json:"color"`
+}
+
+// Box is a filled rectangle with a label. Its position, size,
+// border, corner radius and padding are in dp.
+type Box struct {
+	Pos   Point  `json:"pos"`
+	Size  Size   `json:"size"`
+	Fill  string `json:"fill,omitempty"`
+	Label string `json:"label,omitempty"`
+	// Tip is shown when the pointer rests on the box. Empty means
+	// the label, which may be cut short in the box.
+	Tip          string `json:"tip,omitempty"`
+	Border       Border `json:"border"`
+	CornerRadius int    `json:"cornerRadius,omitempty"`
+	// Padding insets the label from the border.
+	Padding int `json:"padding,omitempty"`
+	// TextSize is the label size in sp. Zero means the theme's size.
+	TextSize float32 `json:"textSize,omitempty"`
+}
+
+// Image is a picture file drawn into a destination rectangle, or
+// fitted to the window.
+type Image struct {
+	Path string `json:"path"`
+	// Place is the placement mode, one of PlaceFixed (the default),
+	// PlaceFit, PlaceFill and PlaceActual.
+	Place string `json:"place,omitempty"`
+	// Dest is where a fixed image is drawn, in dp. If only one of
+	// W and H is given, the other follows from the aspect ratio of
+	// the source; if neither is, the source is drawn at one dp per
+	// pixel.
+	Dest Rect `json:"dest"`
+	// Src, if set, selects the part of the image to draw, in image
+	// pixels.
+	Src *Rect `json:"src,omitempty"`
+	// Border is painted around the image.
+	Border Border `json:"border"`
+	// Letterbox fills the window around an image that is fitted
+	// to it or shown at its actual size. Empty means no fill.
+	Letterbox string `json:"letterbox,omitempty"`
+	// Loops, if not zero, is how many times an animated GIF or
+	// PNG plays, in place of the count in the file. Negative means
+	// forever.
+	Loops int `json:"loops,omitempty"`
+}
+
+// Plot is a scatter or line plot, drawn with vector ops by the plot
+// package.
+type Plot struct {
+	// Dest is where the plot is drawn, axes and labels included,
+	// in dp. Missing sizes are filled in as for an Image of
+	// PlotSize.
+	Dest   Rect   `json:"dest"`
+	Title  string `json:"title,omitempty"`
+	XLabel string `json:"xLabel,omitempty"`
+	YLabel string `json:"yLabel,omitempty"`
+	// XRange and YRange fix the axis ranges. By default they are
+	// fitted to the data.
+	XRange *Range `json:"xRange,omitempty"`
+	YRange *Range `json:"yRange,omitempty"`
+	Grid   bool   `json:"grid,omitempty"`
+	// Background fills Dest. Empty means no fill.
+	Background string `json:"background,omitempty"`
+	// TextSize is the tick label size in sp. Zero means 0.75 times
+	// the theme's size.
+	TextSize float32  `json:"textSize,omitempty"`
+	Series   []Series `json:"series"`
+}
+
+// Range is the extent of a plot axis.
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Series is one set of points of a plot.
+type Series struct {
+	// X is optional; the points are at 0, 1, 2, ... without it.
+	X []float64 `json:"x,omitempty"`
+	Y []float64 `json:"y"`
+	// Color defaults to the next color of plot.Palette.
+	Color     string  `json:"color,omitempty"`
+	LineWidth float32 `json:"lineWidth,omitempty"`
+	// Marker is one of the names in Markers. Empty means none.
+	Marker     string  `json:"marker,omitempty"`
+	MarkerSize float32 `json:"markerSize,omitempty"`
+}
+
+// PlotSize is the size in dp a plot is given when its dest has
+// neither width nor height.
+var PlotSize = image.Point{X: 600, Y: 400}
+
+// Markers are the marker names understood in scene files.
+var Markers = map[string]plot.Marker{
+	"":         plot.NoMarker,
+	"circle":   plot.Circle,
+	"square":   plot.Square,
+	"triangle": plot.Triangle,
+}
+
+// Colors are the color names understood in scene files.
+var Colors = map[string]color.RGBA{
+	"black":  {0, 0, 0, 255},
+	"white":  {255, 255, 255, 255},
+	"maroon": {127, 0, 0, 255},
+	"cream":  {240, 240, 127, 255},
+	"aqua":   {0, 0xcc, 200, 255},
+}
+
+// Load reads and validates the scene file at path.
+func Load(path string) (*Scene, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(path, data)
+	if err != nil {
+		return nil, err
+	}
+	s.File = path
+	s.Dir = filepath.Dir(path)
+	return s, nil
+}
+
+// Parse decodes and validates a scene. The filename is only used
+// in error messages. Errors are reported as an ErrorList, with the
+// line and column of every problem found.
+func Parse(filename string, data []byte) (*Scene, error) {
+	v := &validator{filename: filename, data: data}
+	s := new(Scene)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(s); err != nil {
+		v.decodeError(err)
+		return nil, v.errs
+	}
+	v.scene(s)
+	if len(v.errs) > 0 {
+		sort.SliceStable(v.errs, func(i, j int) bool {
+			a, b := v.errs[i], v.errs[j]
+			return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+		})
+		return nil, v.errs
+	}
+	return s, nil
+}
+
+// Resolve returns path relative to the scene directory, unless it
+// is absolute.
+func (s *Scene) Resolve(path string) string {
+	if filepath.IsAbs(path) || s.Dir == "" {
+		return path
+	}
+	return filepath.Join(s.Dir, path)
+}
+
+// BackgroundColor returns the background color, and false if the
+// scene has none.
+func (s *Scene) BackgroundColor() (color.RGBA, bool) {
+	if s.Background == "" {
+		return color.RGBA{}, false
+	}
+	c, _ := ParseColor(s.Background)
+	return c, true
+}
+
+// Style returns the look of b for the box package, in pixels as
+// converted by c. The scene must have been validated.
+func (b *Box) Style(c unit.Converter) box.Box {
+	fill, _ := ParseColor(b.Fill)
+	stroke, _ := ParseColor(b.Border.Color)
+	st := box.Box{
+		Size:         image.Point{X: dp(c, b.Size.W), Y: dp(c, b.Size.H)},
+		Fill:         fill,
+		StrokeWidth:  dp(c, b.Border.Width),
+		Stroke:       stroke,
+		CornerRadius: dp(c, b.CornerRadius),
+		Padding:      dp(c, b.Padding),
+	}
+	if b.TextSize > 0 {
+		st.TextSize = unit.Sp(b.TextSize)
+	}
+	return st
+}
+
+// Plot returns p for the plot package. The scene must have been
+// validated.
+func (p *Plot) Plot() *plot.Plot {
+	bkg, _ := ParseColor(p.Background)
+	pl := &plot.Plot{
+		Title:      p.Title,
+		X:          plot.Axis{Label: p.XLabel},
+		Y:          plot.Axis{Label: p.YLabel},
+		Grid:       p.Grid,
+		Background: bkg,
+	}
+	if p.XRange != nil {
+		pl.X.Min, pl.X.Max = p.XRange.Min, p.XRange.Max
+	}
+	if p.YRange != nil {
+		pl.Y.Min, pl.Y.Max = p.YRange.Min, p.YRange.Max
+	}
+	if p.TextSize > 0 {
+		pl.TextSize = unit.Sp(p.TextSize)
+	}
+	for _, s := range p.Series {
+		c, _ := ParseColor(s.Color)
+		pl.Series = append(pl.Series, plot.Series{
+			X:          s.X,
+			Y:          s.Y,
+			Color:      c,
+			LineWidth:  s.LineWidth,
+			Marker:     Markers[strings.ToLower(s.Marker)],
+			MarkerSize: s.MarkerSize,
+		})
+	}
+	return pl
+}
+
+// SrcRect returns the part of an image with the given bounds that
+// im draws.
+func (im *Image) SrcRect(bounds image.Rectangle) image.Rectangle {
+	if im.Src == nil {
+		return bounds
+	}
+	r := image.Rect(im.Src.X, im.Src.Y, im.Src.X+im.Src.W, im.Src.Y+im.Src.H)
+	return r.Add(bounds.Min).Intersect(bounds)
+}
+
+// Position returns the top-left corner of b, in pixels as converted
+// by c.
+func (b *Box) Position(c unit.Converter) image.Point {
+	return image.Point{X: dp(c, b.Pos.X), Y: dp(c, b.Pos.Y)}
+}
+
+// Place returns the destination rectangle of p, in pixels as
+// converted by c.
+func (p *Plot) Place(c unit.Converter) image.Rectangle {
+	return dpRect(c, p.Dest.place(PlotSize))
+}
+
+// place fills in the missing width or height of r from the size of
+// the source drawn into it.
+func (r Rect) place(src image.Point) image.Rectangle {
+	w, h := r.W, r.H
+	switch {
+	case w == 0 && h == 0:
+		w, h = src.X, src.Y
+	case h == 0 && src.X > 0:
+		// choose height to maintain aspect ratio
+		h = int(float64(w) * float64(src.Y) / float64(src.X))
+	case w == 0 && src.Y > 0:
+		w = int(float64(h) * float64(src.X) / float64(src.Y))
+	}
+	return image.Rect(r.X, r.Y, r.X+w, r.Y+h)
+}
+
+// BorderRect returns the border of width w around r.
+func BorderRect(r image.Rectangle, w int) image.Rectangle {
+	return image.Rectangle{
+		Min: r.Min.Sub(image.Point{X: w, Y: w}),
+		Max: r.Max.Add(image.Point{X: w, Y: w}),
+	}
+}
+
+// ParseColor parses "#rrggbb", "#rrggbbaa" or a name from Colors.
+// The empty string is transparent.
+func ParseColor(s string) (color.RGBA, error) {
+	if s == "" {
+		return color.RGBA{}, nil
+	}
+	if c, ok := Colors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	if !strings.HasPrefix(s, "#") || (len(s) != 7 && len(s) != 9) {
+		return color.RGBA{}, fmt.Errorf("invalid color %q; want #rrggbb, #rrggbbaa or a color name", s)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q; want #rrggbb, #rrggbbaa or a color name", s)
+	}
+	if len(s) == 7 {
+		v = v<<8 | 0xff
+	}
+	c := color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+	return color.RGBAModel.Convert(c).(color.RGBA), nil
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package scene
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"gioui.org/unit"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "version",
+			src:  `{"version": 2}`,
+			want: []string{"s.json:1:13: version: unsupported version 2; want 1"},
+		},
+		{
+			name: "missing version",
+			src:  "\n  {}",
+			want: []string{"s.json:2:3: version: unsupported version 0; want 1"},
+		},
+		{
+			name: "fields",
+			src: `{
+  "version": 1,
+  "boxes": [
+    {"size": {"w": 10, "h": 10}},
+    {"size": {"w": 0, "h": 10},
+     "fill": "#12345"}
+  ],
+  "images": [{"dest": {"w": -1}}]
+}`,
+			want: []string{
+				"s.json:5:20: boxes[1].size.w: must be positive, got 0",
+				`s.json:6:14: boxes[1].fill: invalid color "#12345"; want #rrggbb, #rrggbbaa or a color name`,
+				"s.json:8:14: images[0].path: missing image path",
+				"s.json:8:29: images[0].dest.w: must not be negative, got -1",
+			},
+		},
+		{
+			name: "plot",
+			src: `{"version": 1, "plots": [{
+  "yRange": {"min": 1, "max": 1},
+  "series": [{"x": [1, 2], "y": [3], "marker": "star"}]}]}`,
+			want: []string{
+				"s.json:2:31: plots[0].yRange.max: must be above min 1, got 1",
+				"s.json:3:20: plots[0].series[0].x: got 2 x values for 1 y values",
+				`s.json:3:48: plots[0].series[0].marker: unknown marker "star"; want circle, square or triangle`,
+			},
+		},
+		{
+			name: "placement",
+			src: `{"version": 1, "images": [
+  {"path": "a.png", "place": "stretch"},
+  {"path": "b.png", "letterbox": "black"}]}`,
+			want: []string{
+				`s.json:2:30: images[0].place: unknown placement "stretch"; want fixed, fit, fill or actual`,
+				"s.json:3:34: images[1].letterbox: only fit, fill and actual images have a letterbox",
+			},
+		},
+		{
+			name: "syntax",
+			src:  "{\n  \"version\": 1,\n}",
+			want: []string{"s.json:3:1: invalid character '}' looking for beginning of object key string"},
+		},
+		{
+			name: "type",
+			src:  "{\n  \"version\": \"one\"\n}",
+			want: []string{"s.json:2:19: version: cannot use JSON string as int"},
+		},
+		{
+			name: "unknown field",
+			src:  "{\"version\": 1,\n \"boxes\": [{\"colour\": \"black\"}]}",
+			want: []string{`s.json:2:23: boxes[0].colour: unknown field "colour"`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse("s.json", []byte(tc.src))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			got := strings.Split(err.Error(), "\n")
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("got errors\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(tc.want, "\n\t"))
+			}
+		})
+	}
+}
+
+func TestLoadDefaultScene(t *testing.T) {
+	s, err := Load("../scene.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Resolve(s.Images[0].Path), "../points.png"; got != want {
+		t.Errorf("image path resolves to %q, want %q", got, want)
+	}
+	if len(s.Boxes) != 5 {
+		t.Errorf("got %d boxes, want 5", len(s.Boxes))
+	}
+}
+
+// dpScale converts dp to pixels on a screen of the given density.
+type dpScale float32
+
+func (s dpScale) Px(v unit.Value) int {
+	return int(math.Round(float64(v.V * float32(s))))
+}
+
+func TestImagePlacement(t *testing.T) {
+	src := image.Point{X: 1704, Y: 1116}
+	window := image.Point{X: 1000, Y: 800}
+	for _, tc := range []struct {
+		name  string
+		im    Image
+		scale dpScale
+		want  Placement
+	}{
+		{
+			name:  "fixed",
+			im:    Image{Dest: Rect{X: 300, Y: 200, W: 1000}},
+			scale: 1,
+			want: Placement{
+				Area: image.Rect(300, 200, 1300, 854),
+				View: image.Rect(300, 200, 1300, 854),
+				Base: image.Rect(300, 200, 1300, 854),
+			},
+		},
+		{
+			name:  "fixed dp",
+			im:    Image{Dest: Rect{X: 30, Y: 20, W: 100}},
+			scale: 2,
+			want: Placement{
+				Area: image.Rect(60, 40, 260, 170),
+				View: image.Rect(60, 40, 260, 170),
+				Base: image.Rect(60, 40, 260, 170),
+			},
+		},
+		{
+			name:  "fit",
+			im:    Image{Place: PlaceFit},
+			scale: 1,
+			want: Placement{
+				Area: image.Rect(0, 0, 1000, 800),
+				View: image.Rect(0, 72, 1000, 727),
+				Base: image.Rect(0, 72, 1000, 727),
+			},
+		},
+		{
+			name:  "fit inside border",
+			im:    Image{Place: PlaceFit, Border: Border{Width: 5, Color: "black"}},
+			scale: 2,
+			want: Placement{
+				Area: image.Rect(10, 10, 990, 790),
+				View: image.Rect(10, 79, 990, 721),
+				Base: image.Rect(10, 79, 990, 721),
+			},
+		},
+		{
+			name:  "fill",
+			im:    Image{Place: PlaceFill},
+			scale: 1,
+			want: Placement{
+				Area: image.Rect(0, 0, 1000, 800),
+				View: image.Rect(0, 0, 1000, 800),
+				Base: image.Rect(-111, 0, 1111, 800),
+			},
+		},
+		{
+			name:  "actual",
+			im:    Image{Place: PlaceActual},
+			scale: .5,
+			want: Placement{
+				Area: image.Rect(0, 0, 1000, 800),
+				View: image.Rect(0, 0, 1000, 800),
+				Base: image.Rect(-352, -158, 1352, 958),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.im.Placement(src, window, tc.scale)
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for s, want := range map[string]color.RGBA{
+		"":          {},
+		"Cream":     {240, 240, 127, 255},
+		"#ffcc00":   {255, 0xcc, 0, 255},
+		"#ff000080": {128, 0, 0, 128},
+	} {
+		got, err := ParseColor(s)
+		if err != nil || got != want {
+			t.Errorf("ParseColor(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Error is a problem found in a scene file.
+type Error struct {
+	Filename  string
+	Line, Col int
+	// Field is the path of the offending value, such as
+	// "boxes[2].size.w". It is empty for syntax errors.
+	Field string
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Line, e.Col, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.Filename, e.Line, e.Col, e.Field, e.Msg)
+}
+
+// ErrorList is the list of problems found in a scene file, in
+// document order.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// validator collects the errors of one scene file.
+type validator struct {
+	filename string
+	data     []byte
+	// offsets maps the path of every value in data to the offset
+	// where it starts. It is built on first use.
+	offsets map[string]int
+	errs    ErrorList
+}
+
+func (v *validator) scene(s *Scene) {
+	if s.Version != Version {
+		v.errorf("version", "unsupported version %d; want %d", s.Version, Version)
+	}
+	v.color("background", s.Background)
+	for i := range s.Images {
+		im := &s.Images[i]
+		p := fmt.Sprintf("images[%d]", i)
+		if im.Path == "" {
+			v.errorf(p+".path", "missing image path")
+		}
+		v.nonNegative(p+".dest.w", im.Dest.W)
+		v.nonNegative(p+".dest.h", im.Dest.H)
+		if im.Src != nil {
+			v.nonNegative(p+".src.x", im.Src.X)
+			v.nonNegative(p+".src.y", im.Src.Y)
+			v.positive(p+".src.w", im.Src.W)
+			v.positive(p+".src.h", im.Src.H)
+		}
+		v.border(p+".border", im.Border)
+		if !placeModes[im.Place] {
+			v.errorf(p+".place", "unknown placement %q; want fixed, fit, fill or actual", im.Place)
+		}
+		v.color(p+".letterbox", im.Letterbox)
+		if im.Letterbox != "" && (im.Place == "" || im.Place == PlaceFixed) {
+			v.errorf(p+".letterbox", "only fit, fill and actual images have a letterbox")
+		}
+	}
+	for i := range s.Plots {
+		v.plot(fmt.Sprintf("plots[%d]", i), &s.Plots[i])
+	}
+	for i := range s.Boxes {
+		b := &s.Boxes[i]
+		p := fmt.Sprintf("boxes[%d]", i)
+		v.positive(p+".size.w", b.Size.W)
+		v.positive(p+".size.h", b.Size.H)
+		v.color(p+".fill", b.Fill)
+		v.border(p+".border", b.Border)
+		v.nonNegative(p+".cornerRadius", b.CornerRadius)
+		v.nonNegative(p+".padding", b.Padding)
+		if b.TextSize < 0 {
+			v.errorf(p+".textSize", "must not be negative, got %v", b.TextSize)
+		}
+	}
+}
+
+func (v *validator) plot(path string, p *Plot) {
+	v.nonNegative(path+".dest.w", p.Dest.W)
+	v.nonNegative(path+".dest.h", p.Dest.H)
+	v.axisRange(path+".xRange", p.XRange)
+	v.axisRange(path+".yRange", p.YRange)
+	v.color(path+".background", p.Background)
+	if p.TextSize < 0 {
+		v.errorf(path+".textSize", "must not be negative, got %v", p.TextSize)
+	}
+	if len(p.Series) == 0 {
+		v.errorf(path+".series", "missing series")
+	}
+	for i := range p.Series {
+		s := &p.Series[i]
+		sp := fmt.Sprintf("%s.series[%d]", path, i)
+		if len(s.Y) == 0 {
+			v.errorf(sp+".y", "missing y values")
+		}
+		if s.X != nil && len(s.X) != len(s.Y) {
+			v.errorf(sp+".x", "got %d x values for %d y values", len(s.X), len(s.Y))
+		}
+		v.color(sp+".color", s.Color)
+		if _, ok := Markers[strings.ToLower(s.Marker)]; !ok {
+			v.errorf(sp+".marker", "unknown marker %q; want circle, square or triangle", s.Marker)
+		}
+		if s.LineWidth < 0 {
+			v.errorf(sp+".lineWidth", "must not be negative, got %v", s.LineWidth)
+		}
+		if s.MarkerSize < 0 {
+			v.errorf(sp+".markerSize", "must not be negative, got %v", s.MarkerSize)
+		}
+		if s.LineWidth == 0 && s.Marker == "" {
+			v.errorf(sp, "draws nothing; give it a lineWidth or a marker")
+		}
+	}
+}
+
+func (v *validator) axisRange(path string, r *Range) {
+	if r != nil && r.Max <= r.Min {
+		v.errorf(path+".max", "must be above min %v, got %v", r.Min, r.Max)
+	}
+}
+
+func (v *validator) border(path string, b Border) {
+	v.nonNegative(path+".width", b.Width)
+	v.color(path+".color", b.Color)
+	if b.Width > 0 && b.Color == "" {
+		v.errorf(path+".color", "missing color for border of width %d", b.Width)
+	}
+}
+
+func (v *validator) color(path, c string) {
+	if _, err := ParseColor(c); err != nil {
+		v.errorf(path, "%v", err)
+	}
+}
+
+func (v *validator) positive(path string, n int) {
+	if n <= 0 {
+		v.errorf(path, "must be positive, got %d", n)
+	}
+}
+
+func (v *validator) nonNegative(path string, n int) {
+	if n < 0 {
+		v.errorf(path, "must not be negative, got %d", n)
+	}
+}
+
+// errorf records an error at the value for path, or at its closest
+// enclosing value if path is missing from the file.
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	if v.offsets == nil {
+		v.offsets = locate(v.data)
+	}
+	p := path
+	off, ok := v.offsets[p]
+	for !ok && p != "" {
+		p = parentPath(p)
+		off, ok = v.offsets[p]
+	}
+	v.add(path, off, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) add(field string, off int, msg string) {
+	line, col := lineCol(v.data, off)
+	v.errs = append(v.errs, &Error{
+		Filename: v.filename,
+		Line:     line,
+		Col:      col,
+		Field:    field,
+		Msg:      msg,
+	})
+}
+
+var unknownField = regexp.MustCompile(`^json: unknown field "(.*)"$`)
+
+// decodeError translates an encoding/json error into an Error at
+// the right line.
+func (v *validator) decodeError(err error) {
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		// Offset is just past the offending byte.
+		off := int(err.Offset)
+		if off > 0 {
+			off--
+		}
+		v.add("", off, err.Error())
+	case *json.UnmarshalTypeError:
+		v.add(err.Field, int(err.Offset), fmt.Sprintf("cannot use JSON %s as %v", err.Value, err.Type))
+	default:
+		if m := unknownField.FindStringSubmatch(err.Error()); m != nil {
+			v.unknownField(m[1])
+			return
+		}
+		if err.Error() == "EOF" {
+			v.add("", 0, "empty scene file")
+			return
+		}
+		v.add("", 0, err.Error())
+	}
+}
+
+// unknownField reports a field name that is not part of the schema.
+// encoding/json does not say where the field is, so the first field
+// with that name in the file is blamed.
+func (v *validator) unknownField(name string) {
+	v.offsets = locate(v.data)
+	field, off := "", -1
+	for p, o := range v.offsets {
+		if (p == name || strings.HasSuffix(p, "."+name)) && (off == -1 || o < off) {
+			field, off = p, o
+		}
+	}
+	if off == -1 {
+		off = 0
+	}
+	v.add(field, off, fmt.Sprintf("unknown field %q", name))
+}
+
+// locate maps the path of every value in the JSON document data,
+// such as "boxes[2].size.w", to the offset of its first byte. The
+// path of the document itself is "".
+func locate(data []byte) map[string]int {
+	offsets := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var walk func(path string) error
+	walk = func(path string) error {
+		off := valueStart(data, int(dec.InputOffset()))
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		offsets[path] = off
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				p := key.(string)
+				if path != "" {
+					p = path + "." + p
+				}
+				if err := walk(p); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	// Invalid documents are reported by the decoder; what was
+	// located up to the error is still useful.
+	walk("")
+	return offsets
+}
+
+// valueStart skips the white space and separators between the end
+// of the previous token at off and the start of the next value.
+func valueStart(data []byte, off int) int {
+	for off < len(data) {
+		switch data[off] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			off++
+		default:
+			return off
+		}
+	}
+	return off
+}
+
+// parentPath returns the path of the value enclosing path.
+func parentPath(path string) string {
+	i := strings.LastIndexAny(path, ".[")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
+
+// lineCol converts a byte offset into a 1-based line and column.
+func lineCol(data []byte, off int) (line, col int) {
+	if off > len(data) {
+		off = len(data)
+	}
+	line = 1 + bytes.Count(data[:off], []byte("\n"))
+	col = 1 + off - (bytes.LastIndexByte(data[:off], '\n') + 1)
+	return line, col
+}
@@ -6,6 +6,7 @@ import (
 	"bufio"
 	"image"
 	"image/color"
+	"image/draw"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
@@ -13,9 +14,22 @@ import (
 
 	"gioui.org/app"
 	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
 	"gioui.org/io/system"
 	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
 	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/anim"
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/plot"
+	"github.com/glycerine/hello_gio.go/scene"
+	"github.com/glycerine/hello_gio.go/tiled"
+	"github.com/glycerine/hello_gio.go/tooltip"
 )
 
 var colors = make(map[string]color.RGBA)
@@ -30,33 +44,193 @@ type myDrawState struct {
 	w   *app.Window
 	gtx *layout.Context
 
-	pngPlot     image.Image
-	pngImageOp  paint.ImageOp
-	pngPlotRect image.Rectangle
+	// scene is what we draw.
+	scene *scene.Scene
+	// images holds the scene images, in scene order.
+	images []*sceneImage
+	// loader decodes the scene images.
+	loader *imageLoader
+
+	// plots are the scene plots, in scene order.
+	plots []*plot.Plot
+
+	// data, if not nil, plots a table across the window.
+	data *dataView
+
+	// canvas holds the draggable boxes.
+	canvas *canvas
+	// tips shows the tooltips of the boxes and plot points.
+	tips tooltip.Tips
+
+	// watcher, if not nil, reloads the scene and images when
+	// their files change.
+	watcher *watcher
+	// sceneErr is why the scene file last failed to reload.
+	sceneErr error
+}
+
+// sceneImage is a scene image, and once its file is decoded, the
+// ops to paint it.
+type sceneImage struct {
+	spec *scene.Image
+	// loadedImage is the picture drawn. Its img is nil until the
+	// image has loaded.
+	loadedImage
+	// place is where the image went in the last frame.
+	place scene.Placement
+	// view is the zoom and pan of the image.
+	view imageView
+	// err is why the image last failed to load. The previous
+	// image, if any, is still drawn.
+	err error
+}
+
+// loadedImage is a decoded image made ready to draw. Making it
+// crops and copies the pixels, so it is done on the goroutine that
+// decoded them, not the UI goroutine.
+type loadedImage struct {
+	img image.Image
+	// src is the part of img that is drawn.
+	src image.Rectangle
+	// imageOp holds src, unless it is too big for one texture and
+	// tiles draws it instead.
+	imageOp paint.ImageOp
+	tiles   *tiled.Image
+	// player, if not nil, plays an animated image, and sets
+	// imageOp to the frame it shows.
+	player *anim.Player
+}
+
+// tileThreshold is the widest or tallest image drawn as one
+// ImageOp; bigger ones are drawn from tiles.
+var tileThreshold = 4096
+
+// newLoadedImage makes img ready to draw as spec says.
+func newLoadedImage(spec *scene.Image, img image.Image) loadedImage {
+	li := loadedImage{img: img, src: spec.SrcRect(img.Bounds())}
+	if a, ok := img.(*anim.Animation); ok {
+		li.player = anim.NewPlayer(a, li.src)
+		li.player.Loops = spec.Loops
+	} else if sz := li.src.Size(); sz.X > tileThreshold || sz.Y > tileThreshold {
+		li.tiles = tiled.New(img, li.src)
+	} else {
+		li.imageOp = paint.NewImageOp(cropImage(img, li.src))
+	}
+	return li
+}
+
+// setImage makes li the picture si draws.
+func (si *sceneImage) setImage(li loadedImage) {
+	if li.src.Size() != si.src.Size() {
+		si.view = imageView{}
+	}
+	si.loadedImage = li
+	si.err = nil
+}
+
+// animKey handles the playback keys of an animated image: Space
+// plays and pauses, and "." and "," step a frame forward and back.
+// It reports whether e was one of them.
+func (si *sceneImage) animKey(e key.Event) bool {
+	if si.player == nil {
+		return false
+	}
+	switch e.Name {
+	case "Space", " ":
+		si.player.Toggle()
+	case ".":
+		si.player.Step(1)
+	case ",":
+		si.player.Step(-1)
+	default:
+		return false
+	}
+	return true
+}
+
+// animKey passes e to the animated scene images. It reports
+// whether any of them used it.
+func (m *myDrawState) animKey(e key.Event) bool {
+	used := false
+	for _, si := range m.images {
+		if si.animKey(e) {
+			used = true
+		}
+	}
+	return used
 }
 
-func setupDrawState(w *app.Window) *myDrawState {
+// setupDrawState prepares to draw sc in w, and starts watching the
+// scene file and images for changes.
+func setupDrawState(w *app.Window, sc *scene.Scene) *myDrawState {
+	m := newDrawState(w.Queue(), sc)
+	m.w = w
+	m.loader.setChanged(w.Invalidate)
+	m.watcher = newWatcher(sc, w.Invalidate)
+	go m.watcher.run(reloadInterval)
+	return m
+}
+
+// newDrawState starts loading the images of sc and prepares a
+// layout context reading events from q. It needs no window, so
+// tests can use it with a nil queue.
+func newDrawState(q event.Queue, sc *scene.Scene) *myDrawState {
 	m := &myDrawState{
-		w: w,
-	}
-	m.gtx = layout.NewContext(w.Queue())
-	var err error
-	m.pngPlot, _, err = LoadImage("points.png")
-	panicOn(err)
-	m.pngPlotRect = m.pngPlot.(*image.NRGBA).Rect
-	m.pngImageOp = paint.NewImageOp(m.pngPlot)
-	vv("m.pngPlot.Rect = '%#v'", m.pngPlotRect)
+		scene:  sc,
+		loader: newImageLoader(),
+		plots:  scenePlots(sc),
+		canvas: newCanvas(sc),
+	}
+	m.images = loadSceneImages(sc, m.loader)
+	m.gtx = layout.NewContext(q)
 	return m
 }
 
-func showImageMain() {
+// loadSceneImages returns the images of sc, and starts decoding
+// them with l. An image that fails to load is kept, with its error,
+// so that it can be reported.
+func loadSceneImages(sc *scene.Scene, l *imageLoader) []*sceneImage {
+	var images []*sceneImage
+	for i := range sc.Images {
+		si := &sceneImage{spec: &sc.Images[i]}
+		l.load(si, sc.Resolve(si.spec.Path))
+		images = append(images, si)
+	}
+	return images
+}
+
+// scenePlots converts the plots of sc for the plot package.
+func scenePlots(sc *scene.Scene) []*plot.Plot {
+	var plots []*plot.Plot
+	for i := range sc.Plots {
+		plots = append(plots, sc.Plots[i].Plot())
+	}
+	return plots
+}
+
+// showImageMain runs the image viewer on files in a window of
+// its own. Frames are saved into shots. Animations play loops
+// times, unless loops is zero.
+func showImageMain(files []string, shots string, loops int, opts ...app.Option) {
 
 	go func() {
-		w := app.NewWindow()
+		w := app.NewWindow(opts...)
 
 		var err error
-		m := setupDrawState(w)
-		yellowBkg := false // show image on background field of yellow?
+		v := newViewer(files, w.Invalidate)
+		v.loops = loops
+		saver := newFrameSaver(shots, w.Invalidate)
+		console := newLogConsole(logs, w.Invalidate)
+		prof := newProfiler(shots)
+		insp := newOpInspector(shots)
+		// The viewer has the key focus, and passes the hotkeys on.
+		saver.focus = false
+		saver.keys = func(e key.Event) bool {
+			return console.Key(e) || prof.Key(e) || insp.Key(e)
+		}
+		v.keys = saver.Key
+		gtx := layout.NewContext(w.Queue())
+		theme := newTheme()
 
 	mainLoop:
 		for {
@@ -66,9 +240,15 @@ func showImageMain() {
 				err = e.Err
 				break mainLoop
 			case system.FrameEvent:
-				//vv("e is '%#v'", e)
-				showImage(e, m, yellowBkg)
-				e.Frame(m.gtx.Ops)
+				prof.Begin()
+				gtx.Reset(e.Config, e.Size)
+				v.Layout(gtx, theme, e.Size)
+				insp.Layout(gtx, theme, e.Size)
+				saver.Layout(gtx, theme, e.Size)
+				console.Layout(gtx, theme, e.Size)
+				prof.Layout(gtx, theme, e.Size)
+				e.Frame(gtx.Ops)
+				saver.Submitted(gtx, e.Size)
 			}
 		}
 		panicOn(err)
@@ -76,54 +256,165 @@ func showImageMain() {
 	app.Main()
 }
 
-func showImage(e system.FrameEvent, m *myDrawState, yellowBkg bool) {
+// showImage paints the scene background, if any, and the scene
+// images with their letterboxes and borders, or their spinners while
+// they load.
+func showImage(e system.FrameEvent, m *myDrawState) {
 	m.gtx.Reset(e.Config, e.Size)
 	//	m.gtx.Reset(&e.Config, e.Size)
 	ops := m.gtx.Ops
+	m.loader.apply()
 
-	// choose how big to show the png
-	width := 1000
-	// choose height to maintain aspect ratio
-	yx := float64(m.pngPlotRect.Max.Y) / float64(m.pngPlotRect.Max.X)
-	height := int(float64(width) * yx)
+	// Get full window rectangle in order to paint the background.
+	fullWindowRect := image.Rectangle{Max: image.Point{X: e.Size.X, Y: e.Size.Y}}
+	if bkg, ok := m.scene.BackgroundColor(); ok {
+		// lets us see easily where the png limits are.
+		paint.ColorOp{Color: bkg}.Add(ops)
+		paint.PaintOp{Rect: toRectF(fullWindowRect)}.Add(ops)
+	}
 
-	// choose where to place the png
-	x0 := 300
-	x1 := x0 + width
-	y0 := 200
-	y1 := y0 + height
-	imgPos := image.Rectangle{
-		Min: image.Point{X: x0, Y: y0},
-		Max: image.Point{X: x1, Y: y1},
+	for _, si := range m.images {
+		si.Layout(m.gtx, e.Size)
 	}
-	borderPx := 5 // pixel width of border
-	borderRect := image.Rectangle{
-		Min: image.Point{X: x0 - borderPx, Y: y0 - borderPx},
-		Max: image.Point{X: x1 + borderPx, Y: y1 + borderPx},
+}
+
+// Layout places si in a window of the given size, and paints it
+// with its letterbox and border.
+func (si *sceneImage) Layout(gtx *layout.Context, window image.Point) {
+	ops := gtx.Ops
+	// choose where to place the png, and how big to show it,
+	// maintaining the aspect ratio unless the scene says otherwise.
+	// The placement follows the window size and density, so it
+	// is redone every frame.
+	if si.img == nil {
+		si.place = si.spec.Placement(placeholderSize, window, gtx)
+		if si.err == nil {
+			si.layoutLoading(gtx)
+		}
+		// drawBadges shows the error in place of the image.
+		return
 	}
+	si.place = si.spec.Placement(si.src.Size(), window, gtx)
+	imgPos := si.place.View
 
-	// Get full window rectangle in order to paint the background.
-	fullWindowRect := image.Rectangle{Max: image.Point{X: e.Size.X, Y: e.Size.Y}}
-	fullWindowRect32 := toRectF(fullWindowRect)
-	if yellowBkg {
-		// lets us see easily where the png limits are.
-		paint.ColorOp{Color: colors["cream"]}.Add(ops)
-		paint.PaintOp{Rect: fullWindowRect32}.Add(ops)
-	} else {
+	if c, _ := scene.ParseColor(si.spec.Letterbox); c.A > 0 {
+		paint.ColorOp{Color: c}.Add(ops)
+		paint.PaintOp{Rect: toRectF(si.place.Area)}.Add(ops)
+	}
+	if b := si.spec.Border; b.Width > 0 {
 		// just paint a border
-		paint.ColorOp{Color: colors["cream"]}.Add(ops)
-		paint.PaintOp{Rect: toRectF(borderRect)}.Add(ops)
+		c, _ := scene.ParseColor(b.Color)
+		paint.ColorOp{Color: c}.Add(ops)
+		bw := gtx.Px(unit.Dp(float32(b.Width)))
+		paint.PaintOp{Rect: toRectF(scene.BorderRect(imgPos, bw))}.Add(ops)
 	}
 
 	// Show the png image.
-	// Here we show all of it, but we could just show a subset by
-	// changing Imageop.Rect.
-
-	// The ImageOp.Rect specifies the source rectangle.
+	// The ImageOp holds the source pixels: all of the image, or
+	// the part the scene selects with "src".
 	// The PaintOp.Rect field specifies the destination rectangle.
 	// Scale the PaintOp.Rect to change the size of the rendered png.
-	m.pngImageOp.Add(ops)                         // set the source rectangle for the png.
-	paint.PaintOp{Rect: toRectF(imgPos)}.Add(ops) // set the destination rectangle.
+	// To zoom, the PaintOp.Rect grows past imgPos, and the clip
+	// cuts it back. A tiled image paints only the tiles in imgPos.
+	si.view.Update(gtx, si.place, si.src.Size())
+	dest := si.view.PaintRect(si.place, si.src.Size())
+	if si.player != nil {
+		si.player.Update(gtx)
+		si.imageOp = si.player.ImageOp()
+	}
+	if si.tiles != nil {
+		si.tiles.Layout(gtx, dest, imgPos)
+		si.view.Add(ops, imgPos)
+		return
+	}
+	var stack op.StackOp
+	stack.Push(ops)
+	clip.Rect{Rect: toRectF(imgPos)}.Op(ops).Add(ops)
+	si.imageOp.Add(ops)                // set the source for the png.
+	paint.PaintOp{Rect: dest}.Add(ops) // set the destination rectangle.
+	stack.Pop()
+	si.view.Add(ops, imgPos)
+}
+
+// waitTiles waits for the tiles that the tiled images lacked in the
+// last frame, and reports whether there were any.
+func (m *myDrawState) waitTiles() bool {
+	waited := false
+	for _, si := range m.images {
+		if si.tiles != nil && si.tiles.Loading() {
+			si.tiles.Wait()
+			waited = true
+		}
+	}
+	return waited
+}
+
+// drawPlots draws the scene plots, each into its dest rectangle.
+// Unlike points.png they are drawn from the data, so they stay sharp
+// at any size.
+func drawPlots(gtx *layout.Context, th *material.Theme, m *myDrawState) {
+	for i, p := range m.plots {
+		p.Layout(gtx, th, m.scene.Plots[i].Place(gtx))
+		addPointTip(&m.tips, p)
+	}
+}
+
+// pointTipRadius is how near, in pixels, the pointer has to be to
+// a plot point to show its tooltip.
+const pointTipRadius = 6
+
+// pointKey identifies a plot point to the tooltips.
+type pointKey struct {
+	plot          *plot.Plot
+	series, index int
+}
+
+// addPointTip gives the point of p nearest the pointer, if it is
+// near enough, a tooltip with its coordinates.
+func addPointTip(tips *tooltip.Tips, p *plot.Plot) {
+	pos, ok := tips.Pointer()
+	if !ok {
+		return
+	}
+	s, i, at, ok := p.Nearest(pos, pointTipRadius)
+	if !ok {
+		return
+	}
+	r := image.Rectangle{Min: at, Max: at}.Inset(-pointTipRadius)
+	tips.Add(pointKey{plot: p, series: s, index: i}, r, p.PointText(s, i))
+}
+
+// badgeStyle is the look of the error badges. Its sizes are in dp.
+var badgeStyle = box.Box{
+	Size:         image.Point{X: 360, Y: 70},
+	Fill:         color.RGBA{200, 30, 30, 230},
+	CornerRadius: 6,
+	Padding:      6,
+	TextColor:    color.RGBA{255, 255, 255, 255},
+	Wrap:         true,
+	MaxLines:     3,
+	Ellipsis:     true,
+}
+
+// drawBadges puts an error tile in place of every image that
+// failed to load, an error badge over every image that failed to
+// reload, and a badge in the top left corner if the scene file
+// failed to reload. Draw them last, so they are on top.
+func drawBadges(gtx *layout.Context, th *material.Theme, m *myDrawState) {
+	b := dpBox(gtx, badgeStyle)
+	b.TextSize = th.TextSize.Scale(.75)
+	for _, si := range m.images {
+		switch {
+		case si.err == nil:
+		case si.img == nil:
+			si.layoutError(gtx, th)
+		default:
+			b.Layout(gtx, th, si.place.View.Min, si.err.Error())
+		}
+	}
+	if m.sceneErr != nil {
+		b.Layout(gtx, th, image.Point{}, m.sceneErr.Error())
+	}
 }
 
 func LoadImage(filename string) (image.Image, string, error) {
@@ -132,7 +423,45 @@ func LoadImage(filename string) (image.Image, string, error) {
 		return nil, "", err
 	}
 	defer f.Close()
-	return image.Decode(bufio.NewReader(f))
+	// anim.Decode decodes all the frames of animated GIF and PNG
+	// files, and anything else as image.Decode does.
+	return anim.Decode(bufio.NewReader(f))
+}
+
+// cropImage returns the r part of img with its origin at (0,0),
+// which is what paint.NewImageOp expects.
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	if r == img.Bounds() && r.Min == (image.Point{}) {
+		return img
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: r.Size()})
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+// dpBox returns b, whose sizes are in dp, with them in pixels, as
+// box.Box draws them. The overlays give their boxes in dp, as their
+// text is in sp, so that labels fit them at any density.
+func dpBox(c unit.Converter, b box.Box) box.Box {
+	b.Size = dpPoint(c, b.Size)
+	b.StrokeWidth = dpPx(c, b.StrokeWidth)
+	b.CornerRadius = dpPx(c, b.CornerRadius)
+	b.Padding = dpPx(c, b.Padding)
+	return b
+}
+
+// dpPoint converts p from dp to pixels.
+func dpPoint(c unit.Converter, p image.Point) image.Point {
+	return image.Point{X: dpPx(c, p.X), Y: dpPx(c, p.Y)}
+}
+
+// dpPx converts v from dp to pixels.
+func dpPx(c unit.Converter, v int) int {
+	return c.Px(unit.Dp(float32(v)))
+}
+
+func toPointF(p image.Point) f32.Point {
+	return f32.Point{X: float32(p.X), Y: float32(p.Y)}
 }
 
 func toRectF(r image.Rectangle) f32.Rectangle {
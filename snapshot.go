@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// Saving frames as PNGs. Nothing is read back from the screen: the
+// saver keeps the ops last submitted with e.Frame, the frame on
+// screen, and when a save is asked for, hands them over to a
+// goroutine that draws them again with the raster package, at the
+// window's own pixel size.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/gesture"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/raster"
+	"github.com/glycerine/hello_gio.go/vlog"
+)
+
+// noteDuration is how long the saved file is announced.
+const noteDuration = 3 * time.Second
+
+var snapshotLog = vlog.New("snapshot")
+
+// saveResult is the outcome of saving one frame.
+type saveResult struct {
+	path string
+	err  error
+}
+
+// frameSaver saves frames as timestamped PNGs in dir, when Ctrl+S
+// (Cmd+S on a Mac) is pressed or "Save frame as PNG" is chosen from
+// the menu a right-click opens. Ctrl+Shift+S saves the ops of the
+// frame instead, as an op list file that render can load.
+type frameSaver struct {
+	dir string
+	// focus makes the saver take the key focus. Leave it unset when
+	// another handler has the focus and passes keys on to Key.
+	focus bool
+	// keys, if set, is given the keys other than the hotkey.
+	keys func(key.Event) bool
+	// changed is called, on a saving goroutine, after a frame has
+	// been saved. Set it to w.Invalidate.
+	changed func()
+	// now returns the time that names the files.
+	now func() time.Time
+
+	// shown holds the ops last submitted, for a window of
+	// shownSize, or nil if there are none, or they are being
+	// saved.
+	shown     *op.Ops
+	shownSize image.Point
+
+	menuOpen bool
+	menuPos  image.Point
+	menuItem gesture.Click
+
+	// note announces the last save until noteUntil.
+	note      string
+	noteErr   bool
+	noteUntil time.Time
+
+	mu      sync.Mutex
+	results []saveResult
+	// pending counts the saves in flight.
+	pending sync.WaitGroup
+}
+
+// newFrameSaver saves frames into dir.
+func newFrameSaver(dir string, changed func()) *frameSaver {
+	return &frameSaver{dir: dir, focus: true, changed: changed, now: time.Now}
+}
+
+// The saver styles give their sizes in dp, for dpBox.
+var (
+	saveMenuStyle = box.Box{
+		Size:         image.Point{X: 200, Y: 30},
+		Fill:         color.RGBA{255, 255, 255, 255},
+		StrokeWidth:  1,
+		Stroke:       color.RGBA{150, 150, 150, 255},
+		CornerRadius: 4,
+		Padding:      6,
+		Ellipsis:     true,
+	}
+	saveNoteStyle = box.Box{
+		Size:         image.Point{X: 560, Y: 30},
+		Fill:         color.RGBA{0, 0, 0, 180},
+		CornerRadius: 4,
+		Padding:      6,
+		TextColor:    color.RGBA{255, 255, 255, 255},
+		Ellipsis:     true,
+	}
+)
+
+// Key handles the save hotkey. It reports whether e was the hotkey.
+func (f *frameSaver) Key(e key.Event) bool {
+	if e.Name != "S" || !(e.Modifiers.Contain(key.ModCtrl) || e.Modifiers.Contain(key.ModCommand)) {
+		if f.keys != nil {
+			f.keys(e)
+		}
+		return false
+	}
+	f.request(e.Modifiers.Contain(key.ModShift))
+	return true
+}
+
+// request saves the frame on screen, as ops or as a PNG, in the
+// background.
+func (f *frameSaver) request(asOps bool) {
+	f.menuOpen = false
+	f.note = ""
+	if f.shown == nil {
+		// Nothing was submitted since the last save.
+		return
+	}
+	ext := ".png"
+	if asOps {
+		ext = opFileExt
+	}
+	path := outputPath(f.dir, "", f.now(), ext)
+	// The saving goroutine has the ops to itself.
+	ops := f.shown
+	f.shown = nil
+	f.pending.Add(1)
+	go f.save(path, ops, f.shownSize)
+}
+
+// Layout handles the hotkey and the menu, and draws the menu and
+// the note about the last save over a window of the given size.
+// Lay it out last, so that it is on top.
+func (f *frameSaver) Layout(gtx *layout.Context, th *material.Theme, window image.Point) {
+	now := gtx.Now()
+	f.applyResults(now)
+	for _, evt := range gtx.Events(f) {
+		switch e := evt.(type) {
+		case key.Event:
+			f.Key(e)
+		case pointer.Event:
+			if e.Type != pointer.Press {
+				break
+			}
+			if e.Buttons.Contain(pointer.ButtonRight) {
+				f.menuOpen = true
+				f.menuPos = image.Point{X: int(e.Position.X), Y: int(e.Position.Y)}
+			} else if f.menuOpen && !inRect(e.Position, f.menuRect(gtx)) {
+				f.menuOpen = false
+			}
+		}
+	}
+	for _, e := range f.menuItem.Events(gtx) {
+		if e.Type == gesture.TypeClick && f.menuOpen {
+			f.request(false)
+		}
+	}
+	if f.focus {
+		key.InputOp{Key: f, Focus: true}.Add(gtx.Ops)
+	}
+	// Watch the whole window for right-clicks, letting every
+	// press through to the handlers below.
+	var stack op.StackOp
+	stack.Push(gtx.Ops)
+	pointer.PassOp{Pass: true}.Add(gtx.Ops)
+	pointer.Rect(image.Rectangle{Max: window}).Add(gtx.Ops)
+	pointer.InputOp{Key: f}.Add(gtx.Ops)
+	stack.Pop()
+
+	if f.note != "" {
+		if now.Before(f.noteUntil) {
+			b := dpBox(gtx, saveNoteStyle)
+			b.TextSize = th.TextSize.Scale(.85)
+			if f.noteErr {
+				b.Fill = badgeStyle.Fill
+			}
+			margin := dpPx(gtx, 10)
+			b.Layout(gtx, th, image.Point{X: margin, Y: window.Y - b.Size.Y - margin}, f.note)
+			op.InvalidateOp{At: f.noteUntil}.Add(gtx.Ops)
+		} else {
+			f.note = ""
+		}
+	}
+	if f.menuOpen {
+		b := dpBox(gtx, saveMenuStyle)
+		r := b.Layout(gtx, th, f.menuPos, "Save frame as PNG")
+		addClick(gtx.Ops, r, &f.menuItem)
+	}
+}
+
+// menuRect returns the bounds of the open menu.
+func (f *frameSaver) menuRect(c unit.Converter) image.Rectangle {
+	return image.Rectangle{Min: f.menuPos, Max: f.menuPos.Add(dpPoint(c, saveMenuStyle.Size))}
+}
+
+// Submitted is called after gtx.Ops have been submitted with
+// e.Frame, for a window of the given size. It keeps them, the frame
+// now on screen, for a save, and gives gtx the ops of the frame
+// before to lay out the next frame into.
+func (f *frameSaver) Submitted(gtx *layout.Context, size image.Point) {
+	f.shown, gtx.Ops = gtx.Ops, f.shown
+	f.shownSize = size
+}
+
+// save draws ops and writes them to path, or writes the ops
+// themselves if path is an op list file.
+func (f *frameSaver) save(path string, ops *op.Ops, size image.Point) {
+	defer f.pending.Done()
+	var err error
+	if isOpFile(path) {
+		err = writeOpFile(path, ops, size)
+	} else {
+		err = writePNG(path, raster.Render(ops, size))
+	}
+	if err != nil {
+		snapshotLog.Warn("save failed", "path", path, "err", err)
+	} else {
+		snapshotLog.Info("saved frame", "path", path)
+	}
+	f.mu.Lock()
+	f.results = append(f.results, saveResult{path: path, err: err})
+	f.mu.Unlock()
+	if f.changed != nil {
+		f.changed()
+	}
+}
+
+// applyResults turns the finished saves into the note, shown from
+// now on.
+func (f *frameSaver) applyResults(now time.Time) {
+	f.mu.Lock()
+	results := f.results
+	f.results = nil
+	f.mu.Unlock()
+	for _, r := range results {
+		f.noteUntil = now.Add(noteDuration)
+		if r.err != nil {
+			f.note, f.noteErr = fmt.Sprintf("saving the frame failed: %v", r.err), true
+			continue
+		}
+		f.note, f.noteErr = "saved "+r.path, false
+	}
+}
+
+// inRect reports whether p lies in r.
+func inRect(p f32.Point, r image.Rectangle) bool {
+	return p.X >= float32(r.Min.X) && p.X < float32(r.Max.X) &&
+		p.Y >= float32(r.Min.Y) && p.Y < float32(r.Max.Y)
+}
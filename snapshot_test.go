@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+)
+
+// testSaver returns a frame saver for the demo scene that saves
+// into dir, and a function that lays out and "submits" a frame
+// after queueing events for the given key.
+func testSaver(t *testing.T, q scriptQueue, dir string) (*frameSaver, func(k event.Key, evs ...event.Event)) {
+	m := loadedDrawState(q, testScene(t))
+	f := newFrameSaver(dir, nil)
+	f.now = func() time.Time { return time.Date(2019, 10, 1, 12, 30, 5, 250e6, time.UTC) }
+	e := testFrame()
+	th := testTheme()
+	frame := func(k event.Key, evs ...event.Event) {
+		q[k] = append(q[k], evs...)
+		drawFrame(m, th, e)
+		f.Layout(m.gtx, th, e.Size)
+		f.Submitted(m.gtx, e.Size)
+		f.pending.Wait()
+	}
+	return f, frame
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "shots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// loadRGBA reads the PNG at path.
+func loadRGBA(t *testing.T, path string) *image.RGBA {
+	t.Helper()
+	img, _, err := LoadImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Rect, img, image.Point{}, draw.Src)
+	return rgba
+}
+
+func TestSaveFrameHotkey(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	q := make(scriptQueue)
+	f, frame := testSaver(t, q, dir)
+	frame(f)
+	frame(f, key.Event{Name: "S"})
+	if f.note != "" {
+		t.Fatalf("S without Ctrl saved the frame: %s", f.note)
+	}
+	frame(f, key.Event{Name: "S", Modifiers: key.ModCtrl})
+	path := filepath.Join(f.dir, "hello_gio-20191001-123005.250.png")
+	checkGolden(t, "demo_yellow", loadRGBA(t, path))
+
+	frame(f)
+	if want := "saved " + path; f.note != want {
+		t.Errorf("note is %q, want %q", f.note, want)
+	}
+}
+
+// TestSaveFrameOps saves the ops of a frame, and checks that render
+// draws them as the window did.
+func TestSaveFrameOps(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	q := make(scriptQueue)
+	f, frame := testSaver(t, q, dir)
+	frame(f)
+	frame(f, key.Event{Name: "S", Modifiers: key.ModCtrl | key.ModShift})
+	frame(f)
+	path := filepath.Join(f.dir, "hello_gio-20191001-123005.250.ops")
+	if want := "saved " + path; f.note != want {
+		t.Fatalf("note is %q, want %q", f.note, want)
+	}
+	out := filepath.Join(dir, "replay.png")
+	o, err := parseArgs([]string{"render", "-o", out, path}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := render(o); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "demo_yellow", loadRGBA(t, out))
+}
+
+func TestSaveFrameMenu(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	q := make(scriptQueue)
+	f, frame := testSaver(t, q, dir)
+	at := f32.Point{X: 700, Y: 500}
+	frame(f, pointer.Event{Type: pointer.Press, Buttons: pointer.ButtonRight, Position: at})
+	if !f.menuOpen {
+		t.Fatal("right-click did not open the menu")
+	}
+	// A left click elsewhere closes it.
+	frame(f, pointer.Event{Type: pointer.Press, Buttons: pointer.ButtonLeft, Position: at.Sub(f32.Point{X: 10})})
+	if f.menuOpen {
+		t.Fatal("clicking outside the menu did not close it")
+	}
+	frame(f, pointer.Event{Type: pointer.Press, Buttons: pointer.ButtonRight, Position: at})
+	item := at.Add(f32.Point{X: 5, Y: 5})
+	frame(&f.menuItem,
+		pointer.Event{Type: pointer.Press, Hit: true, Buttons: pointer.ButtonLeft, Position: item},
+		pointer.Event{Type: pointer.Release, Position: item})
+	if f.menuOpen {
+		t.Error("choosing the menu item did not close the menu")
+	}
+	// The saved frame is the one on screen when the item was
+	// chosen: the demo, with the menu open over it.
+	got := loadRGBA(t, filepath.Join(f.dir, "hello_gio-20191001-123005.250.png"))
+	if c := got.RGBAAt(int(item.X)+20, int(item.Y)+2); c != saveMenuStyle.Fill {
+		t.Errorf("the menu is %v in the saved frame, want %v", c, saveMenuStyle.Fill)
+	}
+	menu := image.Rectangle{Min: image.Pt(int(at.X), int(at.Y)), Max: image.Pt(int(at.X), int(at.Y)).Add(saveMenuStyle.Size)}
+	draw.Draw(got, menu, loadRGBA(t, filepath.Join("testdata", "golden", "demo_yellow.png")), menu.Min, draw.Src)
+	checkGolden(t, "demo_yellow", got)
+	frame(f)
+	if !strings.HasPrefix(f.note, "saved ") {
+		t.Errorf("note is %q, want the saved file", f.note)
+	}
+}
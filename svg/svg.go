@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package svg writes a Gio op.Ops frame as an SVG document, for
+// figures that stay sharp at any size.
+//
+// The op list is walked like the raster package walks it, and every
+// op has an SVG counterpart: StackOp becomes a <g> group,
+// TransformOp a translated <g>, clip.Op a <g> clipped by a
+// <clipPath> holding its rectangle or its even-odd clip.Path, and
+// PaintOp a <rect> filled with the current ColorOp, or an <image>
+// of the current ImageOp, embedded as a base64 PNG. Text is laid out
+// by Gio as clip paths, so it comes out as glyph outlines and looks
+// the same without the fonts.
+package svg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+
+	"github.com/glycerine/hello_gio.go/internal/opconst"
+	"github.com/glycerine/hello_gio.go/internal/ops"
+)
+
+// encoder holds the state of one Encode call.
+type encoder struct {
+	w      *bufio.Writer
+	reader ops.Reader
+	// indent is the depth of the element being written.
+	indent int
+	// tags are the names of the open elements.
+	tags []string
+	// clips counts the clip paths, to number their ids.
+	clips int
+	// images maps every embedded image to its id.
+	images map[*image.RGBA]string
+	err    error
+}
+
+// Encode writes the operations in root, drawn in a window of the
+// given size, to w as an SVG document. Like the GPU renderer, it
+// starts from a white background.
+func Encode(w io.Writer, root *op.Ops, size image.Point) error {
+	e := &encoder{
+		w:      bufio.NewWriter(w),
+		images: make(map[*image.RGBA]string),
+	}
+	e.printf(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" `+
+		`width="%d" height="%d" viewBox="0 0 %d %d">`, size.X, size.Y, size.X, size.Y)
+	e.indent++
+	e.printf(`<rect width="%d" height="%d" fill="#ffffff"/>`, size.X, size.Y)
+	e.reader.Reset(root)
+	e.collect(ops.State{Color: color.RGBA{A: 0xff}})
+	e.indent--
+	e.printf("</svg>")
+	if err := e.w.Flush(); e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+// collect writes the ops up to the Pop that ends the current
+// stack level.
+func (e *encoder) collect(st ops.State) {
+	// groups counts the <g> elements opened at this level, which
+	// the Pop closes.
+	groups := 0
+	var path []ops.Quad
+loop:
+	for encOp, ok := e.reader.Decode(); ok; encOp, ok = e.reader.Decode() {
+		switch opconst.OpType(encOp.Data[0]) {
+		case opconst.TypeTransform:
+			off := ops.DecodeTransformOp(encOp.Data).Transform(f32.Point{})
+			e.open(`<g transform="translate(%s %s)">`, ops.Num(off.X), ops.Num(off.Y))
+			groups++
+		case opconst.TypeAux:
+			path = ops.DecodePath(encOp.Data)
+		case opconst.TypeClip:
+			e.clip(ops.DecodeClipOp(encOp.Data), path)
+			groups++
+			path = nil
+		case opconst.TypeColor:
+			st.Color = ops.DecodeColorOp(encOp.Data)
+			st.Img = nil
+		case opconst.TypeImage:
+			st.Img = ops.DecodeImageOp(encOp.Data, encOp.Refs).Src
+		case opconst.TypePaint:
+			e.paint(st, ops.DecodePaintOp(encOp.Data).Rect)
+		case opconst.TypePush:
+			e.open("<g>")
+			e.collect(st)
+			e.close()
+		case opconst.TypePop:
+			break loop
+		}
+	}
+	for ; groups > 0; groups-- {
+		e.close()
+	}
+}
+
+// clip defines a clip path for bounds, or for path if there is
+// one, and opens a group clipped by it.
+func (e *encoder) clip(bounds f32.Rectangle, path []ops.Quad) {
+	e.clips++
+	id := "c" + strconv.Itoa(e.clips)
+	e.open(`<clipPath id="%s">`, id)
+	if len(path) > 0 {
+		e.printf(`<path clip-rule="evenodd" d="%s"/>`, pathData(path))
+	} else {
+		e.printf(`<rect %s/>`, rectAttrs(bounds))
+	}
+	e.close()
+	e.open(`<g clip-path="url(#%s)">`, id)
+}
+
+// paint fills r with the material of st.
+func (e *encoder) paint(st ops.State, r f32.Rectangle) {
+	if r.Empty() {
+		return
+	}
+	if st.Img == nil {
+		c := st.Color
+		if c.A == 0 {
+			return
+		}
+		// SVG colors are not premultiplied.
+		fill := fmt.Sprintf("#%02x%02x%02x", ops.Unmul(c.R, c.A), ops.Unmul(c.G, c.A), ops.Unmul(c.B, c.A))
+		if c.A == 0xff {
+			e.printf(`<rect %s fill="%s"/>`, rectAttrs(r), fill)
+		} else {
+			e.printf(`<rect %s fill="%s" fill-opacity="%s"/>`, rectAttrs(r), fill, ops.Num(float32(c.A)/255))
+		}
+		return
+	}
+	id, err := e.image(st.Img)
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	sz := st.Img.Bounds().Size()
+	if sz.X == 0 || sz.Y == 0 {
+		return
+	}
+	e.printf(`<use xlink:href="#%s" transform="translate(%s %s) scale(%s %s)"/>`, id,
+		ops.Num(r.Min.X), ops.Num(r.Min.Y), ops.Num(r.Dx()/float32(sz.X)), ops.Num(r.Dy()/float32(sz.Y)))
+}
+
+// image returns the id of img, embedding it the first time it is
+// used.
+func (e *encoder) image(img *image.RGBA) (string, error) {
+	if id, ok := e.images[img]; ok {
+		return id, nil
+	}
+	id := "i" + strconv.Itoa(len(e.images)+1)
+	e.images[img] = id
+	var buf bytes.Buffer
+	// PNG wants the image to start at the origin.
+	src := &image.RGBA{Pix: img.Pix, Stride: img.Stride, Rect: image.Rectangle{Max: img.Rect.Size()}}
+	if err := png.Encode(&buf, src); err != nil {
+		return "", err
+	}
+	sz := img.Bounds().Size()
+	e.open("<defs>")
+	e.printf(`<image id="%s" width="%d" height="%d" preserveAspectRatio="none" xlink:href="data:image/png;base64,%s"/>`,
+		id, sz.X, sz.Y, base64.StdEncoding.EncodeToString(buf.Bytes()))
+	e.close()
+	return id, nil
+}
+
+// pathData returns the SVG path data of path. Each run of joined
+// segments is a subpath, which SVG closes when it fills it.
+func pathData(path []ops.Quad) string {
+	var b bytes.Buffer
+	var pen f32.Point
+	for i, q := range path {
+		if i == 0 || q.From != pen {
+			if i > 0 {
+				b.WriteString("Z")
+			}
+			fmt.Fprintf(&b, "M%s %s", ops.Num(q.From.X), ops.Num(q.From.Y))
+		}
+		if ops.IsLine(q) {
+			fmt.Fprintf(&b, "L%s %s", ops.Num(q.To.X), ops.Num(q.To.Y))
+		} else {
+			fmt.Fprintf(&b, "Q%s %s %s %s", ops.Num(q.Ctrl.X), ops.Num(q.Ctrl.Y), ops.Num(q.To.X), ops.Num(q.To.Y))
+		}
+		pen = q.To
+	}
+	if len(path) > 0 {
+		b.WriteString("Z")
+	}
+	return b.String()
+}
+
+func rectAttrs(r f32.Rectangle) string {
+	return fmt.Sprintf(`x="%s" y="%s" width="%s" height="%s"`, ops.Num(r.Min.X), ops.Num(r.Min.Y), ops.Num(r.Dx()), ops.Num(r.Dy()))
+}
+
+// open writes an element start tag and indents what follows.
+func (e *encoder) open(format string, a ...interface{}) {
+	e.printf(format, a...)
+	e.indent++
+	name := format[1:]
+	if i := strings.IndexAny(name, " >"); i >= 0 {
+		name = name[:i]
+	}
+	e.tags = append(e.tags, name)
+}
+
+// close ends the element open started last.
+func (e *encoder) close() {
+	e.indent--
+	name := e.tags[len(e.tags)-1]
+	e.tags = e.tags[:len(e.tags)-1]
+	e.printf("</%s>", name)
+}
+
+func (e *encoder) printf(format string, a ...interface{}) {
+	if e.err != nil {
+		return
+	}
+	for i := 0; i < e.indent; i++ {
+		e.w.WriteByte('\t')
+	}
+	fmt.Fprintf(e.w, format, a...)
+	e.err = e.w.WriteByte('\n')
+}
+
+func (e *encoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
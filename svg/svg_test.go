@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+func TestEncode(t *testing.T) {
+	ops := new(op.Ops)
+	paint.ColorOp{Color: color.RGBA{R: 0xff, A: 0xff}}.Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 10, Y: 20}}}.Add(ops)
+
+	var stack op.StackOp
+	stack.Push(ops)
+	op.TransformOp{}.Offset(f32.Point{X: 5, Y: 2.5}).Add(ops)
+	clip.Rect{Rect: f32.Rectangle{Max: f32.Point{X: 4, Y: 4}}}.Op(ops).Add(ops)
+	// Half transparent blue, premultiplied.
+	paint.ColorOp{Color: color.RGBA{B: 0x80, A: 0x80}}.Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 8, Y: 8}}}.Add(ops)
+	stack.Pop()
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	paint.NewImageOp(img).Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Min: f32.Point{X: 10}, Max: f32.Point{X: 14, Y: 3}}}.Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Min: f32.Point{X: 20}, Max: f32.Point{X: 22, Y: 1}}}.Add(ops)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, ops, image.Point{X: 30, Y: 20}); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	// Cut the embedded PNG short.
+	if i := strings.Index(got, "base64,"); i >= 0 {
+		j := strings.Index(got[i:], `"`)
+		got = got[:i+len("base64,")] + "…" + got[i+j:]
+	}
+	want := `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="30" height="20" viewBox="0 0 30 20">
+	<rect width="30" height="20" fill="#ffffff"/>
+	<rect x="0" y="0" width="10" height="20" fill="#ff0000"/>
+	<g>
+		<g transform="translate(5 2.5)">
+			<clipPath id="c1">
+				<rect x="0" y="0" width="4" height="4"/>
+			</clipPath>
+			<g clip-path="url(#c1)">
+				<rect x="0" y="0" width="8" height="8" fill="#0000ff" fill-opacity="0.5"/>
+			</g>
+		</g>
+	</g>
+	<defs>
+		<image id="i1" width="2" height="1" preserveAspectRatio="none" xlink:href="data:image/png;base64,…"/>
+	</defs>
+	<use xlink:href="#i1" transform="translate(10 0) scale(2 3)"/>
+	<use xlink:href="#i1" transform="translate(20 0) scale(1 1)"/>
+</svg>
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEncodePath(t *testing.T) {
+	ops := new(op.Ops)
+	var p clip.Path
+	p.Begin(ops)
+	p.Move(f32.Point{X: 1, Y: 1})
+	p.Line(f32.Point{X: 4})
+	p.Quad(f32.Point{X: 2, Y: 2}, f32.Point{Y: 4})
+	p.Line(f32.Point{X: -4})
+	p.Move(f32.Point{X: 1, Y: -3})
+	p.Line(f32.Point{X: 1, Y: 1})
+	p.Line(f32.Point{X: -1})
+	p.End().Add(ops)
+	paint.PaintOp{Rect: f32.Rectangle{Max: f32.Point{X: 10, Y: 10}}}.Add(ops)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, ops, image.Point{X: 10, Y: 10}); err != nil {
+		t.Fatal(err)
+	}
+	// Gio splits the quad where it turns back in x.
+	const want = `<path clip-rule="evenodd" d="M1 1L5 1Q6 2 6 3Q6 4 5 5L1 5ZM2 2L3 3L2 3Z"/>`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("no %s in\n%s", want, buf.String())
+	}
+	checkXML(t, buf.Bytes())
+}
+
+// checkXML fails t unless doc is well-formed XML.
+func checkXML(t *testing.T, doc []byte) {
+	t.Helper()
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
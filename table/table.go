@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package table reads columns of numbers and times from CSV and TSV
+// files, such as the ones our R scripts write, for plotting.
+//
+// The first row is taken as a header if any of its fields is not a
+// value; otherwise the columns are named V1, V2, ... as in R. Fields
+// may be quoted. Every column holds either numbers or RFC 3339
+// times, decided by its first value; empty fields, NA, NaN, N/A and
+// null are missing values, stored as NaN.
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of the values in a column.
+type Kind uint8
+
+const (
+	// Number columns hold float64 values.
+	Number Kind = iota
+	// Time columns hold RFC 3339 times, stored as seconds since
+	// the Unix epoch.
+	Time
+)
+
+func (k Kind) String() string {
+	if k == Time {
+		return "time"
+	}
+	return "number"
+}
+
+// Column is one column of a table.
+type Column struct {
+	Name string
+	Kind Kind
+	// Values holds one value per row, NaN where it is missing.
+	Values []float64
+}
+
+// Table is the decoded content of a CSV or TSV file.
+type Table struct {
+	Columns []*Column
+	// Rows is the number of data rows, not counting the header.
+	Rows int
+	// Header reports whether the first row held the column names.
+	Header bool
+}
+
+// Error is a problem at a field of a table file. Row and Col are
+// 1-based and count the header row, if any, so they match what a
+// spreadsheet shows.
+type Error struct {
+	Filename string
+	Row, Col int
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: row %d, column %d: %s", e.Filename, e.Row, e.Col, e.Msg)
+}
+
+// Load reads the table at path. Fields are separated by tabs in
+// .tsv and .tab files, by commas in .csv files, and otherwise by
+// tabs if the first line has any.
+func Load(path string) (*Table, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data, Separator(path, data))
+}
+
+// Separator guesses the field separator of a file from its name and
+// its first line.
+func Separator(path string, data []byte) rune {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tsv", ".tab":
+		return '\t'
+	case ".csv":
+		return ','
+	}
+	line := data
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if bytes.IndexByte(line, '\t') >= 0 {
+		return '\t'
+	}
+	return ','
+}
+
+// Parse decodes a table with fields separated by sep. The filename
+// is only used in error messages.
+func Parse(filename string, data []byte, sep rune) (*Table, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = sep
+	r.TrimLeadingSpace = sep != '\t'
+	r.ReuseRecord = true
+	var t *Table
+	// known[i] is set once the kind of column i is known.
+	var known []bool
+	for row := 1; ; row++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, readError(filename, row, err)
+		}
+		if t == nil {
+			t = &Table{Header: isHeader(rec)}
+			for i, f := range rec {
+				name := fmt.Sprintf("V%d", i+1)
+				if t.Header && strings.TrimSpace(f) != "" {
+					name = strings.TrimSpace(f)
+				}
+				t.Columns = append(t.Columns, &Column{Name: name})
+			}
+			known = make([]bool, len(rec))
+			if t.Header {
+				continue
+			}
+		}
+		for i, f := range rec {
+			c := t.Columns[i]
+			v, kind, err := parseValue(f)
+			if err != nil {
+				return nil, &Error{Filename: filename, Row: row, Col: i + 1,
+					Msg: fmt.Sprintf("column %q: %q is not a number or an RFC 3339 time", c.Name, f)}
+			}
+			if !math.IsNaN(v) {
+				if !known[i] {
+					c.Kind, known[i] = kind, true
+				} else if kind != c.Kind {
+					return nil, &Error{Filename: filename, Row: row, Col: i + 1,
+						Msg: fmt.Sprintf("column %q: %q is not a %s", c.Name, f, c.Kind)}
+				}
+			}
+			c.Values = append(c.Values, v)
+		}
+		t.Rows++
+	}
+	if t == nil {
+		return nil, &Error{Filename: filename, Row: 1, Col: 1, Msg: "empty table"}
+	}
+	return t, nil
+}
+
+// readError converts the errors of encoding/csv, which count lines
+// rather than rows, into an Error.
+func readError(filename string, row int, err error) error {
+	var pe *csv.ParseError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	msg := pe.Err.Error()
+	if pe.Err == csv.ErrFieldCount {
+		msg = "wrong number of fields"
+	}
+	return &Error{Filename: filename, Row: row, Col: pe.Column, Msg: msg}
+}
+
+// isHeader reports whether rec looks like a row of column names: a
+// row with a field that is not a value.
+func isHeader(rec []string) bool {
+	for _, f := range rec {
+		if _, _, err := parseValue(f); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseValue parses a field as a missing value, a number or a time.
+func parseValue(f string) (float64, Kind, error) {
+	f = strings.TrimSpace(f)
+	if isNA(f) {
+		return math.NaN(), Number, nil
+	}
+	if v, err := strconv.ParseFloat(f, 64); err == nil {
+		return v, Number, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, f); err == nil {
+		return float64(t.UnixNano()) / 1e9, Time, nil
+	}
+	return 0, 0, errors.New("not a value")
+}
+
+func isNA(f string) bool {
+	switch strings.ToLower(f) {
+	case "", "na", "nan", "n/a", "null":
+		return true
+	}
+	return false
+}
+
+// Column returns the column called name, or, if name is a number,
+// the column at that 1-based position.
+func (t *Table) Column(name string) (*Column, error) {
+	i, err := t.ColumnIndex(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Columns[i], nil
+}
+
+// ColumnIndex returns the index in Columns of the column that
+// Column returns.
+func (t *Table) ColumnIndex(name string) (int, error) {
+	for i, c := range t.Columns {
+		if c.Name == name {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(name); err == nil && i >= 1 && i <= len(t.Columns) {
+		return i - 1, nil
+	}
+	return 0, fmt.Errorf("no column %q; have %s", name, strings.Join(t.Names(), ", "))
+}
+
+// Names returns the column names.
+func (t *Table) Names() []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package table
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	src := "time,\"temp, C\",note\n" +
+		"2019-10-01T00:00:00Z,1.5,NA\n" +
+		"2019-10-01T01:00:00+02:00,NaN,\n" +
+		"2019-10-01T02:00:00Z, -3 ,7\n"
+	tb, err := Parse("t.csv", []byte(src), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tb.Header || tb.Rows != 3 {
+		t.Fatalf("got header %v, %d rows; want a header and 3 rows", tb.Header, tb.Rows)
+	}
+	if got, want := tb.Names(), []string{"time", "temp, C", "note"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got names %q, want %q", got, want)
+	}
+	tc := tb.Columns[0]
+	t0 := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	if tc.Kind != Time || tc.Values[0] != float64(t0.Unix()) || tc.Values[1] != float64(t0.Add(-time.Hour).Unix()) {
+		t.Errorf("time column: got %v %v", tc.Kind, tc.Values)
+	}
+	c := tb.Columns[1]
+	if c.Kind != Number || c.Values[0] != 1.5 || !math.IsNaN(c.Values[1]) || c.Values[2] != -3 {
+		t.Errorf("temp column: got %v %v", c.Kind, c.Values)
+	}
+	if n := tb.Columns[2].Values; !math.IsNaN(n[0]) || !math.IsNaN(n[1]) || n[2] != 7 {
+		t.Errorf("note column: got %v", n)
+	}
+}
+
+func TestParseNoHeader(t *testing.T) {
+	tb, err := Parse("t.tsv", []byte("1\t2\n3\t4\n"), '\t')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tb.Header || tb.Rows != 2 {
+		t.Errorf("got header %v, %d rows; want no header and 2 rows", tb.Header, tb.Rows)
+	}
+	c, err := tb.Column("V2")
+	if err != nil || !reflect.DeepEqual(c.Values, []float64{2, 4}) {
+		t.Errorf("column V2: got %v, %v", c, err)
+	}
+	if c2, _ := tb.Column("2"); c2 != c {
+		t.Error("column 2 is not V2")
+	}
+	if i, err := tb.ColumnIndex("V2"); i != 1 || err != nil {
+		t.Errorf("column V2 at %d, %v; want 1", i, err)
+	}
+	if _, err := tb.Column("x"); err == nil || err.Error() != `no column "x"; have V1, V2` {
+		t.Errorf("got error %v for a missing column", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, want string
+	}{
+		{"not a value", "a,b\n1,2\n3,x\n", `t.csv: row 3, column 2: column "b": "x" is not a number or an RFC 3339 time`},
+		{"kind", "a,b\n1,2019-10-01T00:00:00Z\n2,3\n", `t.csv: row 3, column 2: column "b": "3" is not a time`},
+		{"field count", "a,b\n1,2\n3\n", "t.csv: row 3, column 1: wrong number of fields"},
+		// Where encoding/csv places the column varies with the Go
+		// version.
+		{"quote", "a,b\n1,\"2\n", "t.csv: row 2, column "},
+		{"empty", "", "t.csv: row 1, column 1: empty table"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse("t.csv", []byte(tc.src), ',')
+			if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+				t.Errorf("got error %v, want %s", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	for _, tc := range []struct {
+		path, data string
+		want       rune
+	}{
+		{"a.csv", "x\ty", ','},
+		{"a.TSV", "x,y", '\t'},
+		{"a.txt", "x\ty\n1,2", '\t'},
+		{"a.txt", "x,y\n1\t2", ','},
+	} {
+		if got := Separator(tc.path, []byte(tc.data)); got != tc.want {
+			t.Errorf("Separator(%q, %q) = %q, want %q", tc.path, tc.data, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,580 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package tiled draws images too large for one texture, such as
+// 20000x20000 rasters, as a pyramid of fixed-size tiles.
+//
+// paint.NewImageOp copies its whole source into one *image.RGBA,
+// which the GPU then uploads as one texture. An Image instead cuts
+// its source into TileSize tiles at several levels of detail: level
+// 0 is the source itself, and every level above it halves the width
+// and height of the one below, until the whole image fits in one
+// tile. Layout picks the coarsest level that is still at least as
+// sharp as the screen, and draws only the tiles of that level that
+// fall in view, so only those are ever made into ImageOps.
+//
+// Tiles are cut and downsampled on background goroutines, from the
+// bottom up: a coarse tile waits for the four below it, which are
+// made in parallel, and each tile is made once however many frames
+// or coarser tiles ask for it. Until a tile is ready, the part of a coarser tile that covers it is drawn
+// in its place, or the Placeholder color if there is none, and
+// Layout asks for another frame. Ready tiles are kept in an LRU
+// cache that is trimmed to Budget bytes, never dropping a tile that
+// is in view. Coarse tiles are made from the finer ones, each made
+// once, so the whole pyramid costs about one pass over the source.
+package tiled
+
+import (
+	"container/list"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+const (
+	// TileSize is the width and height of a tile, in pixels of
+	// its level.
+	TileSize = 256
+	// DefaultBudget is the cache size, in bytes, when Image.Budget
+	// is zero: 1024 tiles.
+	DefaultBudget = 1024 * TileSize * TileSize * 4
+	// pollInterval is how soon Layout asks for another frame while
+	// tiles in view are being made.
+	pollInterval = 50 * time.Millisecond
+)
+
+// maxWorkers is how many goroutines make tiles at the same time.
+var maxWorkers = runtime.NumCPU()
+
+// Image is a large image drawn from tiles. Like paint.ImageOp, an
+// Image is made once and drawn in every frame.
+type Image struct {
+	// Budget is the most memory, in bytes, the cached tiles may
+	// take. Zero means DefaultBudget. Tiles in view are kept even
+	// when they take more.
+	Budget int
+	// Placeholder fills tiles that are not ready and have no
+	// coarser tile to stand in for them. The zero value means
+	// light gray.
+	Placeholder color.RGBA
+
+	src image.Image
+	// rect is the part of src that is drawn.
+	rect image.Rectangle
+	// levels are the sizes of the levels, in their own pixels.
+	levels []image.Point
+
+	mu sync.Mutex
+	// tiles maps the key of every cached tile to its element in
+	// lru, most recently used first.
+	tiles map[tileKey]*list.Element
+	lru   *list.List
+	// used is the size of the cached tiles, in bytes.
+	used int
+	// frame counts the Layout calls; tiles in view are stamped
+	// with it.
+	frame int
+	// jobs holds the tiles being made, or waiting for the tiles
+	// below them.
+	jobs map[tileKey]*job
+	// ready are the jobs that can be made now, first in first out.
+	ready []*job
+	// workers counts the goroutines making tiles.
+	workers int
+	// loading reports whether the last Layout lacked tiles in view.
+	loading bool
+	// pending waits for the workers.
+	pending sync.WaitGroup
+	// made counts the times each tile was made.
+	made map[tileKey]int
+}
+
+// tileKey names tile (x, y) of a level.
+type tileKey struct {
+	level, x, y int
+}
+
+// job is a tile to be made.
+type job struct {
+	key tileKey
+	// frame is the last frame that asked for the tile, itself or
+	// through a tile above it.
+	frame int
+	// kids holds the pixels of the tiles below, as they are made.
+	kids map[tileKey]*image.RGBA
+	// wait counts the tiles below that are not yet made.
+	wait int
+}
+
+// tile is a cached tile.
+type tile struct {
+	key tileKey
+	// img holds the pixels of the tile and a gutter of one pixel
+	// of its neighbours, so that sampling between pixels at the
+	// edges of the tile blends across them like it does inside
+	// one big texture.
+	img *image.RGBA
+	op  paint.ImageOp
+	// frame is the last frame the tile was in view.
+	frame int
+}
+
+// New returns an Image drawing the r part of src. Nothing is copied
+// or decoded until tiles are needed.
+func New(src image.Image, r image.Rectangle) *Image {
+	im := &Image{
+		src:   src,
+		rect:  r,
+		tiles: make(map[tileKey]*list.Element),
+		lru:   list.New(),
+		jobs:  make(map[tileKey]*job),
+		made:  make(map[tileKey]int),
+	}
+	sz := r.Size()
+	for {
+		im.levels = append(im.levels, sz)
+		if sz.X <= TileSize && sz.Y <= TileSize {
+			break
+		}
+		sz = image.Point{X: (sz.X + 1) / 2, Y: (sz.Y + 1) / 2}
+	}
+	return im
+}
+
+// Size returns the size of the image, in source pixels.
+func (im *Image) Size() image.Point {
+	return im.levels[0]
+}
+
+// Levels returns the number of levels of detail.
+func (im *Image) Levels() int {
+	return len(im.levels)
+}
+
+// Layout draws the image stretched over dest, showing only the part
+// inside view. It is what painting a paint.ImageOp of the image
+// into dest, clipped to view, would show.
+func (im *Image) Layout(gtx *layout.Context, dest f32.Rectangle, view image.Rectangle) {
+	sz := im.Size()
+	if dest.Empty() || view.Empty() || sz.X == 0 || sz.Y == 0 {
+		return
+	}
+	// scale is the size on screen of a source pixel.
+	scale := f32.Point{X: dest.Dx() / float32(sz.X), Y: dest.Dy() / float32(sz.Y)}
+	level := im.level(math.Max(float64(scale.X), float64(scale.Y)))
+	// The tiles in view: those under the view, mapped to the
+	// pixels of the level.
+	n := float32(int(1) << uint(level))
+	lsz := im.levels[level]
+	tiles := func(min, max int, dmin, s float32, size int) (int, int) {
+		lmin := math.Floor(float64((float32(min) - dmin) / s / n))
+		lmax := math.Ceil(float64((float32(max) - dmin) / s / n))
+		last := (size - 1) / TileSize
+		return clampInt(int(lmin)/TileSize, 0, last), clampInt((int(lmax)-1)/TileSize, 0, last)
+	}
+	x0, x1 := tiles(view.Min.X, view.Max.X, dest.Min.X, scale.X, lsz.X)
+	y0, y1 := tiles(view.Min.Y, view.Max.Y, dest.Min.Y, scale.Y, lsz.Y)
+
+	ops := gtx.Ops
+	var stack op.StackOp
+	stack.Push(ops)
+	clip.Rect{Rect: toRectF(view)}.Op(ops).Add(ops)
+	im.mu.Lock()
+	im.frame++
+	waiting := false
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			k := tileKey{level: level, x: x, y: y}
+			r := im.screenRect(k.level, im.levelRect(k), dest, scale)
+			t := im.lookup(k)
+			if t == nil {
+				waiting = true
+				im.start(k)
+				// Paint the nearest coarser tile that is ready.
+				for l := k.level + 1; l < len(im.levels) && t == nil; l++ {
+					d := uint(l - k.level)
+					t = im.lookup(tileKey{level: l, x: k.x >> d, y: k.y >> d})
+				}
+			}
+			im.paintTile(ops, t, r, dest, scale)
+		}
+	}
+	im.loading = waiting
+	im.trim()
+	im.mu.Unlock()
+	stack.Pop()
+	if waiting {
+		op.InvalidateOp{At: gtx.Now().Add(pollInterval)}.Add(ops)
+	}
+}
+
+// level returns the coarsest level whose pixels are no bigger than
+// a screen pixel, given the size on screen of a source pixel.
+func (im *Image) level(scale float64) int {
+	if scale <= 0 {
+		return len(im.levels) - 1
+	}
+	l := int(math.Floor(math.Log2(1 / scale)))
+	return clampInt(l, 0, len(im.levels)-1)
+}
+
+// screenRect returns where lr, a rectangle in the pixels of level,
+// goes when the image is stretched over dest.
+func (im *Image) screenRect(level int, lr image.Rectangle, dest f32.Rectangle, scale f32.Point) f32.Rectangle {
+	n := 1 << uint(level)
+	r := image.Rectangle{Min: lr.Min.Mul(n), Max: lr.Max.Mul(n)}
+	r = r.Intersect(image.Rectangle{Max: im.Size()})
+	return f32.Rectangle{
+		Min: f32.Point{X: dest.Min.X + float32(r.Min.X)*scale.X, Y: dest.Min.Y + float32(r.Min.Y)*scale.Y},
+		Max: f32.Point{X: dest.Min.X + float32(r.Max.X)*scale.X, Y: dest.Min.Y + float32(r.Max.Y)*scale.Y},
+	}
+}
+
+// levelRect returns the bounds of tile k in the pixels of its level.
+func (im *Image) levelRect(k tileKey) image.Rectangle {
+	r := image.Rect(k.x*TileSize, k.y*TileSize, (k.x+1)*TileSize, (k.y+1)*TileSize)
+	return r.Intersect(image.Rectangle{Max: im.levels[k.level]})
+}
+
+// paddedRect returns the bounds of tile k and its gutter in the
+// pixels of its level.
+func (im *Image) paddedRect(k tileKey) image.Rectangle {
+	r := im.levelRect(k).Inset(-1)
+	return r.Intersect(image.Rectangle{Max: im.levels[k.level]})
+}
+
+// lookup returns cached tile k, marked as in view, or nil. The
+// caller holds im.mu.
+func (im *Image) lookup(k tileKey) *tile {
+	e, ok := im.tiles[k]
+	if !ok {
+		return nil
+	}
+	im.lru.MoveToFront(e)
+	t := e.Value.(*tile)
+	t.frame = im.frame
+	return t
+}
+
+// paintTile fills r, the place of a tile on screen, with the part
+// of t that covers it, or with the placeholder color if t is nil.
+func (im *Image) paintTile(ops *op.Ops, t *tile, r f32.Rectangle, dest f32.Rectangle, scale f32.Point) {
+	var stack op.StackOp
+	stack.Push(ops)
+	defer stack.Pop()
+	// Clip to whole pixels, so that neighbouring tiles meet
+	// without the anti-aliased edges of a clip path.
+	r = roundRectF(r)
+	clip.Rect{Rect: r}.Op(ops).Add(ops)
+	if t == nil {
+		c := im.Placeholder
+		if c == (color.RGBA{}) {
+			c = color.RGBA{R: 0xd0, G: 0xd0, B: 0xd0, A: 0xff}
+		}
+		paint.ColorOp{Color: c}.Add(ops)
+		paint.PaintOp{Rect: r}.Add(ops)
+		return
+	}
+	t.op.Add(ops)
+	paint.PaintOp{Rect: im.screenRect(t.key.level, im.paddedRect(t.key), dest, scale)}.Add(ops)
+}
+
+// start asks for tile k in the current frame. A tile above level 0
+// first asks for the tiles below it that are not cached, and is
+// made once they all are. A tile that is cached or being made
+// already is not made again, and the jobs of tiles that no frame
+// asks for any more are dropped before they are made. The caller
+// holds im.mu.
+func (im *Image) start(k tileKey) {
+	j := im.jobs[k]
+	if j == nil {
+		if _, ok := im.tiles[k]; ok {
+			return
+		}
+		j = &job{key: k, kids: make(map[tileKey]*image.RGBA)}
+		im.jobs[k] = j
+		for _, ck := range im.below(k) {
+			if e, ok := im.tiles[ck]; ok {
+				j.kids[ck] = e.Value.(*tile).img
+			} else {
+				j.wait++
+			}
+		}
+		if j.wait == 0 {
+			im.ready = append(im.ready, j)
+		}
+	}
+	j.frame = im.frame
+	for _, ck := range im.below(k) {
+		if j.kids[ck] == nil {
+			im.start(ck)
+		}
+	}
+	im.run()
+}
+
+// below returns the tiles of the level below k that it is halved
+// from: four, or fewer at the right and bottom edges.
+func (im *Image) below(k tileKey) []tileKey {
+	if k.level == 0 {
+		return nil
+	}
+	var keys []tileKey
+	sz := im.levels[k.level-1]
+	for y := 2 * k.y; y < 2*k.y+2 && y*TileSize < sz.Y; y++ {
+		for x := 2 * k.x; x < 2*k.x+2 && x*TileSize < sz.X; x++ {
+			keys = append(keys, tileKey{level: k.level - 1, x: x, y: y})
+		}
+	}
+	return keys
+}
+
+// run starts goroutines to make the ready tiles, up to maxWorkers.
+// The caller holds im.mu.
+func (im *Image) run() {
+	for im.workers < maxWorkers && im.workers < len(im.ready) {
+		im.workers++
+		im.pending.Add(1)
+		go im.work()
+	}
+}
+
+// work makes ready tiles until there are none left.
+func (im *Image) work() {
+	defer im.pending.Done()
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	for len(im.ready) > 0 {
+		j := im.ready[0]
+		im.ready = im.ready[1:]
+		if j.frame < im.frame {
+			im.drop(j.key)
+			continue
+		}
+		im.mu.Unlock()
+		img := im.make(j)
+		im.mu.Lock()
+		im.finish(j, img)
+	}
+	im.workers--
+}
+
+// drop forgets the job of tile k, and those of the tiles above it
+// waiting for it, which no frame asks for either. The caller holds
+// im.mu.
+func (im *Image) drop(k tileKey) {
+	for ; im.jobs[k] != nil; k = above(k) {
+		delete(im.jobs, k)
+	}
+}
+
+// above returns the tile of the level above k that is halved from
+// it.
+func above(k tileKey) tileKey {
+	return tileKey{level: k.level + 1, x: k.x / 2, y: k.y / 2}
+}
+
+// finish caches tile img of j, stamped with the last frame that
+// asked for it so that it is not dropped before that frame draws
+// it, and hands it to the tile above if that is waiting for it. The
+// caller holds im.mu.
+func (im *Image) finish(j *job, img *image.RGBA) {
+	k := j.key
+	delete(im.jobs, k)
+	im.made[k]++
+	t := &tile{key: k, img: img, op: paint.NewImageOp(img), frame: j.frame}
+	im.tiles[k] = im.lru.PushFront(t)
+	im.used += len(img.Pix)
+	im.trim()
+	if p := im.jobs[above(k)]; p != nil {
+		p.kids[k] = img
+		p.wait--
+		if p.wait == 0 {
+			im.ready = append(im.ready, p)
+			im.run()
+		}
+	}
+}
+
+// Loading reports whether the last Layout drew stand-ins for tiles
+// that were not ready.
+func (im *Image) Loading() bool {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	return im.loading
+}
+
+// Wait waits for the tiles being made. Drawing without a window,
+// call Layout, then Wait while Loading, to get a frame with every
+// tile in view ready.
+func (im *Image) Wait() {
+	im.pending.Wait()
+}
+
+// make makes the pixels of the tile of j: a level 0 tile is cut
+// from the source, and one above it halved from j.kids. The gutter
+// is taken from the neighbours, without making them: from their
+// tiles if they are cached, or else from just the pixels along the
+// edge.
+func (im *Image) make(j *job) *image.RGBA {
+	k := j.key
+	pr := im.paddedRect(k)
+	lr := im.levelRect(k)
+	img := image.NewRGBA(image.Rectangle{Max: pr.Size()})
+	im.fill(img, pr.Min, k.level, lr, func(ck tileKey) *image.RGBA {
+		return j.kids[ck]
+	})
+	for _, g := range []image.Rectangle{
+		{Min: pr.Min, Max: image.Point{X: pr.Max.X, Y: lr.Min.Y}},
+		{Min: image.Point{X: pr.Min.X, Y: lr.Max.Y}, Max: pr.Max},
+		{Min: image.Point{X: pr.Min.X, Y: lr.Min.Y}, Max: image.Point{X: lr.Min.X, Y: lr.Max.Y}},
+		{Min: image.Point{X: lr.Max.X, Y: lr.Min.Y}, Max: image.Point{X: pr.Max.X, Y: lr.Max.Y}},
+	} {
+		if !g.Empty() {
+			im.fill(img, pr.Min, k.level, g, im.cached)
+		}
+	}
+	return img
+}
+
+// fill copies the pixels of level in r into dst, whose origin is at
+// org in the pixels of level. Above level 0, the pixels are halved
+// from those of the level below, taken from the tiles there that kid
+// returns, or else worked out from just the pixels under r, which
+// for the edge of a tile is a thin strip.
+func (im *Image) fill(dst *image.RGBA, org image.Point, level int, r image.Rectangle, kid func(tileKey) *image.RGBA) {
+	if level == 0 {
+		draw.Draw(dst, r.Sub(org), im.src, im.rect.Min.Add(r.Min), draw.Src)
+		return
+	}
+	below := image.Rectangle{Min: r.Min.Mul(2), Max: r.Max.Mul(2)}
+	below = below.Intersect(image.Rectangle{Max: im.levels[level-1]})
+	mosaic := image.NewRGBA(image.Rectangle{Max: below.Size()})
+	for cy := below.Min.Y / TileSize; cy*TileSize < below.Max.Y; cy++ {
+		for cx := below.Min.X / TileSize; cx*TileSize < below.Max.X; cx++ {
+			ck := tileKey{level: level - 1, x: cx, y: cy}
+			cr := im.levelRect(ck).Intersect(below)
+			child := kid(ck)
+			if child == nil {
+				im.fill(mosaic, below.Min, level-1, cr, im.cached)
+				continue
+			}
+			draw.Draw(mosaic, cr.Sub(below.Min), child, cr.Min.Sub(im.paddedRect(ck).Min), draw.Src)
+		}
+	}
+	if r.Min == org && r.Size() == dst.Rect.Size() {
+		halve(dst, mosaic)
+		return
+	}
+	out := image.NewRGBA(image.Rectangle{Max: r.Size()})
+	halve(out, mosaic)
+	draw.Draw(dst, r.Sub(org), out, image.Point{}, draw.Src)
+}
+
+// cached returns the pixels of cached tile k, gutter included, or
+// nil. It leaves the tile where it is in the cache.
+func (im *Image) cached(k tileKey) *image.RGBA {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if e, ok := im.tiles[k]; ok {
+		return e.Value.(*tile).img
+	}
+	return nil
+}
+
+// halve sets every pixel of dst to the average of the 2x2 pixels of
+// src under it, or of those of them that exist at the right and
+// bottom edges.
+func halve(dst, src *image.RGBA) {
+	sb := src.Rect
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			var sum [4]int
+			n := 0
+			for sy := 2 * y; sy < 2*y+2 && sy < sb.Max.Y; sy++ {
+				for sx := 2 * x; sx < 2*x+2 && sx < sb.Max.X; sx++ {
+					i := src.PixOffset(sx, sy)
+					for c := range sum {
+						sum[c] += int(src.Pix[i+c])
+					}
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			i := dst.PixOffset(x, y)
+			for c := range sum {
+				dst.Pix[i+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+}
+
+// trim drops tiles that are not in view until the cache is within
+// budget, or holds only tiles in view. The finest tiles go first,
+// least recently used first, since they are the quickest to make
+// again: a tile of level n is made from 4^n tiles of level 0. The
+// caller holds im.mu.
+func (im *Image) trim() {
+	budget := im.Budget
+	if budget == 0 {
+		budget = DefaultBudget
+	}
+	for level := 0; level < len(im.levels) && im.used > budget; level++ {
+		for e := im.lru.Back(); e != nil && im.used > budget; {
+			prev := e.Prev()
+			if t := e.Value.(*tile); t.key.level == level && t.frame < im.frame {
+				im.lru.Remove(e)
+				delete(im.tiles, t.key)
+				im.used -= len(t.img.Pix)
+			}
+			e = prev
+		}
+	}
+}
+
+// Cached returns the number of cached tiles and the bytes they
+// take.
+func (im *Image) Cached() (tiles, bytes int) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	return len(im.tiles), im.used
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// roundRectF rounds the edges of r to the nearest pixel.
+func roundRectF(r f32.Rectangle) f32.Rectangle {
+	round := func(v float32) float32 {
+		return float32(math.Floor(float64(v) + .5))
+	}
+	return f32.Rectangle{
+		Min: f32.Point{X: round(r.Min.X), Y: round(r.Min.Y)},
+		Max: f32.Point{X: round(r.Max.X), Y: round(r.Max.Y)},
+	}
+}
+
+func toRectF(r image.Rectangle) f32.Rectangle {
+	return f32.Rectangle{
+		Min: f32.Point{X: float32(r.Min.X), Y: float32(r.Min.Y)},
+		Max: f32.Point{X: float32(r.Max.X), Y: float32(r.Max.Y)},
+	}
+}
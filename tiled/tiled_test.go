@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package tiled
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+
+	"github.com/glycerine/hello_gio.go/raster"
+)
+
+// pattern returns a w x h image whose every pixel differs from its
+// neighbours.
+func pattern(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 0xff})
+		}
+	}
+	return img
+}
+
+// render lays out im over dest, clipped to a window of the given
+// size, until every tile in view is ready, and renders that frame.
+func render(im *Image, dest f32.Rectangle, size image.Point) *image.RGBA {
+	gtx := layout.NewContext(nil)
+	for {
+		gtx.Reset(nil, size)
+		im.Layout(gtx, dest, image.Rectangle{Max: size})
+		if !im.Loading() {
+			return raster.Render(gtx.Ops, size)
+		}
+		im.Wait()
+	}
+}
+
+// renderImage renders src painted over dest, in a window of the
+// given size.
+func renderImage(src *image.RGBA, dest f32.Rectangle, size image.Point) *image.RGBA {
+	gtx := layout.NewContext(nil)
+	gtx.Reset(nil, size)
+	paint.NewImageOp(src).Add(gtx.Ops)
+	paint.PaintOp{Rect: dest}.Add(gtx.Ops)
+	return raster.Render(gtx.Ops, size)
+}
+
+func TestLevels(t *testing.T) {
+	im := New(pattern(1, 1), image.Rect(0, 0, 1000, 600))
+	if got, want := im.Levels(), 3; got != want {
+		t.Fatalf("%d levels, want %d", got, want)
+	}
+	// 1000x600, 500x300, 250x150.
+	if got, want := im.levels[2], (image.Point{X: 250, Y: 150}); got != want {
+		t.Errorf("top level is %v, want %v", got, want)
+	}
+	for _, c := range []struct {
+		scale float64
+		level int
+	}{{2, 0}, {1, 0}, {.6, 0}, {.5, 1}, {.3, 1}, {.25, 2}, {.01, 2}} {
+		if got := im.level(c.scale); got != c.level {
+			t.Errorf("level at scale %g is %d, want %d", c.scale, got, c.level)
+		}
+	}
+}
+
+func TestHalve(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []uint8{
+		0, 0, 0, 0 /**/, 4, 4, 4, 4 /**/, 9, 9, 9, 9,
+		8, 8, 8, 8 /**/, 12, 12, 12, 12 /**/, 1, 1, 1, 1,
+	})
+	dst := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	halve(dst, src)
+	// The right edge averages the one column it has.
+	if want := []uint8{6, 6, 6, 6, 5, 5, 5, 5}; !bytes.Equal(dst.Pix, want) {
+		t.Errorf("got %v, want %v", dst.Pix, want)
+	}
+}
+
+// TestLayout checks that, at full size, the tiles show what one
+// ImageOp of the whole image does.
+func TestLayout(t *testing.T) {
+	src := pattern(700, 300)
+	size := image.Point{X: 700, Y: 300}
+	dest := f32.Rectangle{Max: f32.Point{X: 700, Y: 300}}
+	got := render(New(src, src.Rect), dest, size)
+	if want := renderImage(src, dest, size); !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("the tiles differ from the image")
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	im := New(pattern(600, 600), image.Rect(0, 0, 600, 600))
+	im.Placeholder = color.RGBA{B: 0xff, A: 0xff}
+	gtx := layout.NewContext(nil)
+	gtx.Reset(nil, image.Point{X: 600, Y: 600})
+	im.Layout(gtx, f32.Rectangle{Max: f32.Point{X: 600, Y: 600}}, image.Rect(0, 0, 600, 600))
+	img := raster.Render(gtx.Ops, image.Point{X: 600, Y: 600})
+	im.Wait()
+	if !im.Loading() {
+		t.Error("Loading is false with no tile ready")
+	}
+	if got := img.RGBAAt(300, 300); got != im.Placeholder {
+		t.Errorf("a tile that is not ready shows %v, want the placeholder", got)
+	}
+}
+
+// TestView checks that zooming in makes only the tiles in view, and
+// that a small budget keeps them and drops the rest.
+func TestView(t *testing.T) {
+	src := pattern(2048, 2048)
+	im := New(src, src.Rect)
+	size := image.Point{X: 300, Y: 300}
+	// Zoomed in 2x, the window shows the 150x150 source pixels at
+	// (300, 300), all inside tile (1, 1) of level 0.
+	dest := f32.Rectangle{Min: f32.Point{X: -600, Y: -600}, Max: f32.Point{X: 3496, Y: 3496}}
+	got := render(im, dest, size)
+	if want := renderImage(src, dest, size); !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("the tile differs from the image")
+	}
+	if n, b := im.Cached(); n != 1 || b != (TileSize+2)*(TileSize+2)*4 {
+		t.Errorf("%d tiles cached in %d bytes, want the one in view", n, b)
+	}
+
+	// Zoomed out to fit, level 3 is one tile made from all of the
+	// 64 below; with a budget of 4 tiles, only the one in view is
+	// kept.
+	im.Budget = 4 * TileSize * TileSize * 4
+	render(im, f32.Rectangle{Max: f32.Point{X: 256, Y: 256}}, size)
+	if n, _ := im.Cached(); n > 4 {
+		t.Errorf("%d tiles cached, want at most 4", n)
+	}
+	im.mu.Lock()
+	_, ok := im.tiles[tileKey{level: 3}]
+	im.mu.Unlock()
+	if !ok {
+		t.Error("the tile in view was dropped")
+	}
+}
+
+// TestMakeOnce checks that zooming out cuts each tile of the source
+// once, even when the tiles below are dropped as soon as they are
+// made, that zooming out again reuses the coarse tiles, and that the
+// tiles made along the way, gutters and all, are the image halved.
+func TestMakeOnce(t *testing.T) {
+	src := pattern(2048, 2048)
+	size := image.Point{X: 300, Y: 300}
+	fit := f32.Rectangle{Max: f32.Point{X: 256, Y: 256}}
+	small := New(src, src.Rect)
+	small.Budget = 4 * TileSize * TileSize * 4
+	render(small, fit, size)
+	if cuts(small) != 64 {
+		t.Errorf("with a budget of 4 tiles, %d tiles cut from the source, want 64", cuts(small))
+	}
+
+	im := New(src, src.Rect)
+	render(im, fit, size)
+
+	// Level 1, halved from the whole image at once.
+	want := image.NewRGBA(image.Rect(0, 0, 1024, 1024))
+	halve(want, src)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			k := tileKey{level: 1, x: x, y: y}
+			img := im.cached(k)
+			if img == nil {
+				t.Fatalf("tile %v was not kept", k)
+			}
+			pr := im.paddedRect(k)
+			if !equalAt(img, want, pr.Min) {
+				t.Errorf("tile %v differs from the image halved", k)
+			}
+		}
+	}
+
+	// In to level 1, and back out.
+	render(im, f32.Rectangle{Max: f32.Point{X: 1024, Y: 1024}}, size)
+	render(im, fit, size)
+	if cuts(im) != 64 {
+		t.Errorf("%d tiles cut from the source after zooming out again, want 64", cuts(im))
+	}
+}
+
+// TestStartOnce checks that tiles asked for at the same time, on
+// their own and through the tiles above them, are each made once,
+// from the bottom up.
+func TestStartOnce(t *testing.T) {
+	defer func(n int) { maxWorkers = n }(maxWorkers)
+	maxWorkers = 8
+	src := pattern(2048, 2048)
+	im := New(src, src.Rect)
+	var keys []tileKey
+	for l := range im.levels {
+		for y := 0; y < 8>>uint(l); y++ {
+			for x := 0; x < 8>>uint(l); x++ {
+				keys = append(keys, tileKey{level: l, x: x, y: y})
+			}
+		}
+	}
+	// The coarsest first, so that most tiles are asked for through
+	// those above them before they are on their own.
+	var wg sync.WaitGroup
+	for i := len(keys) - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(k tileKey) {
+			defer wg.Done()
+			im.mu.Lock()
+			im.start(k)
+			im.mu.Unlock()
+		}(keys[i])
+	}
+	wg.Wait()
+	im.Wait()
+	for _, k := range keys {
+		if n := im.made[k]; n != 1 {
+			t.Errorf("tile %v made %d times, want once", k, n)
+		}
+	}
+	if len(im.jobs) != 0 || len(im.ready) != 0 {
+		t.Errorf("%d jobs left, %d of them ready", len(im.jobs), len(im.ready))
+	}
+}
+
+// cuts returns the number of level 0 tiles cut from the source.
+func cuts(im *Image) int {
+	n := 0
+	for k, m := range im.made {
+		if k.level == 0 {
+			n += m
+		}
+	}
+	return n
+}
+
+// equalAt reports whether img is the same as the pixels of ref at
+// off.
+func equalAt(img, ref *image.RGBA, off image.Point) bool {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.RGBAAt(x, y) != ref.RGBAAt(x+off.X, y+off.Y) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package tooltip shows a floating panel of text when the pointer
+// rests over an area of the window.
+//
+// Gio's pointer package reports Press, Release, Move and Cancel,
+// but not when the pointer enters or leaves a handler's area, and a
+// handler hears nothing once the pointer has moved off it. So Tips
+// watches the whole window with one pass-through handler, and
+// widgets register their areas, in window coordinates, every frame.
+// The area under the pointer that was added last is the hovered
+// one; a change of hovered area is an enter and a leave.
+package tooltip
+
+import (
+	"image"
+	"image/color"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+)
+
+const (
+	// DefaultDelay is how long the pointer rests on an area before
+	// its tip shows, when Tips.Delay is zero.
+	DefaultDelay = 500 * time.Millisecond
+	// defaultMaxWidth is the width, in dp, at which tip text
+	// wraps, when Tips.MaxWidth is zero.
+	defaultMaxWidth = 300
+)
+
+// DefaultStyle is the look of the panel when Tips.Style is the zero
+// value. Its Size is worked out from the text.
+var DefaultStyle = box.Box{
+	Fill:         color.RGBA{40, 40, 40, 235},
+	CornerRadius: 4,
+	Padding:      6,
+	TextColor:    color.RGBA{255, 255, 255, 255},
+}
+
+// Tips tracks the pointer over the areas registered with Add and
+// shows the tip of the hovered one. Like gesture.Click, Tips must
+// be kept across frames; its address is the key of its pointer
+// handler.
+//
+// Each frame, call Update before laying out the widgets, Add for
+// each area while laying them out, and Layout last, so that the
+// panel is on top.
+type Tips struct {
+	// Delay is how long the pointer must rest on an area before
+	// its tip shows. Zero means DefaultDelay.
+	Delay time.Duration
+	// MaxWidth is the widest the text gets before it wraps. Zero
+	// means 300dp.
+	MaxWidth unit.Value
+	// Style is the look of the panel. The zero value means
+	// DefaultStyle. Its Size is ignored.
+	Style box.Box
+
+	// pos is the last pointer position, and inWindow reports
+	// whether it is still known to be over the window.
+	pos      f32.Point
+	inWindow bool
+	// areas are the areas added since the last Layout.
+	areas []area
+	// hovered is the key of the hovered area, or nil, and since is
+	// when the pointer entered it.
+	hovered interface{}
+	since   time.Time
+	// pressed hides the tip of the hovered area after a press,
+	// until the pointer leaves it. newPress is a press that Layout
+	// has yet to see.
+	pressed, newPress bool
+	// panel is where the last Layout drew the tip, if it did.
+	panel image.Rectangle
+}
+
+// area is a region of the window with a tip.
+type area struct {
+	key  interface{}
+	r    image.Rectangle
+	text string
+}
+
+// Update handles the pointer events since the last frame.
+func (t *Tips) Update(gtx *layout.Context) {
+	for _, evt := range gtx.Events(t) {
+		e, ok := evt.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Move, pointer.Release:
+			t.pos, t.inWindow = e.Position, true
+		case pointer.Press:
+			t.pos, t.inWindow = e.Position, true
+			t.newPress = true
+		case pointer.Cancel:
+			// The pointer left the window, or its events went
+			// to another handler: leave the hovered area and
+			// hide its tip, so that it waits for the delay
+			// again if the pointer comes back.
+			t.inWindow = false
+			t.hovered, t.pressed, t.newPress = nil, false, false
+			t.panel = image.Rectangle{}
+		}
+	}
+}
+
+// Pointer returns the last position of the pointer, and whether
+// it is over the window.
+func (t *Tips) Pointer() (image.Point, bool) {
+	return image.Point{X: int(t.pos.X), Y: int(t.pos.Y)}, t.inWindow
+}
+
+// Add registers the area r, in window coordinates, with its tip
+// text for this frame. The key identifies the area from frame to
+// frame, and must be comparable. Areas added later are on top.
+func (t *Tips) Add(key interface{}, r image.Rectangle, text string) {
+	t.areas = append(t.areas, area{key: key, r: r, text: text})
+}
+
+// Hovered reports whether the pointer is over the area of key,
+// and no area added after it, as of the last Layout.
+func (t *Tips) Hovered(key interface{}) bool {
+	return t.hovered != nil && t.hovered == key
+}
+
+// Layout works out the hovered area, watches the pointer over a
+// window of the given size, and draws the tip of the hovered area
+// once the pointer has rested on it for the delay.
+func (t *Tips) Layout(gtx *layout.Context, th *material.Theme, window image.Point) {
+	now := gtx.Now()
+	t.panel = image.Rectangle{}
+	// The hovered area is looked for among those added this frame
+	// only, so one that is no longer added is left, and its tip
+	// hidden, even if the pointer has not moved.
+	var hit *area
+	if t.inWindow {
+		pos, _ := t.Pointer()
+		for i := len(t.areas) - 1; i >= 0; i-- {
+			if pos.In(t.areas[i].r) {
+				hit = &t.areas[i]
+				break
+			}
+		}
+	}
+	var key interface{}
+	if hit != nil {
+		key = hit.key
+	}
+	if key != t.hovered {
+		// Leave the old area and enter the new one.
+		t.hovered, t.since = key, now
+		t.pressed = false
+	}
+	if t.newPress {
+		t.pressed, t.newPress = true, false
+	}
+	t.areas = t.areas[:0]
+
+	// Watch the whole window, letting every event through to the
+	// handlers below.
+	var stack op.StackOp
+	stack.Push(gtx.Ops)
+	pointer.PassOp{Pass: true}.Add(gtx.Ops)
+	pointer.Rect(image.Rectangle{Max: window}).Add(gtx.Ops)
+	pointer.InputOp{Key: t}.Add(gtx.Ops)
+	stack.Pop()
+
+	if hit == nil || hit.text == "" || t.pressed {
+		return
+	}
+	delay := t.Delay
+	if delay == 0 {
+		delay = DefaultDelay
+	}
+	if show := t.since.Add(delay); now.Before(show) {
+		op.InvalidateOp{At: show}.Add(gtx.Ops)
+		return
+	}
+	t.layoutPanel(gtx, th, window, hit.text)
+}
+
+// layoutPanel draws txt, wrapped, in a panel below and to the right
+// of the pointer, moved as needed to keep it inside the window.
+func (t *Tips) layoutPanel(gtx *layout.Context, th *material.Theme, window image.Point, txt string) {
+	b := t.Style
+	if b == (box.Box{}) {
+		b = DefaultStyle
+	}
+	if b.TextSize.V == 0 {
+		b.TextSize = th.TextSize.Scale(.85)
+	}
+	b.Wrap = true
+	maxWidth := t.MaxWidth
+	if maxWidth.V == 0 {
+		maxWidth = unit.Dp(defaultMaxWidth)
+	}
+	inset := b.StrokeWidth + b.Padding
+	b.Size = measure(gtx, th, b.TextSize, txt, gtx.Px(maxWidth)).Add(image.Point{X: 2 * inset, Y: 2 * inset})
+
+	// Keep clear of the pointer: below it and to its right, or
+	// above it if there is no room below.
+	ptr, _ := t.Pointer()
+	gap := gtx.Px(unit.Dp(16))
+	pos := ptr.Add(image.Point{X: gap / 2, Y: gap})
+	if pos.Y+b.Size.Y > window.Y {
+		pos.Y = ptr.Y - gap/2 - b.Size.Y
+	}
+	pos.X = clamp(pos.X, 0, window.X-b.Size.X)
+	pos.Y = clamp(pos.Y, 0, window.Y-b.Size.Y)
+	t.panel = b.Layout(gtx, th, pos, txt)
+}
+
+// measure returns the size of txt wrapped at maxWidth pixels.
+func measure(gtx *layout.Context, th *material.Theme, size unit.Value, txt string, maxWidth int) image.Point {
+	lbl := th.Label(size, txt)
+	lines := th.Shaper.Layout(gtx, lbl.Font, txt, text.LayoutOptions{MaxWidth: maxWidth}).Lines
+	var sz image.Point
+	for _, l := range lines {
+		if w := l.Width.Ceil(); w > sz.X {
+			sz.X = w
+		}
+		sz.Y += (l.Ascent + l.Descent).Ceil()
+	}
+	return sz
+}
+
+// clamp limits v to [min, max], preferring min if the range is
+// empty, so that a panel too big for the window shows its start.
+func clamp(v, min, max int) int {
+	if v > max {
+		v = max
+	}
+	if v < min {
+		v = min
+	}
+	return v
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package tooltip
+
+import (
+	"image"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/font/gofont"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// clock is a system.Config at one pixel per dp, with a settable
+// time.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Px(v unit.Value) int {
+	return int(v.V + .5)
+}
+
+// queue hands out, once, the events queued for each key.
+type queue map[event.Key][]event.Event
+
+func (q queue) Events(k event.Key) []event.Event {
+	evs := q[k]
+	delete(q, k)
+	return evs
+}
+
+var window = image.Point{X: 400, Y: 300}
+
+// tester lays out frames with two areas, "a" over "b", after moving
+// the pointer or pressing it.
+type tester struct {
+	tips  *Tips
+	q     queue
+	clock *clock
+	gtx   *layout.Context
+	th    *material.Theme
+	// b is the text of area b.
+	b string
+	// noA leaves area a out.
+	noA bool
+}
+
+var registerFonts sync.Once
+
+func newTester() *tester {
+	registerFonts.Do(gofont.Register)
+	q := make(queue)
+	return &tester{
+		tips:  new(Tips),
+		q:     q,
+		clock: &clock{now: time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)},
+		gtx:   layout.NewContext(q),
+		th:    material.NewTheme(),
+		b:     "b tip",
+	}
+}
+
+// frame lays out a frame at d after the start, after the given
+// events.
+func (ts *tester) frame(d time.Duration, evs ...pointer.Event) {
+	for _, e := range evs {
+		ts.q[ts.tips] = append(ts.q[ts.tips], e)
+	}
+	start := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	ts.clock.now = start.Add(d)
+	ts.gtx.Reset(ts.clock, window)
+	ts.tips.Update(ts.gtx)
+	ts.tips.Add("b", image.Rect(0, 0, 400, 300), ts.b)
+	if !ts.noA {
+		ts.tips.Add("a", image.Rect(100, 100, 200, 150), "a tip")
+	}
+	ts.tips.Layout(ts.gtx, ts.th, window)
+}
+
+func move(x, y float32) pointer.Event {
+	return pointer.Event{Type: pointer.Move, Position: f32.Point{X: x, Y: y}}
+}
+
+func TestDelay(t *testing.T) {
+	ts := newTester()
+	ts.frame(0, move(150, 120))
+	if !ts.tips.Hovered("a") || ts.tips.Hovered("b") {
+		t.Fatal("the pointer is not over the top area")
+	}
+	ts.frame(DefaultDelay - time.Millisecond)
+	if !ts.tips.panel.Empty() {
+		t.Fatal("the tip showed before the delay")
+	}
+	ts.frame(DefaultDelay)
+	p := ts.tips.panel
+	if p.Empty() {
+		t.Fatal("the tip did not show after the delay")
+	}
+	if p.Min.X <= 150 || p.Min.Y <= 120 {
+		t.Errorf("the tip at %v covers the pointer", p)
+	}
+}
+
+func TestLeave(t *testing.T) {
+	ts := newTester()
+	ts.frame(0, move(150, 120))
+	ts.frame(DefaultDelay)
+	// Moving onto the area below is a leave and an enter: the tip
+	// of b waits for the delay again.
+	ts.frame(DefaultDelay+time.Millisecond, move(250, 120))
+	if ts.tips.Hovered("a") || !ts.tips.Hovered("b") {
+		t.Fatal("the pointer did not move to the area below")
+	}
+	if !ts.tips.panel.Empty() {
+		t.Error("a tip showed right after the pointer moved to another area")
+	}
+	ts.frame(time.Second, pointer.Event{Type: pointer.Cancel})
+	if ts.tips.Hovered("b") {
+		t.Error("the area is still hovered after a cancel")
+	}
+}
+
+// TestCancel checks that a cancel hides the tip, and that it waits
+// for the delay again when the pointer comes back, even in the same
+// frame.
+func TestCancel(t *testing.T) {
+	ts := newTester()
+	ts.frame(0, move(150, 120))
+	ts.frame(DefaultDelay)
+	ts.q[ts.tips] = []event.Event{pointer.Event{Type: pointer.Cancel}}
+	ts.tips.Update(ts.gtx)
+	if !ts.tips.panel.Empty() || ts.tips.Hovered("a") {
+		t.Fatal("the tip is still up after a cancel")
+	}
+	ts.frame(2*DefaultDelay, pointer.Event{Type: pointer.Cancel}, move(150, 120))
+	if !ts.tips.panel.Empty() {
+		t.Error("the tip showed right after a cancel")
+	}
+	ts.frame(3 * DefaultDelay)
+	if ts.tips.panel.Empty() {
+		t.Error("the tip did not show after the delay")
+	}
+}
+
+// TestGone checks that the tip of an area that is no longer added
+// hides, though the pointer stays put.
+func TestGone(t *testing.T) {
+	ts := newTester()
+	ts.frame(0, move(150, 120))
+	ts.frame(DefaultDelay)
+	ts.b = ""
+	ts.noA = true
+	ts.frame(DefaultDelay + time.Millisecond)
+	if ts.tips.Hovered("a") || !ts.tips.panel.Empty() {
+		t.Error("the tip of an area that is gone still shows")
+	}
+}
+
+func TestPressHides(t *testing.T) {
+	ts := newTester()
+	ts.frame(0, move(150, 120))
+	ts.frame(DefaultDelay, pointer.Event{Type: pointer.Press, Position: f32.Point{X: 150, Y: 120}})
+	if !ts.tips.panel.Empty() {
+		t.Fatal("the tip showed after a press")
+	}
+	ts.frame(2*DefaultDelay, move(151, 121))
+	if !ts.tips.panel.Empty() {
+		t.Fatal("the tip showed again before the pointer left the area")
+	}
+	ts.frame(2*DefaultDelay, move(250, 120))
+	ts.frame(2*DefaultDelay, move(150, 120))
+	ts.frame(3 * DefaultDelay)
+	if ts.tips.panel.Empty() {
+		t.Error("the tip did not come back after the pointer left and came back")
+	}
+}
+
+func TestInsideWindow(t *testing.T) {
+	ts := newTester()
+	ts.b = strings.Repeat("a long tip that has to wrap ", 5)
+	ts.frame(0, move(395, 295))
+	ts.frame(DefaultDelay)
+	p := ts.tips.panel
+	if !p.In(image.Rectangle{Max: window}) {
+		t.Errorf("the tip at %v is not inside the window", p)
+	}
+	if p.Max.Y > 295 {
+		t.Errorf("the tip at %v covers the pointer", p)
+	}
+	inset := 2 * DefaultStyle.Padding
+	if w := p.Dx() - inset; w > defaultMaxWidth {
+		t.Errorf("the text is %d wide, want at most %d", w, defaultMaxWidth)
+	}
+	if h := p.Dy() - inset; h < 3*measure(ts.gtx, ts.th, ts.th.TextSize.Scale(.85), "a", 1e6).Y {
+		t.Errorf("the text is %d high, want it wrapped over at least 3 lines", h)
+	}
+}
@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+// The viewer shows image files one at a time, fitted to the window.
+// The arrow keys, PageUp and PageDown step through them, Home and
+// End jump to the ends. The images next to the one shown are
+// decoded ahead of time, by an imageLoader, so that stepping does
+// not wait for a decode.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+	"gioui.org/widget/material"
+
+	"github.com/glycerine/hello_gio.go/box"
+	"github.com/glycerine/hello_gio.go/scene"
+)
+
+// preloadRadius is how many images on each side of the shown one
+// are kept decoded.
+const preloadRadius = 1
+
+// viewerExts are the extensions of the files the viewer picks out
+// of a directory.
+var viewerExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".apng": true,
+}
+
+// viewer steps through a list of image files.
+type viewer struct {
+	files []string
+	// cur is the index of the file shown.
+	cur int
+	// images holds the files near cur, by index. An entry with a
+	// nil img and err is still being decoded.
+	images map[int]*sceneImage
+	loader *imageLoader
+	// keys, if set, is given the keys the viewer has no use for.
+	keys func(key.Event) bool
+	// loops, if not zero, is how many times animations play;
+	// negative means forever.
+	loops int
+}
+
+// viewerFiles expands args into the list of files to view. A
+// directory stands for the image files in it, in name order; files
+// named explicitly are kept whatever their extension.
+func viewerFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		infos, err := ioutil.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, fi := range infos {
+			if !fi.IsDir() && viewerExts[strings.ToLower(filepath.Ext(fi.Name()))] {
+				names = append(names, filepath.Join(arg, fi.Name()))
+			}
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no image files in %s", strings.Join(args, " "))
+	}
+	return files, nil
+}
+
+// newViewer shows files, starting with the first, and starts
+// decoding it and its neighbours. changed is called, on a decoding
+// goroutine, after a file has been decoded; set it to w.Invalidate.
+func newViewer(files []string, changed func()) *viewer {
+	v := &viewer{
+		files:  files,
+		images: make(map[int]*sceneImage),
+		loader: newImageLoader(),
+	}
+	v.loader.setChanged(changed)
+	v.preload()
+	return v
+}
+
+// show makes file i the shown one, within the bounds of the list.
+func (v *viewer) show(i int) {
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(v.files) {
+		i = len(v.files) - 1
+	}
+	if i == v.cur {
+		return
+	}
+	v.cur = i
+	v.preload()
+}
+
+// preload starts decoding the files within preloadRadius of cur
+// that are not decoded yet, and drops the ones further away; they
+// are decoded again if they come back into range.
+func (v *viewer) preload() {
+	for i := range v.images {
+		if i < v.cur-preloadRadius || i > v.cur+preloadRadius {
+			delete(v.images, i)
+		}
+	}
+	for i := v.cur - preloadRadius; i <= v.cur+preloadRadius; i++ {
+		if i < 0 || i >= len(v.files) || v.images[i] != nil {
+			continue
+		}
+		si := &sceneImage{spec: &scene.Image{
+			Path:      v.files[i],
+			Place:     scene.PlaceFit,
+			Letterbox: "black",
+		}}
+		v.images[i] = si
+		v.loader.load(si, v.files[i])
+	}
+}
+
+// Update handles the keys pressed since the last frame.
+func (v *viewer) Update(gtx *layout.Context) {
+	for _, evt := range gtx.Events(v) {
+		e, ok := evt.(key.Event)
+		if !ok {
+			continue
+		}
+		switch e.Name {
+		case key.NameLeftArrow, key.NameUpArrow, key.NamePageUp:
+			v.show(v.cur - 1)
+		case key.NameRightArrow, key.NameDownArrow, key.NamePageDown:
+			v.show(v.cur + 1)
+		case key.NameHome:
+			v.show(0)
+		case key.NameEnd:
+			v.show(len(v.files) - 1)
+		default:
+			if si := v.images[v.cur]; si.animKey(e) {
+				break
+			}
+			if v.keys != nil {
+				v.keys(e)
+			}
+		}
+	}
+}
+
+var (
+	viewerBackground = color.RGBA{A: 255}
+	// viewerLabelStyle is the look of the overlay naming the
+	// shown file. Its sizes are in dp.
+	viewerLabelStyle = box.Box{
+		Size:         image.Point{X: 460, Y: 30},
+		Fill:         color.RGBA{0, 0, 0, 160},
+		CornerRadius: 4,
+		Padding:      6,
+		TextColor:    color.RGBA{255, 255, 255, 255},
+		Ellipsis:     true,
+	}
+)
+
+// Layout draws the shown file fitted to a window of the given size,
+// with its name and size in the top left corner.
+func (v *viewer) Layout(gtx *layout.Context, th *material.Theme, window image.Point) {
+	v.loader.apply()
+	v.Update(gtx)
+	key.InputOp{Key: v, Focus: true}.Add(gtx.Ops)
+
+	paint.ColorOp{Color: viewerBackground}.Add(gtx.Ops)
+	paint.PaintOp{Rect: toRectF(image.Rectangle{Max: window})}.Add(gtx.Ops)
+	si := v.images[v.cur]
+	if si.player != nil {
+		si.player.Loops = v.loops
+	}
+	si.Layout(gtx, window)
+	if si.img == nil && si.err != nil {
+		si.layoutError(gtx, th)
+	}
+
+	lb := dpBox(gtx, viewerLabelStyle)
+	lb.TextSize = th.TextSize.Scale(.85)
+	margin := dpPx(gtx, 10)
+	pos := image.Point{X: margin, Y: margin}
+	lb.Layout(gtx, th, pos, v.label(si))
+	if si.img != nil && si.err != nil {
+		b := dpBox(gtx, badgeStyle)
+		b.TextSize = th.TextSize.Scale(.75)
+		b.Layout(gtx, th, image.Point{X: pos.X, Y: pos.Y + lb.Size.Y + margin}, si.err.Error())
+	}
+}
+
+// label returns the overlay text for si, the shown file.
+func (v *viewer) label(si *sceneImage) string {
+	name := filepath.Base(v.files[v.cur])
+	var size string
+	switch {
+	case si.player != nil:
+		p := si.player
+		size = fmt.Sprintf("%d×%d  frame %d/%d", si.src.Dx(), si.src.Dy(), p.Frame()+1, p.Frames())
+		if !p.Playing() {
+			size += " ❚❚"
+		}
+	case si.img != nil:
+		size = fmt.Sprintf("%d×%d", si.src.Dx(), si.src.Dy())
+	case si.err != nil:
+		size = "failed"
+	default:
+		size = "loading…"
+	}
+	return fmt.Sprintf("%s  %s  (%d/%d)", name, size, v.cur+1, len(v.files))
+}
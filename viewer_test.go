@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gioui.org/io/key"
+	"gioui.org/io/system"
+	"gioui.org/layout"
+
+	"github.com/glycerine/hello_gio.go/raster"
+)
+
+func TestViewerFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "viewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b.png", "a.JPG", "notes.txt", "sub/c.png"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	notes := filepath.Join(dir, "notes.txt")
+	got, err := viewerFiles([]string{notes, dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{notes, filepath.Join(dir, "a.JPG"), filepath.Join(dir, "b.png")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := viewerFiles([]string{filepath.Join(dir, "sub", "x")}); err == nil {
+		t.Error("no error for a missing file")
+	}
+	if _, err := viewerFiles([]string{filepath.Join(dir, "sub")}); err != nil {
+		t.Errorf("error for a directory with an image: %v", err)
+	}
+}
+
+// viewerTestFiles are four images to step through.
+var viewerTestFiles = []string{
+	"points.png",
+	"testdata/golden/image_fit.png",
+	"testdata/golden/plot.png",
+	"testdata/golden/boxes.png",
+}
+
+func TestViewerKeys(t *testing.T) {
+	q := make(scriptQueue)
+	v := newViewer(viewerTestFiles, nil)
+	gtx := layout.NewContext(q)
+	th := testTheme()
+	e := testFrame()
+	frame := func(keys ...string) {
+		for _, k := range keys {
+			q[v] = append(q[v], key.Event{Name: k})
+		}
+		gtx.Reset(e.Config, e.Size)
+		v.Layout(gtx, th, e.Size)
+		v.loader.wait()
+	}
+	loaded := func() []int {
+		var is []int
+		for i := range v.images {
+			is = append(is, i)
+		}
+		sort.Ints(is)
+		return is
+	}
+	for _, tc := range []struct {
+		keys   []string
+		cur    int
+		loaded []int
+	}{
+		{nil, 0, []int{0, 1}},
+		{[]string{key.NameRightArrow}, 1, []int{0, 1, 2}},
+		{[]string{key.NamePageDown, key.NameDownArrow}, 3, []int{2, 3}},
+		{[]string{key.NameRightArrow}, 3, []int{2, 3}},
+		{[]string{key.NamePageUp}, 2, []int{1, 2, 3}},
+		{[]string{key.NameHome, key.NameLeftArrow}, 0, []int{0, 1}},
+		{[]string{key.NameEnd, key.NameUpArrow}, 2, []int{1, 2, 3}},
+	} {
+		frame(tc.keys...)
+		if v.cur != tc.cur {
+			t.Errorf("after %q showing file %d, want %d", tc.keys, v.cur, tc.cur)
+		}
+		if got := loaded(); !reflect.DeepEqual(got, tc.loaded) {
+			t.Errorf("after %q files %v are loaded, want %v", tc.keys, got, tc.loaded)
+		}
+	}
+	// The decodes queued during the last frame land in the next.
+	frame()
+	for i, si := range v.images {
+		if si.img == nil {
+			t.Errorf("file %d is not decoded", i)
+		}
+	}
+}
+
+// TestGoldenViewer covers points.png fitted to the viewer window,
+// with the file name overlay.
+func TestGoldenViewer(t *testing.T) {
+	v := newViewer(viewerTestFiles, nil)
+	v.loader.wait()
+	gtx := layout.NewContext(nil)
+	e := testFrame()
+	e.Size = image.Point{X: 700, Y: 500}
+	gtx.Reset(e.Config, e.Size)
+	v.Layout(gtx, testTheme(), e.Size)
+	checkGolden(t, "viewer", raster.Render(gtx.Ops, e.Size))
+}
+
+// TestViewerAnimation plays an animated GIF in the viewer, and
+// pauses and steps it with the keys.
+func TestViewerAnimation(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	pal := color.Palette{color.RGBA{R: 0xff, A: 0xff}, color.RGBA{G: 0xff, A: 0xff}, color.RGBA{B: 0xff, A: 0xff}}
+	g := &gif.GIF{Delay: []int{10, 10, 10}}
+	for i := range pal {
+		img := image.NewPaletted(image.Rect(0, 0, 8, 8), pal)
+		for p := range img.Pix {
+			img.Pix[p] = uint8(i)
+		}
+		g.Image = append(g.Image, img)
+	}
+	path := filepath.Join(dir, "anim.gif")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	q := make(scriptQueue)
+	v := newViewer([]string{path}, nil)
+	v.loader.wait()
+	gtx := layout.NewContext(q)
+	cfg := &clockConfig{now: testConfig{}.Now()}
+	e := system.FrameEvent{Config: cfg, Size: image.Point{X: 100, Y: 100}}
+	frame := func(after time.Duration, keys ...string) {
+		for _, k := range keys {
+			q[v] = append(q[v], key.Event{Name: k})
+		}
+		cfg.now = cfg.now.Add(after)
+		gtx.Reset(e.Config, e.Size)
+		v.Layout(gtx, testTheme(), e.Size)
+	}
+	shown := func() color.RGBA {
+		return raster.Render(gtx.Ops, e.Size).RGBAAt(50, 50)
+	}
+	frame(0)
+	if got := shown(); got != pal[0] {
+		t.Fatalf("first frame shows %v, want %v", got, pal[0])
+	}
+	frame(150 * time.Millisecond)
+	if got := shown(); got != pal[1] {
+		t.Errorf("second frame shows %v, want %v", got, pal[1])
+	}
+	frame(0, "Space")
+	frame(time.Second)
+	if got := shown(); got != pal[1] {
+		t.Errorf("paused animation moved on to %v", got)
+	}
+	if label := v.label(v.images[0]); !strings.Contains(label, "frame 2/3") {
+		t.Errorf("label %q does not name frame 2 of 3", label)
+	}
+	frame(0, ",", ",")
+	if got := shown(); got != pal[2] {
+		t.Errorf("stepping back twice shows %v, want %v", got, pal[2])
+	}
+}
@@ -0,0 +1,477 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package vlog is a leveled, structured logger. Each subsystem logs
+// through a Logger of its own name, and a record is a level, a
+// message and key/value fields, written either as a line of text
+//
+//	loader.go:80 2019-10-01 12:00:00.5 -0400 EDT INFO loader: loaded path=points.png err=<nil>
+//
+// or as a JSON object per line.
+//
+// Which records are written is set per subsystem by Levels, which
+// can be changed at any time. A record below its level costs a map
+// lookup: no lock is taken, its message is not formatted and
+// runtime.Caller is not called.
+package vlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// Level is the severity of a record.
+type Level int32
+
+const (
+	Trace Level = iota
+	Debug
+	Info
+	Warn
+	Error
+	// Off, as a level to log at, writes nothing.
+	Off
+)
+
+var levelNames = [...]string{"trace", "debug", "info", "warn", "error", "off"}
+
+func (l Level) String() string {
+	if l < Trace || l > Off {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name, as String writes it.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown level %q, want one of %s", s, strings.Join(levelNames[:], ", "))
+}
+
+// Levels are the lowest levels written: Default for every subsystem
+// but those in Subsystems.
+type Levels struct {
+	Default    Level
+	Subsystems map[string]Level
+}
+
+// ParseLevels parses a spec like "info,reload=debug,tiled=trace": a
+// comma separated list of a default level and subsystem=level pairs.
+// Later entries win over earlier ones, and a default that is not
+// given is Info.
+func ParseLevels(spec string) (Levels, error) {
+	ls := Levels{Default: Info}
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		i := strings.IndexByte(f, '=')
+		if i < 0 {
+			l, err := ParseLevel(f)
+			if err != nil {
+				return Levels{}, err
+			}
+			ls.Default = l
+			continue
+		}
+		name := strings.TrimSpace(f[:i])
+		if name == "" {
+			return Levels{}, fmt.Errorf("no subsystem name in %q", f)
+		}
+		l, err := ParseLevel(strings.TrimSpace(f[i+1:]))
+		if err != nil {
+			return Levels{}, fmt.Errorf("%s: %v", name, err)
+		}
+		if ls.Subsystems == nil {
+			ls.Subsystems = make(map[string]Level)
+		}
+		ls.Subsystems[name] = l
+	}
+	return ls, nil
+}
+
+// String returns ls as a spec for ParseLevels, the subsystems in
+// name order.
+func (ls Levels) String() string {
+	parts := []string{ls.Default.String()}
+	var names []string
+	for name := range ls.Subsystems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+ls.Subsystems[name].String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// enabled reports whether a record of the subsystem name at level l
+// is written.
+func (ls *Levels) enabled(name string, l Level) bool {
+	min, ok := ls.Subsystems[name]
+	if !ok {
+		min = ls.Default
+	}
+	return l >= min && l < Off
+}
+
+var (
+	// levels holds the *Levels in force. They are replaced whole,
+	// never changed, so that readers need no lock.
+	levels atomic.Value
+	// setMu serializes the writers of levels.
+	setMu sync.Mutex
+)
+
+func init() {
+	levels.Store(&Levels{Default: Info})
+}
+
+// SetLevels sets the levels written, from now on.
+func SetLevels(ls Levels) {
+	subs := make(map[string]Level, len(ls.Subsystems))
+	for name, l := range ls.Subsystems {
+		subs[name] = l
+	}
+	setMu.Lock()
+	levels.Store(&Levels{Default: ls.Default, Subsystems: subs})
+	setMu.Unlock()
+}
+
+// SetLevel sets the level of one subsystem, leaving the others as
+// they are.
+func SetLevel(name string, l Level) {
+	setMu.Lock()
+	defer setMu.Unlock()
+	old := levels.Load().(*Levels)
+	subs := map[string]Level{name: l}
+	for n, l := range old.Subsystems {
+		if n != name {
+			subs[n] = l
+		}
+	}
+	levels.Store(&Levels{Default: old.Default, Subsystems: subs})
+}
+
+// CurrentLevels returns the levels in force.
+func CurrentLevels() Levels {
+	ls := levels.Load().(*Levels)
+	subs := make(map[string]Level, len(ls.Subsystems))
+	for name, l := range ls.Subsystems {
+		subs[name] = l
+	}
+	return Levels{Default: ls.Default, Subsystems: subs}
+}
+
+// Format is how records are written.
+type Format int
+
+const (
+	// Text writes a line of caller, time, level, subsystem,
+	// message and key=value fields.
+	Text Format = iota
+	// JSON writes an object per line, with the keys time, level,
+	// sub, msg and caller, followed by the fields.
+	JSON
+)
+
+func (f Format) String() string {
+	if f == JSON {
+		return "json"
+	}
+	return "text"
+}
+
+// ParseFormat parses "text" or "json".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	}
+	return 0, fmt.Errorf("unknown log format %q, want text or json", s)
+}
+
+// RecordLevel returns the level of a record as written in either
+// format, and false if line is not the first line of a record.
+func RecordLevel(line string) (Level, bool) {
+	if strings.HasPrefix(line, "{") {
+		var rec struct {
+			Level string
+		}
+		if json.Unmarshal([]byte(line), &rec) != nil {
+			return 0, false
+		}
+		l, err := ParseLevel(rec.Level)
+		return l, err == nil && l < Off
+	}
+	// The level follows the caller and the time, which is a few
+	// fields at most.
+	fields := strings.Fields(line)
+	if len(fields) > 8 {
+		fields = fields[:8]
+	}
+	for _, f := range fields {
+		for l, name := range levelNames[:Off] {
+			if f == strings.ToUpper(name) {
+				return Level(l), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// TimeFormat is the default layout of the time in Text records.
+const TimeFormat = "2006-01-02 15:04:05.999 -0700 MST"
+
+// output is where and how records are written.
+var output = struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+	// loc is the time zone, and layout the time layout of Text
+	// records; JSON records use RFC 3339. now is the clock.
+	loc    *time.Location
+	layout string
+	now    func() time.Time
+}{w: os.Stderr, loc: time.Local, layout: TimeFormat, now: time.Now}
+
+// SetOutput sets where records are written. Each record is one
+// Write.
+func SetOutput(w io.Writer) {
+	output.mu.Lock()
+	output.w = w
+	output.mu.Unlock()
+}
+
+// SetFormat sets how records are written.
+func SetFormat(f Format) {
+	output.mu.Lock()
+	output.format = f
+	output.mu.Unlock()
+}
+
+// SetLocation sets the time zone record times are written in.
+func SetLocation(loc *time.Location) {
+	output.mu.Lock()
+	output.loc = loc
+	output.mu.Unlock()
+}
+
+// SetTimeFormat sets the layout, as for time.Format, of the time in
+// Text records. An empty layout leaves the time out.
+func SetTimeFormat(layout string) {
+	output.mu.Lock()
+	output.layout = layout
+	output.mu.Unlock()
+}
+
+// SetClock sets the clock that timestamps records. A clock that
+// stands still makes the output of a test the same from run to run.
+func SetClock(now func() time.Time) {
+	output.mu.Lock()
+	output.now = now
+	output.mu.Unlock()
+}
+
+// Logger writes the records of a subsystem. A Logger is safe for
+// use by many goroutines.
+type Logger struct {
+	name string
+	// fields are added to every record, before the record's own.
+	fields []interface{}
+}
+
+// New returns the Logger of the subsystem name.
+func New(name string) *Logger {
+	return &Logger{name: name}
+}
+
+// Name returns the subsystem of l.
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// With returns a Logger that adds the key/value pairs kv to every
+// record of l.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	return &Logger{name: l.name, fields: append(fields, kv...)}
+}
+
+// Enabled reports whether records of l at level lv are written. Use
+// it to skip work that only feeds a record.
+func (l *Logger) Enabled(lv Level) bool {
+	return levels.Load().(*Levels).enabled(l.name, lv)
+}
+
+// Trace, Debug, Info, Warn and Error write a record of msg and the
+// key/value pairs kv at their level. Keys are strings; a key
+// without a value is written under "!extra".
+func (l *Logger) Trace(msg string, kv ...interface{}) { l.log(Trace, msg, kv) }
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(Debug, msg, kv) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.log(Info, msg, kv) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.log(Warn, msg, kv) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(Error, msg, kv) }
+
+func (l *Logger) log(lv Level, msg string, kv []interface{}) {
+	if l.Enabled(lv) {
+		l.write(3, lv, msg, kv)
+	}
+}
+
+// Output writes a record like Info and the rest do, for helpers that
+// wrap a Logger. calldepth is the number of frames to skip to find
+// the caller to report, as for log.Output: 1 is the caller of
+// Output.
+func (l *Logger) Output(calldepth int, lv Level, msg string, kv ...interface{}) {
+	if l.Enabled(lv) {
+		l.write(calldepth+1, lv, msg, kv)
+	}
+}
+
+// write formats and writes a record. skip counts the frames above
+// write to the caller to report.
+func (l *Logger) write(skip int, lv Level, msg string, kv []interface{}) {
+	caller := ""
+	if _, file, line, ok := runtime.Caller(skip); ok {
+		caller = path.Base(file) + ":" + strconv.Itoa(line)
+	}
+	fields := kv
+	if len(l.fields) > 0 {
+		fields = append(append([]interface{}(nil), l.fields...), kv...)
+	}
+
+	output.mu.Lock()
+	w, format, loc, layout, clock := output.w, output.format, output.loc, output.layout, output.now
+	output.mu.Unlock()
+
+	now := clock().In(loc)
+	var buf bytes.Buffer
+	if format == JSON {
+		writeJSON(&buf, now.Format(time.RFC3339Nano), lv, l.name, msg, caller, fields)
+	} else {
+		writeText(&buf, now.Format(layout), lv, l.name, msg, caller, fields)
+	}
+
+	// The lock keeps records whole when w is not safe for
+	// concurrent writes.
+	output.mu.Lock()
+	w.Write(buf.Bytes())
+	output.mu.Unlock()
+}
+
+// pairs calls f for each key/value pair of kv.
+func pairs(kv []interface{}, f func(key string, v interface{})) {
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			f("!extra", kv[i])
+			break
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		f(key, kv[i+1])
+	}
+}
+
+func writeText(buf *bytes.Buffer, now string, lv Level, name, msg, caller string, kv []interface{}) {
+	if caller != "" {
+		buf.WriteString(caller)
+		buf.WriteByte(' ')
+	}
+	if now != "" {
+		buf.WriteString(now)
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(strings.ToUpper(lv.String()))
+	buf.WriteByte(' ')
+	if name != "" {
+		buf.WriteString(name)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(msg)
+	pairs(kv, func(key string, v interface{}) {
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(textValue(v))
+	})
+	buf.WriteByte('\n')
+}
+
+// textValue formats v for a Text record, quoted if it would
+// otherwise be hard to tell where it ends.
+func textValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func writeJSON(buf *bytes.Buffer, now string, lv Level, name, msg, caller string, kv []interface{}) {
+	first := true
+	field := func(key string, v interface{}) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(jsonValue(v))
+	}
+	buf.WriteByte('{')
+	field("time", now)
+	field("level", lv.String())
+	if name != "" {
+		field("sub", name)
+	}
+	field("msg", msg)
+	if caller != "" {
+		field("caller", caller)
+	}
+	pairs(kv, field)
+	buf.WriteString("}\n")
+}
+
+// jsonValue encodes v for a JSON record. Errors, and values that
+// print themselves but have no JSON form of their own, such as
+// time.Duration, are written as their strings; values JSON cannot
+// encode are written as fmt prints them.
+func jsonValue(v interface{}) []byte {
+	switch x := v.(type) {
+	case error:
+		v = x.Error()
+	case json.Marshaler:
+	case fmt.Stringer:
+		v = x.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return b
+}
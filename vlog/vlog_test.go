@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package vlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capture sends records to a buffer, in format f, until the
+// returned func is called.
+func capture(f Format, ls Levels) (*bytes.Buffer, func()) {
+	buf := new(bytes.Buffer)
+	old := CurrentLevels()
+	SetOutput(buf)
+	SetFormat(f)
+	SetLevels(ls)
+	return buf, func() {
+		SetLevels(old)
+		SetFormat(Text)
+		SetOutput(os.Stderr)
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	for _, tc := range []struct {
+		spec, want string
+	}{
+		{"", "info"},
+		{"debug", "debug"},
+		{" info , reload=Debug,tiled=trace ", "info,reload=debug,tiled=trace"},
+		{"reload=warn", "info,reload=warn"},
+		{"trace,reload=off,error,reload=debug", "error,reload=debug"},
+	} {
+		ls, err := ParseLevels(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		if got := ls.String(); got != tc.want {
+			t.Errorf("%q parsed as %q, want %q", tc.spec, got, tc.want)
+		}
+	}
+	for _, spec := range []string{"loud", "=debug", "reload=loud"} {
+		if _, err := ParseLevels(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	ls, _ := ParseLevels("warn,reload=debug,tiled=off")
+	buf, done := capture(Text, ls)
+	defer done()
+	reload, tiled, other := New("reload"), New("tiled"), New("other")
+	reload.Trace("no")
+	reload.Debug("yes")
+	tiled.Error("no")
+	other.Info("no")
+	other.Warn("yes")
+	if got := strings.Count(buf.String(), "yes"); got != 2 || strings.Contains(buf.String(), "no") {
+		t.Errorf("wrong records written:\n%s", buf)
+	}
+
+	// Levels change at run time.
+	buf.Reset()
+	SetLevel("tiled", Trace)
+	tiled.Trace("yes")
+	if !strings.Contains(buf.String(), "yes") {
+		t.Error("SetLevel did not take effect")
+	}
+	if got := CurrentLevels().String(); got != "warn,reload=debug,tiled=trace" {
+		t.Errorf("levels are %q after SetLevel", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	buf, done := capture(Text, Levels{Default: Info})
+	defer done()
+	SetLocation(time.UTC)
+	defer SetLocation(time.Local)
+	l := New("loader").With("worker", 3)
+	l.Info("loaded", "path", "my file.png", "err", nil, "size", 12, "odd")
+	want := regexp.MustCompile(`^vlog_test\.go:\d+ \d{4}-\d\d-\d\d \d\d:\d\d:\d\d[.\d]* \+0000 UTC INFO loader: ` +
+		`loaded worker=3 path="my file.png" err=<nil> size=12 !extra=odd\n$`)
+	if !want.MatchString(buf.String()) {
+		t.Errorf("got %q", buf)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	buf, done := capture(JSON, Levels{Default: Info})
+	defer done()
+	New("reload").Warn("failed", "err", errors.New("bad"), "after", 1500*time.Millisecond, "n", 2)
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %s", err, buf)
+	}
+	for k, v := range map[string]interface{}{
+		"level": "warn", "sub": "reload", "msg": "failed",
+		"err": "bad", "after": "1.5s", "n": 2.0,
+	} {
+		if rec[k] != v {
+			t.Errorf("%s is %v, want %v", k, rec[k], v)
+		}
+	}
+	if c, _ := rec["caller"].(string); !strings.HasPrefix(c, "vlog_test.go:") {
+		t.Errorf("caller is %q", c)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, rec["time"].(string)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClock(t *testing.T) {
+	buf, done := capture(Text, Levels{Default: Info})
+	defer done()
+	defer SetClock(time.Now)
+	defer SetLocation(time.Local)
+	defer SetTimeFormat(TimeFormat)
+	tokyo := time.FixedZone("JST", 9*60*60)
+	SetClock(func() time.Time { return time.Date(2019, 10, 1, 16, 0, 0, 0, time.UTC) })
+	SetLocation(tokyo)
+	SetTimeFormat(time.Kitchen)
+	New("x").Info("a")
+	SetFormat(JSON)
+	New("x").Info("b")
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasSuffix(lines[0], " 1:00AM INFO x: a") {
+		t.Errorf("text record %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `{"time":"2019-10-02T01:00:00+09:00",`) {
+		t.Errorf("JSON record %q", lines[1])
+	}
+}
+
+func TestRecordLevel(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want Level
+		ok   bool
+	}{
+		{"reload.go:120 2019-10-01 12:00:00.5 -0400 EDT WARN reload: reload failed path=scene.json", Warn, true},
+		{"2019-10-01 12:00:00 +0000 UTC DEBUG tiled: made", Debug, true},
+		{`{"time":"2019-10-01T12:00:00Z","level":"error","msg":"x"}`, Error, true},
+		{"the second line of a message", 0, false},
+		{`{"level":"loud"}`, 0, false},
+	} {
+		l, ok := RecordLevel(tc.line)
+		if l != tc.want || ok != tc.ok {
+			t.Errorf("%q: got %v, %v, want %v, %v", tc.line, l, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+// wrapper logs like the printf helpers that wrap a Logger.
+func wrapper(l *Logger, msg string) {
+	l.Output(2, Info, msg)
+}
+
+func TestOutputCaller(t *testing.T) {
+	buf, done := capture(Text, Levels{Default: Info})
+	defer done()
+	_, _, line, _ := runtime.Caller(0)
+	wrapper(New("main"), "hi")
+	if want := "vlog_test.go:" + strconv.Itoa(line+1) + " "; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("reported caller of %q is not the caller of wrapper", buf)
+	}
+}
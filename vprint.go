@@ -6,12 +6,14 @@ import (
 	"os"
 	"path"
 	"runtime"
-	"sync"
 	"time"
 
 	"4d63.com/tz"
+
+	"github.com/glycerine/hello_gio.go/vlog"
 )
 
+// NYC is America/New_York, the default zone of log times.
 var NYC *time.Location
 
 func init() {
@@ -19,65 +21,83 @@ func init() {
 	NYC, err = tz.LoadLocation("America/New_York")
 	panicOn(err)
 
+	vlog.SetOutput(ourStdout{})
+	vlog.SetLocation(NYC)
 }
 
-// for tons of debug output
-var Verbose bool = false
-var VerboseVerbose bool = false
+// loadLocation loads the time zone name, such as "UTC", "Local" or
+// "America/New_York", from the host's zoneinfo or, where the host
+// has none, as on many Windows machines, from the copy embedded by
+// 4d63.com/tz.
+func loadLocation(name string) (*time.Location, error) {
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc, nil
+	}
+	if loc, err := tz.LoadLocation(name); err == nil {
+		return loc, nil
+	}
+	return nil, fmt.Errorf("unknown time zone %q", name)
+}
+
+// The printf helpers below are thin wrappers over the vlog logger
+// of the main package, which writes to OurStdout; which of them print
+// is set by -v, -vv and -log (see setupLogging). P and p log at the
+// debug level, PP, pp and PPP at trace, and VV, vv, AlwaysPrintf and
+// TSPrintf at info, which is on unless turned down.
+var mainLog = vlog.New("main")
 
 func P(format string, a ...interface{}) {
-	if Verbose {
-		TSPrintf(format, a...)
-	}
+	logf(vlog.Debug, format, a...)
 }
 
 func PP(format string, a ...interface{}) {
-	if VerboseVerbose {
-		TSPrintf(format, a...)
-	}
+	logf(vlog.Trace, format, a...)
 }
 
 func VV(format string, a ...interface{}) {
-	TSPrintf(format, a...)
+	logf(vlog.Info, format, a...)
 }
 
 func AlwaysPrintf(format string, a ...interface{}) {
-	TSPrintf(format, a...)
+	logf(vlog.Info, format, a...)
 }
 
 var vv = VV
 
-// without the file/line, otherwise the same as PP
+// PPP is the same as PP. It used to leave out the file and line.
 func PPP(format string, a ...interface{}) {
-	if VerboseVerbose {
-		Printf("\n%s ", ts())
-		Printf(format+"\n", a...)
-	}
+	logf(vlog.Trace, format, a...)
 }
 
 func PB(w io.Writer, format string, a ...interface{}) {
-	if Verbose {
+	if mainLog.Enabled(vlog.Debug) {
 		fmt.Fprintf(w, "\n"+format+"\n", a...)
 	}
 }
 
-var tsPrintfMut sync.Mutex
-
 // time-stamped printf
 func TSPrintf(format string, a ...interface{}) {
-	tsPrintfMut.Lock()
-	Printf("\n%s %s ", FileLine(3), ts())
-	Printf(format+"\n", a...)
-	tsPrintfMut.Unlock()
+	logf(vlog.Info, format, a...)
 }
 
-// get timestamp for logging purposes
-func ts() string {
-	return time.Now().In(NYC).Format("2006-01-02 15:04:05.999 -0700 MST")
+// logf logs the formatted message at level l, as from the caller of
+// its caller. It only formats messages that will be written.
+func logf(l vlog.Level, format string, a ...interface{}) {
+	if mainLog.Enabled(l) {
+		mainLog.Output(3, l, fmt.Sprintf(format, a...))
+	}
 }
 
-// so we can multi write easily, use our own printf
-var OurStdout io.Writer = os.Stdout
+// so we can multi write easily, use our own printf. What is
+// written is also kept for the log console.
+var OurStdout io.Writer = io.MultiWriter(os.Stdout, logs)
+
+// ourStdout writes to OurStdout, whatever it is at the time.
+type ourStdout struct{}
+
+func (ourStdout) Write(p []byte) (int, error) {
+	return OurStdout.Write(p)
+}
 
 // Printf formats according to a format specifier and writes to standard output.
 // It returns the number of bytes written and any write error encountered.
@@ -97,15 +117,13 @@ func FileLine(depth int) string {
 }
 
 func p(format string, a ...interface{}) {
-	if Verbose {
-		TSPrintf(format, a...)
-	}
+	logf(vlog.Debug, format, a...)
 }
 
 var pp = PP
 
 func pbb(w io.Writer, format string, a ...interface{}) {
-	if Verbose {
+	if mainLog.Enabled(vlog.Debug) {
 		fmt.Fprintf(w, "\n"+format+"\n", a...)
 	}
 }
@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/gesture"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+
+	"github.com/glycerine/hello_gio.go/scene"
+)
+
+const (
+	// maxZoom is how far an image can be magnified beyond fitting
+	// its destination.
+	maxZoom = 64
+	// zoomScroll is the scroll distance, in pixels, that doubles
+	// or halves the zoom.
+	zoomScroll = 240
+	// doubleClick is the longest time between the presses of a
+	// double-click.
+	doubleClick = 400 * time.Millisecond
+)
+
+// imageView is the zoom and pan of a displayed image, kept from
+// frame to frame. The wheel zooms in and out around the cursor,
+// dragging pans, and a double-click shows the whole image again.
+//
+// Nothing is decoded or copied to zoom: the whole image is painted
+// into a rectangle larger than its view, clipped to the view, so the
+// GPU samples it at full resolution.
+type imageView struct {
+	// zoom is the magnification relative to the image at its
+	// placement's base rectangle. 0 means 1.
+	zoom float32
+	// center is the point of the image shown at the center of the
+	// view, in image pixels.
+	center f32.Point
+
+	scroll gesture.Scroll
+	// cursor is the last known pointer position.
+	cursor f32.Point
+
+	pressed   bool
+	pid       pointer.ID
+	last      f32.Point
+	lastPress time.Duration
+}
+
+// Update processes the pointer and scroll events since the last
+// frame, for the image of size src placed at p.
+func (v *imageView) Update(gtx *layout.Context, p scene.Placement, src image.Point) {
+	if v.zoom == 0 {
+		v.reset(src)
+	}
+	for _, evt := range gtx.Events(v) {
+		e, ok := evt.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Press:
+			if v.pressed || !e.Hit {
+				break
+			}
+			if e.Source == pointer.Mouse && e.Buttons != pointer.ButtonLeft {
+				break
+			}
+			if e.Time-v.lastPress < doubleClick && v.lastPress != 0 {
+				v.reset(src)
+				v.lastPress = 0
+				break
+			}
+			v.pressed = true
+			v.pid = e.PointerID
+			v.last = e.Position
+			v.lastPress = e.Time
+		case pointer.Move:
+			v.cursor = e.Position
+			if !v.pressed || e.PointerID != v.pid {
+				break
+			}
+			k := v.scale(p.Base, src)
+			d := e.Position.Sub(v.last)
+			v.center = v.center.Sub(f32.Point{X: d.X / k.X, Y: d.Y / k.Y})
+			v.last = e.Position
+		case pointer.Release:
+			if e.PointerID == v.pid {
+				v.pressed = false
+			}
+		case pointer.Cancel:
+			v.pressed = false
+		}
+	}
+	dist := v.scroll.Scroll(gtx, gtx, gtx.Now(), gesture.Vertical)
+	if v.pressed {
+		// On touch screens gesture.Scroll also reports drags,
+		// which pan instead.
+		v.scroll.Stop()
+		dist = 0
+	}
+	if dist != 0 {
+		v.zoomAt(p, src, v.cursor, float32(math.Pow(2, -float64(dist)/zoomScroll)))
+	}
+	v.clamp(p, src)
+}
+
+// Add registers the pointer and scroll handlers over view. Add it
+// after the image is painted.
+func (v *imageView) Add(ops *op.Ops, view image.Rectangle) {
+	var stack op.StackOp
+	stack.Push(ops)
+	pointer.Rect(view).Add(ops)
+	pointer.InputOp{Key: v, Grab: v.pressed}.Add(ops)
+	v.scroll.Add(ops)
+	stack.Pop()
+}
+
+// PaintRect returns where the whole image lands at the current zoom
+// and pan. It may overflow p.View, which it should be clipped to.
+func (v *imageView) PaintRect(p scene.Placement, src image.Point) f32.Rectangle {
+	k := v.scale(p.Base, src)
+	c := viewCenter(p)
+	min := c.Sub(f32.Point{X: v.center.X * k.X, Y: v.center.Y * k.Y})
+	return f32.Rectangle{
+		Min: min,
+		Max: min.Add(f32.Point{X: float32(src.X) * k.X, Y: float32(src.Y) * k.Y}),
+	}
+}
+
+// reset shows the whole image.
+func (v *imageView) reset(src image.Point) {
+	v.zoom = 1
+	v.center = toPointF(src).Mul(.5)
+}
+
+// scale returns the size of an image pixel in window pixels, for
+// an image of size src that fills base at zoom 1.
+func (v *imageView) scale(base image.Rectangle, src image.Point) f32.Point {
+	if src.X == 0 || src.Y == 0 {
+		return f32.Point{X: 1, Y: 1}
+	}
+	return f32.Point{
+		X: float32(base.Dx()) / float32(src.X) * v.zoom,
+		Y: float32(base.Dy()) / float32(src.Y) * v.zoom,
+	}
+}
+
+// zoomAt multiplies the zoom by f, keeping the image point under at
+// in place.
+func (v *imageView) zoomAt(p scene.Placement, src image.Point, at f32.Point, f float32) {
+	z := v.zoom * f
+	if z < 1 {
+		z = 1
+	}
+	if z > maxZoom {
+		z = maxZoom
+	}
+	k0 := v.scale(p.Base, src)
+	v.zoom = z
+	k1 := v.scale(p.Base, src)
+	// at - c is the offset from the center of the view, which
+	// shows v.center. The image point under it must not move.
+	off := at.Sub(viewCenter(p))
+	v.center = v.center.Add(f32.Point{
+		X: off.X/k0.X - off.X/k1.X,
+		Y: off.Y/k0.Y - off.Y/k1.Y,
+	})
+}
+
+// clamp keeps the view within the image.
+func (v *imageView) clamp(p scene.Placement, src image.Point) {
+	k := v.scale(p.Base, src)
+	clampf := func(c, size, view, k float32) float32 {
+		// half is half the view, in image pixels.
+		half := view / 2 / k
+		if half >= size/2 {
+			return size / 2
+		}
+		if c < half {
+			return half
+		}
+		if c > size-half {
+			return size - half
+		}
+		return c
+	}
+	v.center.X = clampf(v.center.X, float32(src.X), float32(p.View.Dx()), k.X)
+	v.center.Y = clampf(v.center.Y, float32(src.Y), float32(p.View.Dy()), k.Y)
+}
+
+// viewCenter returns the center of p.View.
+func viewCenter(p scene.Placement) f32.Point {
+	return toRectF(p.View).Min.Add(toPointF(p.View.Size()).Mul(.5))
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+
+	"github.com/glycerine/hello_gio.go/raster"
+)
+
+func TestImageZoomAndPan(t *testing.T) {
+	q := make(scriptQueue)
+	sc := testScene(t)
+	m := loadedDrawState(q, sc)
+	e := testFrame()
+	th := testTheme()
+	si := m.images[0]
+	v := &si.view
+	frame := func() { drawFrame(m, th, e) }
+	// imagePoint returns the image pixel under the window point p.
+	imagePoint := func(p f32.Point) f32.Point {
+		r := v.PaintRect(si.place, si.src.Size())
+		return f32.Point{
+			X: (p.X - r.Min.X) / r.Dx() * float32(si.src.Dx()),
+			Y: (p.Y - r.Min.Y) / r.Dy() * float32(si.src.Dy()),
+		}
+	}
+	near := func(a, b f32.Point) bool {
+		return math.Abs(float64(a.X-b.X)) < .01 && math.Abs(float64(a.Y-b.Y)) < .01
+	}
+
+	frame()
+	cursor := f32.Point{X: 500, Y: 300}
+	before := imagePoint(cursor)
+	q[v] = append(q[v], pointer.Event{Type: pointer.Move, Position: cursor})
+	q[&v.scroll] = append(q[&v.scroll], pointer.Event{Type: pointer.Move, Position: cursor, Scroll: f32.Point{Y: -zoomScroll}})
+	frame()
+	if v.zoom != 2 {
+		t.Fatalf("zoom is %v after scrolling up %d pixels, want 2", v.zoom, zoomScroll)
+	}
+	if after := imagePoint(cursor); !near(before, after) {
+		t.Errorf("image point under the cursor moved from %v to %v", before, after)
+	}
+
+	// Drag the image 100 pixels left.
+	grabbed := imagePoint(cursor)
+	to := cursor.Sub(f32.Point{X: 100})
+	q[v] = append(q[v],
+		pointer.Event{Type: pointer.Press, Hit: true, Buttons: pointer.ButtonLeft, Position: cursor, Time: time.Second},
+		pointer.Event{Type: pointer.Move, Position: to},
+		pointer.Event{Type: pointer.Release, Position: to},
+	)
+	frame()
+	if got := imagePoint(to); !near(got, grabbed) {
+		t.Errorf("dragged image point is at %v, want %v", got, grabbed)
+	}
+
+	// Double-click to see the whole image again.
+	for _, at := range []time.Duration{2 * time.Second, 2*time.Second + 200*time.Millisecond} {
+		q[v] = append(q[v],
+			pointer.Event{Type: pointer.Press, Hit: true, Buttons: pointer.ButtonLeft, Position: cursor, Time: at},
+			pointer.Event{Type: pointer.Release, Position: cursor, Time: at},
+		)
+	}
+	frame()
+	if got, want := v.PaintRect(si.place, si.src.Size()), toRectF(si.place.Base); v.zoom != 1 || got != want {
+		t.Errorf("after a double-click zoom %v and paint rect %v, want 1 and %v", v.zoom, got, want)
+	}
+}
+
+// TestGoldenImageZoom covers an image zoomed 8 times into its
+// center, clipped to its destination.
+func TestGoldenImageZoom(t *testing.T) {
+	sc := testScene(t)
+	sc.Background = ""
+	m := loadedDrawState(nil, sc)
+	si := m.images[0]
+	si.view.reset(si.src.Size())
+	si.view.zoom = 8
+	e := testFrame()
+	si.view.clamp(si.spec.Placement(si.src.Size(), e.Size, e.Config), si.src.Size())
+	showImage(e, m)
+	checkGolden(t, "image_zoom", raster.Render(m.gtx.Ops, e.Size))
+}